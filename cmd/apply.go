@@ -5,15 +5,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/alexlm78/sokru/internal/config"
 	"github.com/alexlm78/sokru/internal/i18n"
-	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/pkg/sokru"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 // applyCmd represents the apply command
@@ -25,86 +25,135 @@ var applyCmd = &cobra.Command{
 }
 
 func ApplyFunc(cmd *cobra.Command, args []string) {
-	fmt.Println("Applying configuration changes...")
-	fmt.Println()
+	lock := lockConfig(cmd)
+	defer lock.Release()
 
-	// Get current config to preserve flags
-	currentCfg, _ := config.GetConfig()
-	preserveDryRun := currentCfg.DryRun
-	preserveVerbose := currentCfg.Verbose
+	text := !jsonOutput()
+	if text {
+		fmt.Println("Applying configuration changes...")
+		fmt.Println()
+	}
 
-	// 1. Reload configuration from disk
-	cfg, err := config.LoadConfig()
+	// 1. Reload configuration from disk, with the same flags and --config
+	// file, once the sok that was changing it is done
+	cfg, _, err := config.Resolve(configOptions())
 	if err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
 
-	// Preserve command-line flags
-	cfg.DryRun = preserveDryRun
-	cfg.Verbose = preserveVerbose
-	config.SetConfig(cfg)
-
-	if cfg.Verbose {
+	if cfg.Verbose && text {
 		fmt.Println("✓ Configuration reloaded from disk")
 	}
 
-	// 2. Get current symlinks from YAML
-	symlinkFile := expandPath(cfg.SymlinksFile)
-
-	if _, err := os.Stat(symlinkFile); os.IsNotExist(err) {
-		log.Fatalf("Symlinks file not found: %s\nPlease create the file or update the configuration with: sok config symlinkfile <path>", symlinkFile)
+	printer := func(event sokru.Event) {
+		switch event.Type {
+		case sokru.EventLoaded:
+			printWarnings(event.Issues, cfg.Verbose)
+			if cfg.Verbose {
+				fmt.Printf("✓ Read %d symlink configuration(s) from: %s\n", event.Count, event.Path)
+			}
+		case sokru.EventPlanned:
+			printPlan(event.Plan, event.DryRun)
+		case sokru.EventChange:
+			// Targets edited since the plan are always reported
+			if cfg.Verbose || event.Change.Kind == sokru.ChangeSkipped {
+				printApplyChange(*event.Change)
+			}
+		case sokru.EventError:
+			if errors.Is(event.Err, sokru.ErrSymlinksFileNotFound) {
+				log.Printf("Symlinks file not found: %s\nPlease create the file or update the configuration with: sok config symlinkfile <path>", expandPath(cfg.SymlinksFile))
+				return
+			}
+			printEvent(event, cfg.Verbose)
+		default:
+			printEvent(event, cfg.Verbose)
+		}
 	}
+	opts := sokru.RunOptions{SkipValidation: skipValidation, Observer: observer(printer), Lock: lock}
 
-	data, err := os.ReadFile(symlinkFile)
+	// 2. Compare the targets of the symlinks file with their sources
+	plan, err := sokru.NewPlan(cfg, opts)
 	if err != nil {
-		log.Fatalf("Error reading YAML file: %v", err)
+		os.Exit(1)
 	}
 
-	var symlinkConfigs []SymlinkConfig
-	err = yaml.Unmarshal(data, &symlinkConfigs)
-	if err != nil {
-		log.Fatalf("Error parsing YAML: %v", err)
+	if !plan.Pending() {
+		if text {
+			fmt.Println("\n✓ No changes needed - all symlinks are up to date!")
+		}
+		return
 	}
 
-	if cfg.Verbose {
-		fmt.Printf("✓ Read %d symlink configuration(s) from: %s\n", len(symlinkConfigs), symlinkFile)
+	// 3. Apply changes (unless dry-run)
+	if cfg.DryRun {
+		if text {
+			fmt.Println("\n[DRY-RUN] No changes were made")
+		}
+		return
 	}
 
-	// 3. Build a map of configured symlinks (filtered by OS)
-	configuredSymlinks := make(map[string]string)
-	for _, entry := range symlinkConfigs {
-		// Get links for current OS
-		links := entry.getLinksForOS(cfg.OS)
+	if text {
+		fmt.Println("\n=== Applying Changes ===")
+	}
 
-		for target, source := range links {
-			targetPath := expandPath(target)
-			sourcePath := expandPath(source)
-			configuredSymlinks[targetPath] = sourcePath
-		}
+	// The links are in place even when their state could not be saved
+	result, err := sokru.Apply(cfg, plan, opts)
+	if failed(err) {
+		os.Exit(1)
+	}
+	if !text {
+		return
+	}
+
+	// 4. Summary
+	created, updated := result.Count(sokru.ChangeCreated), result.Count(sokru.ChangeUpdated)
+	alreadyCorrect := plan.Count(sokru.ChangeUnchanged)
+	fmt.Printf("\n=== %s ===\n", i18n.T(i18n.MsgApplySummary))
+	if created > 0 {
+		fmt.Printf("Created: %d symlink(s)\n", created)
+	}
+	if updated > 0 {
+		fmt.Printf("Updated: %d symlink(s)\n", updated)
+	}
+	if alreadyCorrect > 0 {
+		fmt.Printf("Already correct: %d symlink(s)\n", alreadyCorrect)
 	}
 
-	// 4. Check existing symlinks and track changes
+	fmt.Println(i18n.Success(i18n.MsgConfigApplied))
+}
+
+// printPlan prints the changes apply is about to make
+func printPlan(plan *sokru.Plan, dryRun bool) {
 	var toCreate, toUpdate []string
-	var alreadyCorrect int
-
-	// Check what needs to be created or updated
-	for targetPath, sourcePath := range configuredSymlinks {
-		existingLink, err := os.Readlink(targetPath)
-		if os.IsNotExist(err) {
-			// Symlink doesn't exist, needs to be created
-			toCreate = append(toCreate, fmt.Sprintf("%s -> %s", targetPath, sourcePath))
-		} else if err == nil {
-			if existingLink != sourcePath {
-				// Symlink exists but points to wrong source
-				toUpdate = append(toUpdate, fmt.Sprintf("%s: %s -> %s", targetPath, existingLink, sourcePath))
-			} else {
-				// Symlink is correct
-				alreadyCorrect++
-			}
+	for _, change := range plan.Changes {
+		link := change.Link
+		materialized := link.Mode != sokru.ModeSymlink
+
+		if dryRun && change.Diff != "" {
+			fmt.Println(i18n.Info(i18n.MsgDryRunWouldRender, link.Target, link.Template))
+			printDiff(change.Diff)
+		}
+
+		switch {
+		case change.Kind == sokru.ChangeCreated && materialized:
+			toCreate = append(toCreate, fmt.Sprintf("%s => %s (%s)", link.Target, link.Source, link.Mode))
+		case change.Kind == sokru.ChangeCreated:
+			toCreate = append(toCreate, fmt.Sprintf("%s -> %s", link.Target, link.Source))
+		case change.Kind == sokru.ChangeUpdated && materialized:
+			toUpdate = append(toUpdate, fmt.Sprintf("%s => %s (%s)", link.Target, link.Source, link.Mode))
+		case change.Kind == sokru.ChangeUpdated && change.Status == sokru.StatusWrongTarget:
+			// Symlink exists but points to wrong source
+			toUpdate = append(toUpdate, fmt.Sprintf("%s: %s -> %s", link.Target, change.Previous, link.Source))
+		case change.Kind == sokru.ChangeUpdated:
+			// Symlink is correct but the template output changed
+			toUpdate = append(toUpdate, fmt.Sprintf("%s: %s (re-render)", link.Target, link.Template))
+		case change.Kind == sokru.ChangeSkipped && change.Status == sokru.StatusDrifted:
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyDrifted, link.Target))
+		case change.Kind == sokru.ChangeSkipped && change.Status == sokru.StatusBlocked:
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyUnmanaged, link.Target))
 		}
 	}
 
-	// 5. Show what will change
 	fmt.Println("=== Changes to Apply ===")
 
 	if len(toCreate) > 0 {
@@ -121,99 +170,28 @@ func ApplyFunc(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if alreadyCorrect > 0 {
+	if alreadyCorrect := plan.Count(sokru.ChangeUnchanged); alreadyCorrect > 0 {
 		fmt.Printf("\n✅ Already Correct: %d\n", alreadyCorrect)
 	}
+}
 
-	if len(toCreate) == 0 && len(toUpdate) == 0 {
-		fmt.Println("\n✓ No changes needed - all symlinks are up to date!")
-		return
-	}
-
-	// 6. Apply changes (unless dry-run)
-	if cfg.DryRun {
-		fmt.Println("\n[DRY-RUN] No changes were made")
-		return
-	}
-
-	fmt.Println("\n=== Applying Changes ===")
-
-	// Create rollback tracker
-	tracker := rollback.NewTracker()
-	var created, updated, failed int
-	var hasError bool
-
-	// Create new symlinks
-	for targetPath, sourcePath := range configuredSymlinks {
-		existingLink, err := os.Readlink(targetPath)
-
-		if os.IsNotExist(err) {
-			// Create new symlink
-			if err := os.Symlink(sourcePath, targetPath); err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCreatingSymlink, targetPath, sourcePath, err))
-				failed++
-				hasError = true
-				break
-			} else {
-				if cfg.Verbose {
-					fmt.Println(i18n.Success(i18n.MsgCreated, targetPath, sourcePath))
-				}
-				created++
-				tracker.TrackCreated(targetPath, sourcePath)
-			}
-		} else if err == nil && existingLink != sourcePath {
-			// Update existing symlink
-			if err := os.Remove(targetPath); err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorRemovingSymlink, targetPath, err))
-				failed++
-				hasError = true
-				break
-			}
-			if err := os.Symlink(sourcePath, targetPath); err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCreatingSymlink, targetPath, sourcePath, err))
-				failed++
-				hasError = true
-				break
-			} else {
-				if cfg.Verbose {
-					fmt.Println(i18n.Success(i18n.MsgUpdated, targetPath, sourcePath))
-				}
-				updated++
-				tracker.TrackUpdated(targetPath, sourcePath, existingLink)
-			}
-		}
-	}
-
-	// Perform rollback if there was an error
-	if hasError && tracker.HasActions() {
-		fmt.Println()
-		fmt.Println(i18n.Warning(i18n.MsgRollbackStarting, tracker.Count()))
-
-		if err := tracker.Rollback(); err != nil {
-			log.Printf("%s", i18n.Error(i18n.MsgRollbackFailed, err))
-		} else {
-			fmt.Println(i18n.Success(i18n.MsgRollbackComplete))
-		}
-
-		os.Exit(1)
-	}
-
-	// 7. Summary
-	fmt.Printf("\n=== %s ===\n", i18n.T(i18n.MsgApplySummary))
-	if created > 0 {
-		fmt.Printf("Created: %d symlink(s)\n", created)
+// printApplyChange prints, in verbose mode, what apply did to a target
+func printApplyChange(change sokru.Change) {
+	link := change.Link
+	switch {
+	case change.Kind == sokru.ChangeSkipped && change.Status == sokru.StatusDrifted:
+		log.Printf("%s", i18n.Warning(i18n.MsgCopyDrifted, link.Target))
+	case change.Kind == sokru.ChangeSkipped:
+		log.Printf("%s", i18n.Warning(i18n.MsgCopyUnmanaged, link.Target))
+	case link.Mode != sokru.ModeSymlink && change.Status != sokru.StatusInstalled:
+		fmt.Println(i18n.Success(i18n.MsgCopied, link.Mode, link.Target, link.Source))
+	case link.Mode != sokru.ModeSymlink:
+		// Only the template output changed
+	case change.Kind == sokru.ChangeCreated:
+		fmt.Println(i18n.Success(i18n.MsgCreated, link.Target, link.Source))
+	default:
+		fmt.Println(i18n.Success(i18n.MsgUpdated, link.Target, link.Source))
 	}
-	if updated > 0 {
-		fmt.Printf("Updated: %d symlink(s)\n", updated)
-	}
-	if alreadyCorrect > 0 {
-		fmt.Printf("Already correct: %d symlink(s)\n", alreadyCorrect)
-	}
-	if failed > 0 {
-		fmt.Printf("Failed: %d symlink(s)\n", failed)
-	}
-
-	fmt.Println(i18n.Success(i18n.MsgConfigApplied))
 }
 
 func init() {
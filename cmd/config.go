@@ -7,21 +7,29 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/render"
 	"github.com/spf13/cobra"
 )
 
 func init() {
+	configShowCmd.Flags().BoolVar(&showOrigin, "origin", false, "Show where each value came from")
 	configCmd.AddCommand(configShowCmd)
-	configCmd.AddCommand(configDotDirCmd)
-	configCmd.AddCommand(configSymlinkFileCmd)
-	configCmd.AddCommand(configVerboseCmd)
-	configCmd.AddCommand(configDryrunCmd)
-	configCmd.AddCommand(configOsCmd)
-	configCmd.AddCommand(configLanguageCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show the changes without writing them")
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(keyAlias("dotdir [path]", "dotfiles_dir", "Set the directory where the dotfiles are located"))
+	configCmd.AddCommand(keyAlias("symlinkfile [path]", "symlinks_file", "Set the file where the symlinks are located"))
+	configCmd.AddCommand(keyAlias("verbose [true|false]", "verbose", "Set the verbosity of the output"))
+	configCmd.AddCommand(keyAlias("dryrun [true|false]", "dry_run", "Set the dry run mode"))
+	configCmd.AddCommand(keyAlias("os [operating-system]", "os", "Set the operating system"))
+	configCmd.AddCommand(keyAlias("language [lang]", "language", "Set the language (en, es)"))
 	configCmd.AddCommand(configHelpCmd)
 	rootCmd.AddCommand(configCmd)
 }
@@ -33,253 +41,254 @@ var configCmd = &cobra.Command{
 	Long:  `This command will allow you to set up the configuration of sokru.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Show all config values by default
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath, _ := config.GetConfigPath()
-		fmt.Printf("Configuration file: %s\n\n", configPath)
-		fmt.Printf("Current configuration:\n")
-		fmt.Printf("  Dotfiles Directory: %s\n", cfg.DotfilesDir)
-		fmt.Printf("  Symlinks File:      %s\n", cfg.SymlinksFile)
-		fmt.Printf("  Operating System:   %s\n", cfg.OS)
-		fmt.Printf("  Language:           %s\n", cfg.Language)
-		fmt.Printf("  Verbose:            %v\n", cfg.Verbose)
-		fmt.Printf("  Dry Run:            %v\n", cfg.DryRun)
+		cfg, _ := contextConfig(cmd)
+		printConfig(cfg, nil)
 	},
 }
 
+// showOrigin makes config show print where each value came from
+var showOrigin bool
+
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show all configuration values",
-	Long:  `This command will display all current configuration values.`,
+	Long: `This command will display all current configuration values.
+
+With --origin it also shows where each value came from: the defaults, the
+system file (/etc/sokru/config.yaml), the user file, a SOKRU_* environment
+variable, the --config file or a command-line flag.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
-			os.Exit(1)
+		if !showOrigin {
+			cfg, _ := contextConfig(cmd)
+			printConfig(cfg, nil)
+			return
 		}
 
-		configPath, _ := config.GetConfigPath()
-		fmt.Printf("Configuration file: %s\n\n", configPath)
-		fmt.Printf("Current configuration:\n")
-		fmt.Printf("  Dotfiles Directory: %s\n", cfg.DotfilesDir)
-		fmt.Printf("  Symlinks File:      %s\n", cfg.SymlinksFile)
-		fmt.Printf("  Operating System:   %s\n", cfg.OS)
-		fmt.Printf("  Language:           %s\n", cfg.Language)
-		fmt.Printf("  Verbose:            %v\n", cfg.Verbose)
-		fmt.Printf("  Dry Run:            %v\n", cfg.DryRun)
+		cfg, origins := contextConfig(cmd)
+		printConfig(cfg, origins)
 	},
 }
 
-var configDotDirCmd = &cobra.Command{
-	Use:   "dotdir [path]",
-	Short: "Set the directory where the dotfiles are located",
-	Long:  `This command will allow you to set the directory where the dotfiles are located.`,
-	Args:  cobra.MaximumNArgs(1),
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a configuration key",
+	Long: `This command will print the value in use of a configuration key, like
+dotfiles_dir, backup.max_backups or variables.email.
+
+Run sok config list to see every key.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
+		cfg, _ := contextConfig(cmd)
+		value, err := config.Get(cfg, args[0])
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println(value)
+	},
+}
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current dotfiles directory: %s\n", cfg.DotfilesDir)
-			return
-		}
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a configuration key in the user config file",
+	Long: `This command will check a value against the type of the key and save it in
+the user config file, keeping the rest of the file as it is.
 
-		// Expand path and update value
-		expandedPath := expandPath(args[0])
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.DotfilesDir = expandedPath
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Dotfiles directory set to: %s\n", expandedPath)
+Entries of maps are set one by one, like variables.email.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setKey(cmd, args[0], args[1])
 	},
 }
 
-var configSymlinkFileCmd = &cobra.Command{
-	Use:   "symlinkfile [path]",
-	Short: "Set the file where the symlinks are located",
-	Long:  `This command will allow you to set the file where the symlinks are located.`,
-	Args:  cobra.MaximumNArgs(1),
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a configuration key from the user config file",
+	Long: `This command will remove a key from the user config file, so its value comes
+from the defaults, the system file or the environment again.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+		cfg, _ := contextConfig(cmd)
+		if err := config.UnsetKey(cfg.Home, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
 			os.Exit(1)
 		}
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current symlinks file: %s\n", cfg.SymlinksFile)
-			return
-		}
-
-		// Expand path and update value
-		expandedPath := expandPath(args[0])
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.SymlinksFile = expandedPath
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
+		// The value in use comes from the other layers now
+		cfg, origins := resolveConfig()
+		if value, err := config.Get(cfg, args[0]); err == nil {
+			fmt.Printf("%s unset, now %s (%s)\n", args[0], value, origins[args[0]])
+		} else {
+			fmt.Printf("%s unset\n", args[0])
 		}
-		fmt.Printf("Symlinks file set to: %s\n", expandedPath)
 	},
 }
 
-var configVerboseCmd = &cobra.Command{
-	Use:   "verbose [true|false]",
-	Short: "Set the verbosity of the output",
-	Long:  `This command will allow you to set the verbosity of the output.`,
-	Args:  cobra.MaximumNArgs(1),
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configuration keys",
+	Long:  `This command will list every configuration key with its type, its value in use and what it does.`,
+	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
-			os.Exit(1)
-		}
+		cfg, _ := contextConfig(cmd)
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current verbose setting: %v\n", cfg.Verbose)
-			return
-		}
-
-		// Parse and update value
-		verbose, err := strconv.ParseBool(args[0])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Invalid boolean value. Use 'true' or 'false'\n")
-			os.Exit(1)
-		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range config.Keys() {
+			typ := key.Type
+			if len(key.Enum) > 0 {
+				typ = strings.Join(key.Enum, "|")
+			}
 
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.Verbose = verbose
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
+			if key.Type != "map" {
+				value, _ := config.Get(cfg, key.Name)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Name, typ, value, key.Help)
+				continue
+			}
+			fmt.Fprintf(w, "%s.<name>\t%s\t\t%s\n", key.Name, "string", key.Help)
+			for _, name := range config.Entries(cfg, key) {
+				value, _ := config.Get(cfg, name)
+				fmt.Fprintf(w, "%s\t%s\t%s\t\n", name, "string", value)
+			}
 		}
-		fmt.Printf("Verbose mode set to: %v\n", verbose)
+		w.Flush()
 	},
 }
 
-var configDryrunCmd = &cobra.Command{
-	Use:   "dryrun [true|false]",
-	Short: "Set the dry run mode",
-	Long:  `This command will allow you to set the dry run mode.`,
-	Args:  cobra.MaximumNArgs(1),
+// migrateDryRun makes config migrate only show the changes. It shadows the
+// global --dry-run, so a dry_run key in the config does not apply.
+var migrateDryRun bool
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [file]",
+	Short: "Upgrade a config file to the current version",
+	Long: `This command will upgrade the user config file, or the given one, to the
+current version of the format. The original is kept next to it as
+<file>.v<version>-<time>.bak.
+
+With --dry-run it shows the changes without writing them. Old files are
+read correctly without migrating them, and sok migrates the user file when it
+changes it.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
+		cfg, _ := contextConfig(cmd)
+
+		configPath, _ := config.GetConfigPath(cfg.Home)
+		if len(args) > 0 {
+			configPath = expandPath(args[0])
+		}
+
+		result, err := config.Migrate(cfg.Home, configPath, migrateDryRun)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current dry-run setting: %v\n", cfg.DryRun)
+		if len(result.Applied) == 0 {
+			fmt.Printf("%s is already at version %d\n", configPath, result.To)
 			return
 		}
 
-		// Parse and update value
-		dryRun, err := strconv.ParseBool(args[0])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Invalid boolean value. Use 'true' or 'false'\n")
-			os.Exit(1)
+		fmt.Printf("Migrating %s from version %d to %d:\n", configPath, result.From, result.To)
+		for _, migration := range result.Applied {
+			fmt.Printf("  - %d to %d: %s\n", migration.From, migration.From+1, migration.Description)
 		}
 
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.DryRun = dryRun
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
+		if migrateDryRun {
+			fmt.Println()
+			fmt.Print(render.Diff(result.Original, result.Migrated))
+			fmt.Println("\nDry run, nothing was written")
+			return
 		}
-		fmt.Printf("Dry-run mode set to: %v\n", dryRun)
+		fmt.Printf("Original kept in %s\n", result.Backup)
 	},
 }
 
-var configOsCmd = &cobra.Command{
-	Use:   "os [operating-system]",
-	Short: "Set the operating system",
-	Long:  `This command will allow you to set the operating system (e.g., linux, darwin, windows).`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
-			os.Exit(1)
-		}
+// keyAlias returns a command that prints a key without arguments and sets it
+// with one, a shortcut kept from before sok config get and set
+func keyAlias(use, key, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  fmt.Sprintf("This command will print %[1]s, or set it like sok config set %[1]s <value>.", key),
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cfg, _ := contextConfig(cmd)
+				value, _ := config.Get(cfg, key)
+				fmt.Printf("Current %s: %s\n", key, value)
+				return
+			}
+			setKey(cmd, key, args[0])
+		},
+	}
+}
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current OS setting: %s\n", cfg.OS)
-			return
-		}
+// setKey saves a key in the user config file of the configuration of cmd and
+// warns when a later layer overrides it
+func setKey(cmd *cobra.Command, name, text string) {
+	cfg, origins := contextConfig(cmd)
+	value, err := config.SetKey(cfg.Home, name, text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s set to: %s\n", name, value)
 
-		// Validate OS
-		osName := strings.ToLower(args[0])
-		if !validateOS(osName) {
-			fmt.Fprintf(os.Stderr, "Error: Invalid OS '%s'. Valid options are: linux, darwin, windows\n", args[0])
-			os.Exit(1)
-		}
+	// The environment, the --config file and the flags come after the user file
+	origin := origins[name]
+	if strings.HasPrefix(origin, "SOKRU_") || strings.HasPrefix(origin, "--") || (origin != "" && origin == configOptions().File) {
+		fmt.Printf("Note: %s overrides it\n", origin)
+	}
+}
 
-		// Update value
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.OS = osName
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("OS set to: %s\n", osName)
-	},
+// contextConfig returns the configuration cmd runs with and the origin of its
+// values
+func contextConfig(cmd *cobra.Command) (*config.Config, config.Origins) {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, config.OriginsFromContext(cmd.Context())
 }
 
-var configLanguageCmd = &cobra.Command{
-	Use:   "language [lang]",
-	Short: "Set the language (en, es)",
-	Long:  `This command will allow you to set the language (e.g., en for English, es for Spanish).`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
-			os.Exit(1)
-		}
+// resolveConfig resolves the configuration and the origin of its values again,
+// with the --home dir, flags and --config file of the command line
+func resolveConfig() (*config.Config, config.Origins) {
+	cfg, origins, err := config.Resolve(configOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, origins
+}
 
-		if len(args) == 0 {
-			// Display current value
-			fmt.Printf("Current language setting: %s\n", cfg.Language)
-			return
-		}
+// printConfig prints the configuration values, followed by their origin
+// when origins is not nil
+func printConfig(cfg *config.Config, origins config.Origins) {
+	configPath, _ := config.GetConfigPath(cfg.Home)
+	fmt.Printf("Configuration file: %s\n\n", configPath)
+	fmt.Printf("Current configuration:\n")
 
-		// Validate language
-		lang := strings.ToLower(args[0])
-		if lang != "en" && lang != "es" {
-			fmt.Fprintf(os.Stderr, "Error: Invalid language '%s'. Valid options are: en, es\n", args[0])
-			os.Exit(1)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	line := func(name string) {
+		value, _ := config.Get(cfg, name)
+		if origins == nil {
+			fmt.Fprintf(w, "  %s:\t%s\n", name, value)
+		} else {
+			fmt.Fprintf(w, "  %s:\t%s\t(%s)\n", name, value, origins[name])
 		}
-
-		// Update value
-		err = config.UpdateConfig(func(c *config.Config) {
-			c.Language = lang
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
-			os.Exit(1)
+	}
+	for _, key := range config.Keys() {
+		if key.Type != "map" {
+			line(key.Name)
+			continue
 		}
-		fmt.Printf("Language set to: %s\n", lang)
-	},
+		for _, name := range config.Entries(cfg, key) {
+			line(name)
+		}
+	}
+	w.Flush()
 }
 
 var configHelpCmd = &cobra.Command{
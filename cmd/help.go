@@ -37,16 +37,24 @@ func HelpSymlinksFunc(cmd *cobra.Command, args []string) {
 	fmt.Println("sok symlinks install        # Install the symlinks")
 	fmt.Println("sok symlinks uninstall      # Uninstall the symlinks")
 	fmt.Println("sok symlinks list           # List the symlinks")
+	fmt.Println("sok symlinks pull [target]  # Pull local changes of copied targets into the dotfiles")
 	fmt.Println("sok symlinks help           # Show this help")
 }
 
 func HelpConfigFunc(cmd *cobra.Command, args []string) {
 	fmt.Println("Config command help::")
-	fmt.Println("sok config dotDir <dir>     # Set the directory where the dotfiles are stored (default: ~/.dotfiles)")
-	fmt.Println("sok config symlinks <file>  # Set the file that contains the symlinks (default: ~/.dotfiles/symlinks.yml)")
-	fmt.Println("sok config os <os>          # Set the OS to use (default: linux)")
-	fmt.Println("sok config verbose <bool>   # Set the verbose mode (default: false)")
-	fmt.Println("sok config dryRun <bool>    # Set the dry run mode (default: false)")
+	fmt.Println("sok config show [--origin]  # Show the configuration and where each value came from")
+	fmt.Println("sok config list             # List every key with its type, value and description")
+	fmt.Println("sok config get <key>        # Print the value of a key (e.g. backup.max_backups)")
+	fmt.Println("sok config set <key> <val>  # Set a key in the user config file")
+	fmt.Println("sok config unset <key>      # Remove a key from the user config file")
+	fmt.Println("sok config migrate [file]   # Upgrade a config file to the current version")
+	fmt.Println("sok config dotdir [dir]     # Shortcut for dotfiles_dir (default: ~/dotfiles)")
+	fmt.Println("sok config symlinkfile [f]  # Shortcut for symlinks_file")
+	fmt.Println("sok config os [os]          # Shortcut for os (linux, darwin, windows)")
+	fmt.Println("sok config language [lang]  # Shortcut for language (en, es)")
+	fmt.Println("sok config verbose [bool]   # Shortcut for verbose (default: false)")
+	fmt.Println("sok config dryrun [bool]    # Shortcut for dry_run (default: false)")
 	fmt.Println("sok config help             # Show this help")
 }
 
@@ -54,9 +62,12 @@ func HelpFunc(cmd *cobra.Command, args []string) {
 	fmt.Println("Sokru help::")
 	fmt.Println("sok init                    # Initialize the configuration")
 	fmt.Println("sok apply                   # Apply the changes in memory and reload the symlinks and dotfiles")
+	fmt.Println("sok validate                # Check the symlinks file for mistakes")
+	fmt.Println("sok schema <symlinks|config> # Print the JSON Schema of a configuration file")
 	fmt.Println("sok version                 # Show the version")
 	fmt.Println("sok config                  # Show the configuration options")
 	fmt.Println("sok symlinks                # Show the symlinks options")
+	fmt.Println("sok profile                 # Show or select the active profile")
 	fmt.Println("sok help                    # Show this help")
 }
 
@@ -17,6 +17,8 @@ const symlinkTemplate = `# Sokru Symlinks Configuration
 # Format:
 # - link:
 #     ~/target/path: ~/.dotfiles/source/file
+#
+# Relative sources are resolved against the dotfiles directory.
 
 # Examples (uncomment to use):
 # - link:
@@ -33,7 +35,7 @@ var initCmd = &cobra.Command{
 	This command will create the necessary files and directories to start using sokru.
 
 	This will create the following files and directories:
-	- .config/sokru/
+	- .config/sokru/ (or $XDG_CONFIG_HOME/sokru/)
 	- .config/sokru/config.yaml
 	- .dotfiles/
 	- .dotfiles/symlinks.yaml
@@ -58,8 +60,17 @@ func InitFunc(cmd *cobra.Command, args []string) {
 	}
 
 	// Paths
-	sokruDir := filepath.Join(homeDir, ".config", "sokru")
-	configFile := filepath.Join(sokruDir, "config.yaml")
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	configFile, err := config.GetConfigPath(cfg.Home)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sokruDir := filepath.Dir(configFile)
 	dotfilesDir := filepath.Join(homeDir, "dotfiles")
 	symlinkFile := filepath.Join(dotfilesDir, "symlinks.yml")
 
@@ -67,7 +78,7 @@ func InitFunc(cmd *cobra.Command, args []string) {
 	var created []string
 	var skipped []string
 
-	// 1. Create ~/.config/sokru/ directory
+	// 1. Create the config directory, ~/.config/sokru/ by default
 	if _, err := os.Stat(sokruDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(sokruDir, 0755); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Failed to create %s: %v\n", sokruDir, err)
@@ -80,7 +91,7 @@ func InitFunc(cmd *cobra.Command, args []string) {
 		fmt.Printf("⚠ Directory already exists: %s\n", sokruDir)
 	}
 
-	// 2. Create ~/.config/sokru/config.yaml with defaults
+	// 2. Create config.yaml with defaults
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
 		cfg := config.GetDefaultConfig()
 		// Update paths to use the dotfiles directory we're creating
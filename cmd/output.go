@@ -0,0 +1,56 @@
+// Package cmd
+// Description: This file contains the --output flag, which selects between text for people and a JSON lines event stream for programs.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
+)
+
+// outputFormat is how commands that change links report what they do
+var outputFormat string
+
+// jsonOutput reports whether events are written as JSON lines instead of
+// text
+func jsonOutput() bool {
+	return outputFormat == "json"
+}
+
+// observer returns the observer of a command: printer, which prints text,
+// or a JSON lines stream of the events on stdout with --output json
+func observer(printer sokru.ObserverFunc) sokru.Observer {
+	if jsonOutput() {
+		return sokru.NewJSONObserver(os.Stdout)
+	}
+	return printer
+}
+
+// messageOutput is where messages outside the events go: stdout for text,
+// stderr when stdout carries JSON
+func messageOutput() io.Writer {
+	if jsonOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// checkOutputFormat refuses unknown output formats
+func checkOutputFormat(cmd *cobra.Command, args []string) {
+	if outputFormat != "text" && outputFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Error: Unknown output format %q, use text or json\n", outputFormat)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	for _, command := range []*cobra.Command{installCmd, uninstallCmd, applyCmd, pullCmd} {
+		command.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format: text, or json for one JSON object per event")
+		command.PreRun = checkOutputFormat
+	}
+}
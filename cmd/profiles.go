@@ -0,0 +1,209 @@
+// Package cmd
+// Description: This file contains the profile command and the selection of symlinks.yaml entries by profile.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
+)
+
+// readSymlinkConfigs reads the entries of the configured symlinks file
+func readSymlinkConfigs(cfg *config.Config) ([]sokru.SymlinkConfig, string, error) {
+	symlinkFile := expandPath(cfg.SymlinksFile)
+	symlinkConfigs, err := sokru.LoadSymlinkConfigs(symlinkFile)
+	return symlinkConfigs, symlinkFile, err
+}
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage the active profile",
+	Long: `This command will allow you to select which profile of symlinks.yaml is used
+on this machine. Entries tagged with a profile are only installed when that
+profile, or one extending it, is active.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.FromContext(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+
+		if cfg.Profile == "" {
+			fmt.Println("No active profile")
+			return
+		}
+		fmt.Printf("Active profile: %s\n", cfg.Profile)
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Set the active profile",
+	Long:  `This command will set the active profile. Use "none" to only keep untagged entries.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.FromContext(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+
+		profile := args[0]
+		if profile == "none" {
+			profile = ""
+		} else {
+			symlinkConfigs, _, err := readSymlinkConfigs(cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			profiles, err := sokru.CollectProfiles(symlinkConfigs)
+			if err == nil {
+				_, err = sokru.ProfileChain(profiles, profile)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		err = config.UpdateConfig(cfg.Home, func(c *config.Config) {
+			c.Profile = profile
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to update config: %v\n", err)
+			os.Exit(1)
+		}
+
+		if profile == "" {
+			fmt.Println("Active profile cleared")
+			return
+		}
+		fmt.Printf("Active profile set to: %s\n", profile)
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles defined in the symlinks file",
+	Long:  `This command will list the profiles of symlinks.yaml, marking the active one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.FromContext(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+
+		symlinkConfigs, symlinkFile, err := readSymlinkConfigs(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		profiles, err := sokru.CollectProfiles(symlinkConfigs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(profiles) == 0 {
+			fmt.Printf("No profiles defined in %s\n", symlinkFile)
+			return
+		}
+
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			marker := " "
+			if name == cfg.Profile {
+				marker = "*"
+			}
+
+			line := fmt.Sprintf("%s %-16s", marker, name)
+			if spec := profiles[name]; len(spec.Extends) > 0 {
+				line += fmt.Sprintf(" extends %s", strings.Join(spec.Extends, ", "))
+			}
+			if description := profiles[name].Description; description != "" {
+				line += fmt.Sprintf("  # %s", description)
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	},
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "Show the links of a profile",
+	Long:  `This command will show the inheritance and the links of a profile, the active one by default.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.FromContext(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+
+		profile := cfg.Profile
+		if len(args) > 0 {
+			profile = args[0]
+		}
+
+		symlinkConfigs, symlinkFile, err := readSymlinkConfigs(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if profile == "" {
+			fmt.Println("Profile: (none)")
+		} else {
+			profiles, err := sokru.CollectProfiles(symlinkConfigs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			chain, err := sokru.ProfileChain(profiles, profile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Profile: %s\n", strings.Join(chain, " -> "))
+		}
+
+		selected, err := sokru.SelectProfile(symlinkConfigs, profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := sokru.NewResolveOptions(cfg, symlinkFile)
+		plan, err := sokru.PlanLinks(selected, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Links (%d):\n", len(plan))
+		for _, link := range plan {
+			fmt.Printf("  %s -> %s\n", link.Target, link.Source)
+		}
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileShowCmd)
+	rootCmd.AddCommand(profileCmd)
+}
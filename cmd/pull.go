@@ -0,0 +1,112 @@
+// Package cmd
+// Description: This file contains the pull command, which copies local edits of copied targets back into the dotfiles.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
+)
+
+// pullYes skips the confirmation prompt
+var pullYes bool
+
+// pullCmd represents the pull command
+var pullCmd = &cobra.Command{
+	Use:   "pull [target...]",
+	Short: "Pull local changes of copied targets into the dotfiles",
+	Long: `This command copies targets in copy or hardlink mode that were edited locally
+back into their sources. Without arguments every drifted target is offered.`,
+	Run: PullSymlinksFunc,
+}
+
+func PullSymlinksFunc(cmd *cobra.Command, args []string) {
+	lock := lockConfig(cmd)
+	defer lock.Release()
+
+	// Get configuration
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
+	}
+
+	// Ask before each target unless --yes is given
+	opts := sokru.PullOptions{Targets: args, Lock: lock}
+	if !pullYes {
+		reader := bufio.NewReader(cmd.InOrStdin())
+		opts.Confirm = func(link sokru.Link) bool {
+			return confirm(reader, i18n.T(i18n.MsgPullConfirm, link.Target, link.Source))
+		}
+	}
+
+	opts.Observer = observer(func(event sokru.Event) {
+		if event.Type != sokru.EventChange {
+			printEvent(event, cfg.Verbose)
+			return
+		}
+
+		change := *event.Change
+		link := change.Link
+		switch {
+		case change.Kind == sokru.ChangePulled && event.DryRun:
+			fmt.Println(i18n.Info(i18n.MsgDryRunWouldPull, link.Target, link.Source))
+		case change.Kind == sokru.ChangePulled:
+			fmt.Println(i18n.Success(i18n.MsgPulled, link.Target, link.Source))
+		case errors.Is(change.Err, sokru.ErrRenderedTarget):
+			// Rendered output would be overwritten on the next render
+			log.Printf("%s", i18n.Warning(i18n.MsgCannotPullTemplate, link.Target, link.Template))
+		case change.Err != nil:
+			log.Printf("%s", errorMessage(change.Err))
+		}
+	})
+
+	result, err := sokru.Pull(cfg, opts)
+	if err != nil {
+		os.Exit(1)
+	}
+	if jsonOutput() {
+		return
+	}
+
+	pulled := result.Count(sokru.ChangePulled)
+	if result.DryRun {
+		pulled = 0
+	}
+	if pulled == 0 && !cfg.DryRun {
+		fmt.Println(i18n.Info(i18n.MsgNoLocalChanges))
+		return
+	}
+	fmt.Println(i18n.Info(i18n.MsgPullSummary, pulled))
+}
+
+// confirm prints prompt and reports whether the answer was affirmative
+func confirm(reader *bufio.Reader, prompt string) bool {
+	fmt.Fprint(messageOutput(), prompt)
+
+	answer, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "s", "si", "sí":
+		return true
+	}
+	return false
+}
+
+func init() {
+	pullCmd.Flags().BoolVarP(&pullYes, "yes", "y", false, "Pull without asking for confirmation")
+	symlinksCmd.AddCommand(pullCmd)
+}
@@ -0,0 +1,148 @@
+// Package cmd
+// Description: This file contains the helpers that print the errors and issues of the operations of the sokru package.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// errorMessage returns the message of a failed operation
+func errorMessage(err error) string {
+	var opErr *sokru.Error
+	if !errors.As(err, &opErr) {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	var target, source string
+	if opErr.Link != nil {
+		target, source = opErr.Link.Target, opErr.Link.Source
+	}
+
+	switch opErr.Op {
+	case sokru.OpLoad:
+		if errors.Is(err, sokru.ErrSymlinksFileNotFound) {
+			return i18n.Error(i18n.MsgSymlinkFileNotFound, opErr.Path)
+		}
+		return i18n.Error(i18n.MsgErrorLoadingSymlinks, opErr.Err)
+	case sokru.OpResolve:
+		return i18n.Error(i18n.MsgErrorResolvingLinks, opErr.Err)
+	case sokru.OpState:
+		return i18n.Error(i18n.MsgErrorLoadingState, opErr.Err)
+	case sokru.OpSaveState:
+		return i18n.Error(i18n.MsgErrorSavingState, opErr.Err)
+	case sokru.OpBackupDir:
+		return i18n.Error(i18n.MsgErrorGettingBackupDir, opErr.Err)
+	case sokru.OpLock:
+		return i18n.Error(i18n.MsgErrorLocking, opErr.Err)
+	case sokru.OpRender:
+		return i18n.Error(i18n.MsgErrorRenderingTemplate, opErr.Err)
+	case sokru.OpCheck:
+		return i18n.Error(i18n.MsgErrorCheckingFile, opErr.Path, opErr.Err)
+	case sokru.OpReadLink:
+		return i18n.Error(i18n.MsgErrorReadingSymlink, opErr.Path, opErr.Err)
+	case sokru.OpCopy:
+		return i18n.Error(i18n.MsgErrorCopying, target, source, opErr.Err)
+	case sokru.OpPull:
+		return i18n.Error(i18n.MsgErrorCopying, source, target, opErr.Err)
+	case sokru.OpRemove:
+		return i18n.Error(i18n.MsgErrorRemovingSymlink, opErr.Path, opErr.Err)
+	case sokru.OpLink:
+		return i18n.Error(i18n.MsgErrorCreatingSymlink, target, source, opErr.Err)
+	case sokru.OpSaveBackup:
+		return i18n.Warning(i18n.MsgBackupFailed, opErr.Err)
+	case sokru.OpPrune:
+		return i18n.Warning(i18n.MsgErrorPruningBackups, opErr.Err)
+	}
+	return fmt.Sprintf("Error: %v", err)
+}
+
+// printEvent prints the failures of an operation and the rollback of the
+// changes it made before failing
+func printEvent(event sokru.Event, verbose bool) {
+	switch event.Type {
+	case sokru.EventError:
+		printError(event.Err, verbose)
+	case sokru.EventRollback:
+		fmt.Println()
+		fmt.Println(i18n.Warning(i18n.MsgRollbackStarting, event.Count))
+	case sokru.EventRolledBack:
+		if event.Err != nil {
+			log.Printf("%s", i18n.Error(i18n.MsgRollbackFailed, event.Err))
+		} else {
+			fmt.Println(i18n.Success(i18n.MsgRollbackComplete))
+		}
+	}
+}
+
+// printError prints the failure of an operation. Issues that stopped it are
+// printed with the verbose warnings.
+func printError(err error, verbose bool) {
+	var opErr *sokru.Error
+	var issues sokru.Issues
+	if errors.As(err, &opErr) && errors.As(err, &issues) {
+		printIssues(opErr.Op, issues, verbose)
+		return
+	}
+	log.Printf("%s", errorMessage(err))
+}
+
+// failed reports whether err stops a command. Targets are in place even when
+// their state could not be saved, so that is only a warning.
+func failed(err error) bool {
+	var opErr *sokru.Error
+	return err != nil && !(errors.As(err, &opErr) && opErr.Op == sokru.OpSaveState)
+}
+
+// printIssues prints the issues that stopped an operation: the errors
+// validating the symlinks file, or the targets that would be written
+// through another link
+func printIssues(op sokru.Op, issues sokru.Issues, verbose bool) {
+	if op == sokru.OpOverlap {
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stderr, issue)
+		}
+		fmt.Fprintln(os.Stderr, i18n.Error(i18n.MsgOverlappingTargets, len(issues)))
+		return
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		if !issue.Warning {
+			errorCount++
+			fmt.Fprintln(os.Stderr, issue)
+		} else if verbose {
+			fmt.Fprintln(os.Stderr, issue)
+		}
+	}
+	fmt.Fprintln(os.Stderr, i18n.Error(i18n.MsgValidationFailed, errorCount))
+}
+
+// printWarnings prints the warnings found validating the symlinks file
+func printWarnings(issues []sokru.Issue, verbose bool) {
+	if !verbose {
+		return
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+}
+
+// printDiff prints the change rendering a template makes, indented under
+// the line announcing it
+func printDiff(diff string) {
+	if diff == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
@@ -5,13 +5,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"sort"
 	"time"
 
-	"github.com/alexlm78/sokru/internal/backup"
+	"github.com/alexlm78/sokru/internal/config"
 	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/sokru"
 	"github.com/spf13/cobra"
 )
 
@@ -52,15 +53,12 @@ var restoreDeleteCmd = &cobra.Command{
 }
 
 func RestoreListFunc(cmd *cobra.Command, args []string) {
-	backupDir, err := backup.GetDefaultBackupDir()
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorGettingBackupDir, err))
-	}
+	cfg := backupConfig(cmd)
 
-	manager := backup.NewManager(backupDir)
-	backups, err := manager.ListBackups()
+	// Backups are listed newest first
+	backups, err := sokru.Backups(cfg, sokru.BackupOptions{})
 	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorListingBackups, err))
+		log.Fatalf("%s", backupErrorMessage(err, i18n.MsgErrorListingBackups))
 	}
 
 	if len(backups) == 0 {
@@ -68,11 +66,6 @@ func RestoreListFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	// Sort backups by timestamp (newest first)
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].Timestamp.After(backups[j].Timestamp)
-	})
-
 	fmt.Println(i18n.T(i18n.MsgAvailableBackups))
 	fmt.Println("================")
 	fmt.Println()
@@ -90,18 +83,15 @@ func RestoreListFunc(cmd *cobra.Command, args []string) {
 
 func RestoreApplyFunc(cmd *cobra.Command, args []string) {
 	backupID := args[0]
+	cfg := backupConfig(cmd)
 
-	backupDir, err := backup.GetDefaultBackupDir()
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorGettingBackupDir, err))
-	}
-
-	manager := backup.NewManager(backupDir)
+	lock := lockConfig(cmd)
+	defer lock.Release()
 
 	// Load metadata to show what will be restored
-	metadata, err := manager.LoadMetadata(backupID)
+	metadata, err := sokru.LoadBackup(cfg, backupID, sokru.BackupOptions{})
 	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingBackup, backupID, err))
+		log.Fatalf("%s", backupErrorMessage(err, i18n.MsgErrorLoadingBackup, backupID))
 	}
 
 	fmt.Printf("%s: %s\n", i18n.T(i18n.MsgRestoringBackup), backupID)
@@ -121,8 +111,8 @@ func RestoreApplyFunc(cmd *cobra.Command, args []string) {
 
 	// Perform restore
 	fmt.Println(i18n.Info(i18n.MsgRestoringFiles))
-	if err := manager.RestoreBackup(backupID); err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgRestoreFailed, err))
+	if err := sokru.Restore(cfg, backupID, sokru.BackupOptions{Lock: lock}); err != nil {
+		log.Fatalf("%s", backupErrorMessage(err, i18n.MsgRestoreFailed))
 	}
 
 	fmt.Println(i18n.Success(i18n.MsgRestoreComplete))
@@ -130,18 +120,15 @@ func RestoreApplyFunc(cmd *cobra.Command, args []string) {
 
 func RestoreDeleteFunc(cmd *cobra.Command, args []string) {
 	backupID := args[0]
+	cfg := backupConfig(cmd)
 
-	backupDir, err := backup.GetDefaultBackupDir()
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorGettingBackupDir, err))
-	}
-
-	manager := backup.NewManager(backupDir)
+	lock := lockConfig(cmd)
+	defer lock.Release()
 
 	// Load metadata to show what will be deleted
-	metadata, err := manager.LoadMetadata(backupID)
+	metadata, err := sokru.LoadBackup(cfg, backupID, sokru.BackupOptions{})
 	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingBackup, backupID, err))
+		log.Fatalf("%s", backupErrorMessage(err, i18n.MsgErrorLoadingBackup, backupID))
 	}
 
 	fmt.Printf("%s: %s\n", i18n.T(i18n.MsgDeletingBackup), backupID)
@@ -149,13 +136,32 @@ func RestoreDeleteFunc(cmd *cobra.Command, args []string) {
 	fmt.Printf("%s: %d\n\n", i18n.T(i18n.MsgFiles), len(metadata.Entries))
 
 	// Delete backup
-	if err := manager.DeleteBackup(backupID); err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorDeletingBackup, err))
+	if err := sokru.DeleteBackup(cfg, backupID, sokru.BackupOptions{Lock: lock}); err != nil {
+		log.Fatalf("%s", backupErrorMessage(err, i18n.MsgErrorDeletingBackup))
 	}
 
 	fmt.Println(i18n.Success(i18n.MsgBackupDeleted, backupID))
 }
 
+// backupConfig returns the configuration whose backups cmd handles
+func backupConfig(cmd *cobra.Command) *config.Config {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
+	}
+	return cfg
+}
+
+// backupErrorMessage returns the message of a failed backup operation, key
+// with args and the cause when the backup itself could not be handled
+func backupErrorMessage(err error, key i18n.MessageKey, args ...interface{}) string {
+	var opErr *sokru.Error
+	if errors.As(err, &opErr) && opErr.Op == sokru.OpBackup {
+		return i18n.Error(key, append(args, opErr.Err)...)
+	}
+	return errorMessage(err)
+}
+
 func init() {
 	restoreCmd.AddCommand(restoreListCmd)
 	restoreCmd.AddCommand(restoreApplyCmd)
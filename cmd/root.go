@@ -5,8 +5,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/alexlm78/sokru/internal/config"
 	"github.com/alexlm78/sokru/internal/i18n"
@@ -26,20 +29,50 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(context.Background())
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// configFile is the config file given with --config
+var configFile string
+
+// homeDir holds every file of sok when given with --home
+var homeDir string
+
+// Values of the persistent flags, they only override the configuration when
+// they are set
+var (
+	verboseFlag bool
+	dryRunFlag  bool
+)
+
 func init() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
+	// Every command runs with the configuration resolved from its flags
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		cfg, origins := initConfig()
+		ctx := config.WithConfig(cmd.Context(), cfg)
+		cmd.SetContext(config.WithOrigins(ctx, origins))
+	}
+
+	// Set up persistent flags
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Read this config file on top of the system and user ones, e.g. per project.")
+	rootCmd.PersistentFlags().StringVar(&homeDir, "home", "", "Keep the config, state and backups of sok in this directory instead of the XDG ones.")
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Prints the details of the response such as protocol, status, and headers.")
+	rootCmd.PersistentFlags().BoolVar(&dryRunFlag, "dry-run", false, "Run in dry-run mode without making actual changes.")
+}
+
+// initConfig resolves the configuration, and where its values came from,
+// once the flags are parsed
+func initConfig() (*config.Config, config.Origins) {
+	opts := configOptions()
+	cfg, origins, err := config.Resolve(opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to load config: %v\n", err)
 		cfg = config.GetDefaultConfig()
+		cfg.Home = opts.Home
 	}
-	config.SetConfig(cfg)
 
 	// Initialize i18n with configured language
 	if cfg.Language == "es" {
@@ -47,10 +80,33 @@ func init() {
 	} else {
 		i18n.SetLanguage(i18n.English)
 	}
+	return cfg, origins
+}
 
-	// Set up persistent flags
-	rootCmd.PersistentFlags().BoolVarP(&cfg.Verbose, "verbose", "v", cfg.Verbose, "Prints the details of the response such as protocol, status, and headers.")
-	rootCmd.PersistentFlags().BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Run in dry-run mode without making actual changes.")
+// configOptions returns the --home dir, the --config file and the flags set
+// on the command line, the last layers of the configuration
+func configOptions() config.Options {
+	opts := config.Options{Flags: make(map[string]string)}
+	if homeDir != "" {
+		opts.Home = expandPath(homeDir)
+		if abs, err := filepath.Abs(opts.Home); err == nil {
+			opts.Home = abs
+		}
+	}
+	if configFile != "" {
+		opts.File = expandPath(configFile)
+		if abs, err := filepath.Abs(opts.File); err == nil {
+			opts.File = abs
+		}
+	}
+
+	for _, key := range config.Keys() {
+		flag := rootCmd.PersistentFlags().Lookup(strings.TrimPrefix(key.FlagName(), "--"))
+		if flag != nil && flag.Changed {
+			opts.Flags[key.Name] = flag.Value.String()
+		}
+	}
+	return opts
 }
 
 func valArguments(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,51 @@
+// Package cmd
+// Description: This file contains the schema command, which prints the JSON Schema of symlinks.yaml and config.yaml for editors.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema <symlinks|config>",
+	Short: "Print the JSON Schema of symlinks.yaml or config.yaml",
+	Long: `This command will print the JSON Schema of symlinks.yaml or config.yaml, so
+editors can complete and validate them. Save it next to the file and reference
+it with a yaml-language-server comment on the first line:
+
+  sok schema symlinks > symlinks.schema.json
+  # yaml-language-server: $schema=./symlinks.schema.json`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"symlinks", "config"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var s *sokru.Schema
+		switch args[0] {
+		case "symlinks":
+			s = sokru.SymlinksSchema()
+		case "config":
+			s = sokru.ConfigSchema()
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown schema %q, use symlinks or config\n", args[0])
+			os.Exit(1)
+		}
+
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
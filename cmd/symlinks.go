@@ -8,72 +8,13 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
-	"github.com/alexlm78/sokru/internal/backup"
 	"github.com/alexlm78/sokru/internal/config"
 	"github.com/alexlm78/sokru/internal/i18n"
-	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/pkg/sokru"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-type SymlinkConfig struct {
-	OS      string            `yaml:"os,omitempty"`
-	Link    map[string]string `yaml:"link"`
-	Common  map[string]string `yaml:"common,omitempty"`
-	Linux   map[string]string `yaml:"linux,omitempty"`
-	Darwin  map[string]string `yaml:"darwin,omitempty"`
-	Windows map[string]string `yaml:"windows,omitempty"`
-}
-
-// getLinksForOS returns the appropriate links based on the current OS
-func (sc *SymlinkConfig) getLinksForOS(currentOS string) map[string]string {
-	return sc.GetLinksForOS(currentOS)
-}
-
-// GetLinksForOS is exported for testing purposes
-func (sc *SymlinkConfig) GetLinksForOS(currentOS string) map[string]string {
-	links := make(map[string]string)
-
-	// If this is a legacy format (only "link" field), return it
-	if len(sc.Link) > 0 && sc.OS == "" {
-		return sc.Link
-	}
-
-	// If OS is specified and doesn't match, skip this entry
-	if sc.OS != "" && sc.OS != currentOS {
-		return links
-	}
-
-	// Add common links first (lowest priority)
-	for target, source := range sc.Common {
-		links[target] = source
-	}
-
-	// Add OS-specific links (higher priority, can override common)
-	var osLinks map[string]string
-	switch currentOS {
-	case "linux":
-		osLinks = sc.Linux
-	case "darwin":
-		osLinks = sc.Darwin
-	case "windows":
-		osLinks = sc.Windows
-	}
-
-	for target, source := range osLinks {
-		links[target] = source
-	}
-
-	// Legacy "link" field has highest priority
-	for target, source := range sc.Link {
-		links[target] = source
-	}
-
-	return links
-}
-
 // symlinksCmd represents the symlinks command
 var symlinksCmd = &cobra.Command{
 	Use:   "symlinks",
@@ -116,271 +57,165 @@ var symhelpCmd = &cobra.Command{
 	Run:   HelpSymlinksFunc,
 }
 
-func InstallSymlinksFunc(*cobra.Command, []string) {
+func InstallSymlinksFunc(cmd *cobra.Command, args []string) {
+	lock := lockConfig(cmd)
+	defer lock.Release()
+
 	// Get configuration
-	cfg, err := config.GetConfig()
+	cfg, err := config.FromContext(cmd.Context())
 	if err != nil {
 		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
 	}
 
-	// Expand path if it contains ~
-	symlinkFile := expandPath(cfg.SymlinksFile)
-
-	// Check if symlinks file exists
-	if _, err := os.Stat(symlinkFile); os.IsNotExist(err) {
-		log.Fatalf("%s", i18n.Error(i18n.MsgSymlinkFileNotFound, symlinkFile))
-	}
-
-	// Verbose output
-	if cfg.Verbose {
-		fmt.Println(i18n.Info(i18n.MsgReadingSymlinksFrom, symlinkFile))
-	}
-
-	// Read the YAML file
-	data, err := os.ReadFile(symlinkFile)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorReadingFile, err))
-	}
-
-	// Unmarshal YAML to struct
-	var symlinkConfigs []SymlinkConfig
-	err = yaml.Unmarshal(data, &symlinkConfigs)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorParsingYAML, err))
-	}
-
-	if cfg.Verbose {
-		fmt.Println(i18n.Info(i18n.MsgFoundConfigurations, len(symlinkConfigs)))
+	printer := func(event sokru.Event) {
+		switch event.Type {
+		case sokru.EventLoaded:
+			printWarnings(event.Issues, cfg.Verbose)
+			if cfg.Verbose {
+				fmt.Println(i18n.Info(i18n.MsgReadingSymlinksFrom, event.Path))
+				fmt.Println(i18n.Info(i18n.MsgFoundConfigurations, event.Count))
+			}
+		case sokru.EventChange:
+			printInstallChange(*event.Change, event.DryRun, cfg.Verbose)
+		case sokru.EventBackup:
+			if cfg.Verbose {
+				fmt.Println(i18n.Success(i18n.MsgBackupComplete, event.Backup.ID))
+			}
+		default:
+			printEvent(event, cfg.Verbose)
+		}
 	}
 
-	// Create backup manager and metadata
-	backupDir, err := backup.GetDefaultBackupDir()
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorGettingBackupDir, err))
+	result, err := sokru.Install(cfg, sokru.RunOptions{SkipValidation: skipValidation, Observer: observer(printer), Lock: lock})
+	if failed(err) {
+		os.Exit(1)
 	}
 
-	backupMgr := backup.NewManager(backupDir)
-	backupID := backup.GenerateBackupID()
-	backupMetadata := &backup.BackupMetadata{
-		ID:        backupID,
-		Timestamp: time.Now(),
-		Command:   "symlinks install",
-		Entries:   []backup.BackupEntry{},
+	if len(result.Pruned) > 0 && cfg.Verbose && !jsonOutput() {
+		fmt.Println(i18n.Info(i18n.MsgBackupsPruned, len(result.Pruned)))
 	}
+}
 
-	// Create rollback tracker
-	tracker := rollback.NewTracker()
-	var hasError bool
-
-	// Iterate over items and create symbolic links
-	for _, entry := range symlinkConfigs {
-		// Get links for current OS
-		links := entry.getLinksForOS(cfg.OS)
-
-		for target, source := range links {
-			targetPath := expandPath(target)
-			sourcePath := expandPath(source)
-
-			// Check if dry-run mode is enabled
-			if cfg.DryRun {
-				fmt.Println(i18n.Info(i18n.MsgDryRunWouldCreate, targetPath, sourcePath))
-				continue
-			}
-
-			// Check if file/symlink exists and create backup
-			if _, err := os.Lstat(targetPath); err == nil {
-				if cfg.Verbose {
-					fmt.Println(i18n.Info(i18n.MsgBackingUpFile, targetPath))
-				}
-
-				backupEntry, err := backupMgr.CreateBackup(targetPath, backupID)
-				if err != nil {
-					log.Printf("%s", i18n.Warning(i18n.MsgBackupFailed, err))
-					// Continue anyway - backup failure shouldn't stop installation
-				} else {
-					backupMetadata.Entries = append(backupMetadata.Entries, *backupEntry)
-				}
-			}
-
-			existingLink, err := os.Readlink(targetPath)
-			if err == nil {
-				if existingLink == sourcePath {
-					if cfg.Verbose {
-						fmt.Println(i18n.Success(i18n.MsgSymlinkAlreadyExists, targetPath, sourcePath))
-					}
-					continue
-				} else {
-					// Remove existing link if it points to a different destination
-					err = os.Remove(targetPath)
-					if err != nil {
-						log.Printf("%s", i18n.Error(i18n.MsgErrorRemovingSymlink, targetPath, err))
-						hasError = true
-						break
-					}
-					if cfg.Verbose {
-						fmt.Println(i18n.Success(i18n.MsgExistingSymlinkRemoved, targetPath))
-					}
-					// Track the update for rollback
-					tracker.TrackUpdated(targetPath, sourcePath, existingLink)
-				}
-			} else if !os.IsNotExist(err) {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCheckingFile, targetPath, err))
-				hasError = true
-				break
-			}
-
-			// Create the new symbolic link
-			err = os.Symlink(sourcePath, targetPath)
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCreatingSymlink, targetPath, sourcePath, err))
-				hasError = true
-				break
-			} else {
-				fmt.Println(i18n.Success(i18n.MsgSymlinkCreated, targetPath, sourcePath))
-				// Track creation only if it wasn't an update
-				if existingLink == "" || os.IsNotExist(err) {
-					tracker.TrackCreated(targetPath, sourcePath)
-				}
-			}
+// printInstallChange prints what install did to a target
+func printInstallChange(change sokru.Change, dryRun, verbose bool) {
+	link := change.Link
+
+	switch change.Kind {
+	case sokru.ChangeRendered:
+		if dryRun {
+			fmt.Println(i18n.Info(i18n.MsgDryRunWouldRender, link.Target, link.Template))
+			printDiff(change.Diff)
+		} else if verbose {
+			fmt.Println(i18n.Success(i18n.MsgTemplateRendered, link.Template, link.Source))
 		}
-
-		// If error occurred, break outer loop too
-		if hasError {
-			break
+	case sokru.ChangeBackedUp:
+		if verbose {
+			fmt.Println(i18n.Info(i18n.MsgBackingUpFile, link.Target))
 		}
-	}
-
-	// Perform rollback if there was an error
-	if hasError && tracker.HasActions() {
-		fmt.Println()
-		fmt.Println(i18n.Warning(i18n.MsgRollbackStarting, tracker.Count()))
-
-		if err := tracker.Rollback(); err != nil {
-			log.Printf("%s", i18n.Error(i18n.MsgRollbackFailed, err))
+		// A failed backup does not stop the installation
+		if change.Err != nil {
+			log.Printf("%s", i18n.Warning(i18n.MsgBackupFailed, change.Err))
+		}
+	case sokru.ChangeUnchanged:
+		if !verbose {
+			return
+		}
+		if link.Mode != sokru.ModeSymlink {
+			fmt.Println(i18n.Success(i18n.MsgCopyUpToDate, link.Target))
 		} else {
-			fmt.Println(i18n.Success(i18n.MsgRollbackComplete))
+			fmt.Println(i18n.Success(i18n.MsgSymlinkAlreadyExists, link.Target, link.Source))
 		}
-
-		os.Exit(1)
-	}
-
-	// Save backup metadata if we created any backups
-	if len(backupMetadata.Entries) > 0 {
-		if err := backupMgr.SaveMetadata(backupMetadata); err != nil {
-			log.Printf("%s", i18n.Warning(i18n.MsgBackupFailed, err))
-		} else if cfg.Verbose {
-			fmt.Println(i18n.Success(i18n.MsgBackupComplete, backupID))
+	case sokru.ChangeSkipped:
+		if change.Status == sokru.StatusBlocked {
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyUnmanaged, link.Target))
+		} else {
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyDrifted, link.Target))
+		}
+	case sokru.ChangeCreated, sokru.ChangeUpdated:
+		switch {
+		case link.Mode != sokru.ModeSymlink && dryRun:
+			fmt.Println(i18n.Info(i18n.MsgDryRunWouldCopy, link.Mode, link.Target, link.Source))
+		case link.Mode != sokru.ModeSymlink:
+			fmt.Println(i18n.Success(i18n.MsgCopied, link.Mode, link.Target, link.Source))
+		case dryRun:
+			fmt.Println(i18n.Info(i18n.MsgDryRunWouldCreate, link.Target, link.Source))
+		default:
+			if change.Kind == sokru.ChangeUpdated && verbose {
+				fmt.Println(i18n.Success(i18n.MsgExistingSymlinkRemoved, link.Target))
+			}
+			fmt.Println(i18n.Success(i18n.MsgSymlinkCreated, link.Target, link.Source))
 		}
 	}
 }
 
-func UninstallSymlinksFunc(*cobra.Command, []string) {
+func UninstallSymlinksFunc(cmd *cobra.Command, args []string) {
+	lock := lockConfig(cmd)
+	defer lock.Release()
+
 	// Get configuration
-	cfg, err := config.GetConfig()
+	cfg, err := config.FromContext(cmd.Context())
 	if err != nil {
 		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
 	}
 
-	// Expand path if it contains ~
-	symlinkFile := expandPath(cfg.SymlinksFile)
-
-	// Check if symlinks file exists
-	if _, err := os.Stat(symlinkFile); os.IsNotExist(err) {
-		log.Fatalf("%s", i18n.Error(i18n.MsgSymlinkFileNotFound, symlinkFile))
-	}
-
-	// Verbose output
-	if cfg.Verbose {
-		fmt.Println(i18n.Info(i18n.MsgReadingSymlinksFrom, symlinkFile))
-	}
-
-	// Read the YAML file
-	data, err := os.ReadFile(symlinkFile)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorReadingFile, err))
-	}
-
-	// Unmarshal YAML to struct
-	var symlinkConfigs []SymlinkConfig
-	err = yaml.Unmarshal(data, &symlinkConfigs)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorParsingYAML, err))
-	}
-
-	if cfg.Verbose {
-		fmt.Println(i18n.Info(i18n.MsgFoundConfigurations, len(symlinkConfigs)))
-	}
-
 	// Counters for summary
 	var removed, skipped, notFound, notSymlink int
 
-	// Iterate over items and remove symbolic links
-	for _, entry := range symlinkConfigs {
-		// Get links for current OS
-		links := entry.getLinksForOS(cfg.OS)
-
-		for target, source := range links {
-			targetPath := expandPath(target)
-			sourcePath := expandPath(source)
-
-			// Check if target exists
-			fileInfo, err := os.Lstat(targetPath)
-			if os.IsNotExist(err) {
-				if cfg.Verbose {
-					fmt.Println(i18n.Info(i18n.MsgSymlinkNotFound, targetPath))
-				}
-				notFound++
-				continue
-			}
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCheckingFile, targetPath, err))
-				skipped++
-				continue
-			}
-
-			// Check if it's a symlink
-			if fileInfo.Mode()&os.ModeSymlink == 0 {
-				log.Printf("%s", i18n.Warning(i18n.MsgNotSymlink, targetPath))
-				notSymlink++
-				continue
-			}
+	printer := func(event sokru.Event) {
+		if event.Type == sokru.EventLoaded && cfg.Verbose {
+			fmt.Println(i18n.Info(i18n.MsgReadingSymlinksFrom, event.Path))
+			fmt.Println(i18n.Info(i18n.MsgFoundConfigurations, event.Count))
+		}
+		if event.Type != sokru.EventChange {
+			printEvent(event, cfg.Verbose)
+			return
+		}
 
-			// Read the symlink to verify it points to the expected source
-			existingLink, err := os.Readlink(targetPath)
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorReadingSymlink, targetPath, err))
-				skipped++
-				continue
+		change := *event.Change
+		link := change.Link
+		switch {
+		case change.Kind == sokru.ChangeRemoved:
+			if event.DryRun {
+				fmt.Println(i18n.Info(i18n.MsgDryRunWouldRemove, link.Target, link.Source))
+			} else {
+				fmt.Println(i18n.Success(i18n.MsgSymlinkRemoved, link.Target, link.Source))
 			}
-
-			// Verify the symlink points to the expected source
-			if existingLink != sourcePath {
-				if cfg.Verbose {
-					fmt.Println(i18n.Warning(i18n.MsgSymlinkWrongTarget, targetPath, existingLink, sourcePath))
-				}
-				skipped++
-				continue
+			removed++
+		case change.Err != nil:
+			log.Printf("%s", errorMessage(change.Err))
+			skipped++
+		case change.Status == sokru.StatusMissing:
+			if cfg.Verbose {
+				fmt.Println(i18n.Info(i18n.MsgSymlinkNotFound, link.Target))
 			}
-
-			// Check if dry-run mode is enabled
-			if cfg.DryRun {
-				fmt.Println(i18n.Info(i18n.MsgDryRunWouldRemove, targetPath, sourcePath))
-				removed++
-				continue
-			}
-
-			// Remove the symlink
-			err = os.Remove(targetPath)
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorRemovingSymlink, targetPath, err))
-				skipped++
-			} else {
-				fmt.Println(i18n.Success(i18n.MsgSymlinkRemoved, targetPath, sourcePath))
-				removed++
+			notFound++
+		case change.Status == sokru.StatusDrifted:
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyDrifted, link.Target))
+			skipped++
+		case change.Status == sokru.StatusBlocked && link.Mode != sokru.ModeSymlink:
+			log.Printf("%s", i18n.Warning(i18n.MsgCopyUnmanaged, link.Target))
+			skipped++
+		case change.Status == sokru.StatusBlocked:
+			log.Printf("%s", i18n.Warning(i18n.MsgNotSymlink, link.Target))
+			notSymlink++
+		default:
+			// Symlinks pointing somewhere else
+			if cfg.Verbose {
+				fmt.Println(i18n.Warning(i18n.MsgSymlinkWrongTarget, link.Target, change.Previous, link.Source))
 			}
+			skipped++
 		}
 	}
 
+	// Removed copies may not have been forgotten, which only warns
+	_, err = sokru.Uninstall(cfg, sokru.RunOptions{Observer: observer(printer), Lock: lock})
+	if failed(err) {
+		os.Exit(1)
+	}
+	if jsonOutput() {
+		return
+	}
+
 	// Print summary
 	fmt.Printf("\n=== %s ===\n", i18n.T(i18n.MsgUninstallSummary))
 	if cfg.DryRun {
@@ -399,37 +234,21 @@ func UninstallSymlinksFunc(*cobra.Command, []string) {
 	}
 }
 
-func ListSymlinksFunc(*cobra.Command, []string) {
+func ListSymlinksFunc(cmd *cobra.Command, args []string) {
 	// Get configuration
-	cfg, err := config.GetConfig()
+	cfg, err := config.FromContext(cmd.Context())
 	if err != nil {
 		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
 	}
 
-	// Expand path if it contains ~
-	symlinkFile := expandPath(cfg.SymlinksFile)
-
-	// Check if symlinks file exists
-	if _, err := os.Stat(symlinkFile); os.IsNotExist(err) {
-		log.Fatalf("%s", i18n.Error(i18n.MsgSymlinkFileNotFound, symlinkFile))
+	report, err := sokru.CheckStatus(cfg, sokru.StatusOptions{})
+	if err != nil {
+		log.Fatalf("%s", errorMessage(err))
 	}
 
 	// Verbose output
 	if cfg.Verbose {
-		fmt.Printf("%s\n\n", i18n.Info(i18n.MsgReadingSymlinksFrom, symlinkFile))
-	}
-
-	// Read the YAML file
-	data, err := os.ReadFile(symlinkFile)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorReadingFile, err))
-	}
-
-	// Unmarshal YAML to struct
-	var symlinkConfigs []SymlinkConfig
-	err = yaml.Unmarshal(data, &symlinkConfigs)
-	if err != nil {
-		log.Fatalf("%s", i18n.Error(i18n.MsgErrorParsingYAML, err))
+		fmt.Printf("%s\n\n", i18n.Info(i18n.MsgReadingSymlinksFrom, report.SymlinksFile))
 	}
 
 	// Print header
@@ -439,64 +258,48 @@ func ListSymlinksFunc(*cobra.Command, []string) {
 	fmt.Printf("%-8s %-40s -> %s\n", i18n.T(i18n.MsgStatus), i18n.T(i18n.MsgTarget), i18n.T(i18n.MsgSource))
 	fmt.Println("─────────────────────────────────────────────────────────────────────────────────────────────")
 
-	// Counters for summary
-	var installed, wrongTarget, notInstalled, regularFile int
-
-	// Iterate over items and check status
-	for _, entry := range symlinkConfigs {
-		// Get links for current OS
-		links := entry.getLinksForOS(cfg.OS)
-
-		for target, source := range links {
-			targetPath := expandPath(target)
-			sourcePath := expandPath(source)
-
-			// Check if target exists
-			fileInfo, err := os.Lstat(targetPath)
-
-			if os.IsNotExist(err) {
-				// Not installed
-				fmt.Printf("%-8s %-40s -> %s\n", "❌", targetPath, sourcePath)
-				notInstalled++
-				continue
-			}
-
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorCheckingFile, targetPath, err))
-				continue
-			}
+	// Nested targets are installed through another link
+	for _, issue := range report.Issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
 
-			// Check if it's a symlink
-			if fileInfo.Mode()&os.ModeSymlink == 0 {
-				// Regular file exists at target location
-				fmt.Printf("%-8s %-40s -> %s\n", "⛔", targetPath, sourcePath)
-				regularFile++
-				continue
-			}
+	for _, status := range report.Links {
+		link := status.Link
+		if status.Err != nil {
+			log.Printf("%s", errorMessage(status.Err))
+			continue
+		}
 
-			// Read the symlink
-			existingLink, err := os.Readlink(targetPath)
-			if err != nil {
-				log.Printf("%s", i18n.Error(i18n.MsgErrorReadingSymlink, targetPath, err))
-				continue
-			}
+		// Copied and hard linked targets are marked with =>
+		arrow := "->"
+		if link.Mode != sokru.ModeSymlink {
+			arrow = "=>"
+		}
 
-			// Check if it points to the correct source
-			if existingLink == sourcePath {
-				// Installed correctly
-				fmt.Printf("%-8s %-40s -> %s\n", "✅", targetPath, sourcePath)
-				installed++
-			} else {
-				// Installed but wrong target
-				fmt.Printf("%-8s %-40s -> %s\n", "⚠️", targetPath, sourcePath)
-				if cfg.Verbose {
-					fmt.Printf("         (currently points to: %s)\n", existingLink)
-				}
-				wrongTarget++
+		switch status.Status {
+		case sokru.StatusInstalled:
+			fmt.Printf("%-8s %-40s %s %s\n", "✅", link.Target, arrow, link.Source)
+		case sokru.StatusWrongTarget, sokru.StatusOutdated:
+			fmt.Printf("%-8s %-40s %s %s\n", "⚠️", link.Target, arrow, link.Source)
+			if status.Current != "" && cfg.Verbose {
+				fmt.Printf("         (currently points to: %s)\n", status.Current)
 			}
+		case sokru.StatusDrifted:
+			fmt.Printf("%-8s %-40s %s %s\n", "✏️", link.Target, arrow, link.Source)
+		case sokru.StatusBlocked:
+			fmt.Printf("%-8s %-40s %s %s\n", "⛔", link.Target, arrow, link.Source)
+		default:
+			fmt.Printf("%-8s %-40s %s %s\n", "❌", link.Target, arrow, link.Source)
 		}
 	}
 
+	// Counters for summary
+	installed := report.Count(sokru.StatusInstalled)
+	wrongTarget := report.Count(sokru.StatusWrongTarget) + report.Count(sokru.StatusOutdated)
+	notInstalled := report.Count(sokru.StatusMissing)
+	regularFile := report.Count(sokru.StatusBlocked)
+	drifted := report.Count(sokru.StatusDrifted)
+
 	// Print summary
 	fmt.Println()
 	fmt.Println(i18n.T(i18n.MsgSummary))
@@ -511,7 +314,10 @@ func ListSymlinksFunc(*cobra.Command, []string) {
 	if regularFile > 0 {
 		fmt.Printf("⛔ %s\n", i18n.T(i18n.MsgRegularFileExists, regularFile))
 	}
-	fmt.Printf("\n%s\n", i18n.T(i18n.MsgTotalSymlinks, installed+wrongTarget+notInstalled+regularFile))
+	if drifted > 0 {
+		fmt.Printf("✏️  %s\n", i18n.T(i18n.MsgLocalChanges, drifted))
+	}
+	fmt.Printf("\n%s\n", i18n.T(i18n.MsgTotalSymlinks, installed+wrongTarget+notInstalled+regularFile+drifted))
 
 	// Show legend
 	fmt.Printf("\n%s\n", i18n.T(i18n.MsgLegend))
@@ -519,6 +325,7 @@ func ListSymlinksFunc(*cobra.Command, []string) {
 	fmt.Printf("  %s\n", i18n.T(i18n.MsgLegendWrongTarget))
 	fmt.Printf("  %s\n", i18n.T(i18n.MsgLegendNotInstalled))
 	fmt.Printf("  %s\n", i18n.T(i18n.MsgLegendRegularFile))
+	fmt.Printf("  %s\n", i18n.T(i18n.MsgLegendDrifted))
 }
 
 func init() {
@@ -5,18 +5,25 @@
 package cmd
 
 import (
-	"os"
-	"path/filepath"
+	"fmt"
+	"log"
 	"strings"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
 )
 
-// expandPath expands ~ to the user's home directory
+// expandPath expands ~, environment variables and XDG base directories in path.
+// Paths that cannot be expanded are returned unchanged.
 func expandPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, _ := os.UserHomeDir()
-		return filepath.Join(homeDir, path[2:])
+	expanded, err := paths.Expand(path)
+	if err != nil {
+		return path
 	}
-	return path
+	return expanded
 }
 
 // ExpandPathForTesting is exported for testing purposes
@@ -24,6 +31,26 @@ func ExpandPathForTesting(path string) string {
 	return expandPath(path)
 }
 
+// lockConfig takes the lock of the configuration of cmd before a command
+// changes links, state or backups, saying so when another sok process holds
+// it. The command keeps it until it ends and passes it to the operations it
+// runs, so concurrent runs, like a login hook and a terminal, take turns
+// without one slipping in between the steps of the other.
+func lockConfig(cmd *cobra.Command) *sokru.Lock {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLoadingConfig, err))
+	}
+
+	lock, err := sokru.AcquireLock(cfg, func(path string) {
+		fmt.Fprintln(messageOutput(), i18n.Info(i18n.MsgWaitingForLock, path))
+	})
+	if err != nil {
+		log.Fatalf("%s", i18n.Error(i18n.MsgErrorLocking, err))
+	}
+	return lock
+}
+
 // validateOS checks if the OS is one of the supported values
 func validateOS(osName string) bool {
 	validOS := map[string]bool{
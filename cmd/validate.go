@@ -0,0 +1,70 @@
+// Package cmd
+// Description: This file contains the validate command, which checks symlinks.yaml and the files it includes for mistakes.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"github.com/spf13/cobra"
+)
+
+// skipValidation disables the validation run before install and apply
+var skipValidation bool
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the symlinks file for mistakes",
+	Long: `This command will check symlinks.yaml and the files it includes for unknown
+keys, duplicate targets, missing sources, targets inside the dotfiles
+directory or nested under other targets and link cycles, for every OS and
+profile.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.FromContext(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+
+		symlinkFile := expandPath(cfg.SymlinksFile)
+		issues, err := sokru.ValidateSymlinks(symlinkFile, sokru.ValidateOptions{
+			Resolve:     sokru.NewResolveOptions(cfg, symlinkFile),
+			DotfilesDir: cfg.DotfilesDir,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var errorCount, warningCount int
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Warning {
+				warningCount++
+			} else {
+				errorCount++
+			}
+		}
+
+		if len(issues) == 0 {
+			fmt.Printf("✓ %s is valid\n", symlinkFile)
+			return
+		}
+
+		fmt.Printf("\n%d error(s), %d warning(s)\n", errorCount, warningCount)
+		if errorCount > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	installCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Do not validate the symlinks file before installing")
+	applyCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Do not validate the symlinks file before applying")
+	rootCmd.AddCommand(validateCmd)
+}
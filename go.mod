@@ -3,6 +3,7 @@ module github.com/alexlm78/sokru
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/spf13/cobra v1.8.1
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -7,10 +7,13 @@ package backup
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/pkg/fsys"
 )
 
 // BackupEntry represents a single backed up file or symlink
@@ -34,12 +37,20 @@ type BackupMetadata struct {
 // Manager handles backup operations
 type Manager struct {
 	backupDir string
+	fsys      fsys.FS
 }
 
-// NewManager creates a new backup manager
+// NewManager creates a new backup manager on the real filesystem
 func NewManager(backupDir string) *Manager {
+	return NewManagerFS(fsys.OS{}, backupDir)
+}
+
+// NewManagerFS creates a new backup manager that backs up and restores files
+// on fsys, keeping the backups in backupDir of fsys
+func NewManagerFS(fsys fsys.FS, backupDir string) *Manager {
 	return &Manager{
 		backupDir: backupDir,
+		fsys:      fsys,
 	}
 }
 
@@ -50,7 +61,7 @@ func (m *Manager) GetBackupDir() string {
 
 // EnsureBackupDir creates the backup directory if it doesn't exist
 func (m *Manager) EnsureBackupDir() error {
-	if err := os.MkdirAll(m.backupDir, 0755); err != nil {
+	if err := m.fsys.MkdirAll(m.backupDir, 0755); err != nil {
 		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 	return nil
@@ -64,14 +75,14 @@ func (m *Manager) CreateBackup(originalPath string, backupID string) (*BackupEnt
 	}
 
 	// Get file info
-	fileInfo, err := os.Lstat(originalPath)
+	fileInfo, err := m.fsys.Lstat(originalPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	// Create backup subdirectory for this backup session
 	sessionDir := filepath.Join(m.backupDir, backupID)
-	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+	if err := m.fsys.MkdirAll(sessionDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create session directory: %w", err)
 	}
 
@@ -81,7 +92,7 @@ func (m *Manager) CreateBackup(originalPath string, backupID string) (*BackupEnt
 
 	// Handle symlinks
 	if fileInfo.Mode()&os.ModeSymlink != 0 {
-		target, err := os.Readlink(originalPath)
+		target, err := m.fsys.Readlink(originalPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read symlink: %w", err)
 		}
@@ -116,37 +127,31 @@ func (m *Manager) CreateBackup(originalPath string, backupID string) (*BackupEnt
 
 // copyFile copies a file from src to dst
 func (m *Manager) copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
+	data, err := m.fsys.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
+	if err := m.fsys.WriteFile(dst, data, 0644); err != nil {
 		return err
 	}
 
 	// Copy file permissions
-	sourceInfo, err := os.Stat(src)
+	sourceInfo, err := m.fsys.Stat(src)
 	if err != nil {
 		return err
 	}
 
-	return os.Chmod(dst, sourceInfo.Mode())
+	return m.fsys.Chmod(dst, sourceInfo.Mode())
 }
 
-// SaveMetadata saves backup metadata to a JSON file
+// SaveMetadata saves backup metadata to a JSON file. The file is replaced at
+// once, so a crash never leaves a backup with half its metadata.
 func (m *Manager) SaveMetadata(metadata *BackupMetadata) error {
 	sessionDir := filepath.Join(m.backupDir, metadata.ID)
 
 	// Ensure session directory exists
-	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+	if err := m.fsys.MkdirAll(sessionDir, 0755); err != nil {
 		return fmt.Errorf("failed to create session directory: %w", err)
 	}
 
@@ -157,7 +162,7 @@ func (m *Manager) SaveMetadata(metadata *BackupMetadata) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := fsys.WriteFileAtomic(m.fsys, metadataPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
@@ -168,7 +173,7 @@ func (m *Manager) SaveMetadata(metadata *BackupMetadata) error {
 func (m *Manager) LoadMetadata(backupID string) (*BackupMetadata, error) {
 	metadataPath := filepath.Join(m.backupDir, backupID, "metadata.json")
 
-	data, err := os.ReadFile(metadataPath)
+	data, err := m.fsys.ReadFile(metadataPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
@@ -183,7 +188,7 @@ func (m *Manager) LoadMetadata(backupID string) (*BackupMetadata, error) {
 
 // ListBackups returns a list of all available backups
 func (m *Manager) ListBackups() ([]BackupMetadata, error) {
-	entries, err := os.ReadDir(m.backupDir)
+	entries, err := m.fsys.ReadDir(m.backupDir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []BackupMetadata{}, nil
@@ -222,19 +227,19 @@ func (m *Manager) RestoreBackup(backupID string) error {
 		if entry.IsSymlink {
 			// Restore symlink
 			// Remove current file/symlink if exists
-			if err := os.Remove(entry.OriginalPath); err != nil && !os.IsNotExist(err) {
+			if err := m.fsys.Remove(entry.OriginalPath); err != nil && !os.IsNotExist(err) {
 				errors = append(errors, fmt.Errorf("failed to remove %s: %w", entry.OriginalPath, err))
 				continue
 			}
 
 			// Recreate symlink
-			if err := os.Symlink(entry.SymlinkTarget, entry.OriginalPath); err != nil {
+			if err := m.fsys.Symlink(entry.SymlinkTarget, entry.OriginalPath); err != nil {
 				errors = append(errors, fmt.Errorf("failed to restore symlink %s: %w", entry.OriginalPath, err))
 			}
 		} else {
 			// Restore regular file
 			// Remove current file if exists
-			if err := os.Remove(entry.OriginalPath); err != nil && !os.IsNotExist(err) {
+			if err := m.fsys.Remove(entry.OriginalPath); err != nil && !os.IsNotExist(err) {
 				errors = append(errors, fmt.Errorf("failed to remove %s: %w", entry.OriginalPath, err))
 				continue
 			}
@@ -246,7 +251,7 @@ func (m *Manager) RestoreBackup(backupID string) error {
 			}
 
 			// Restore permissions
-			if err := os.Chmod(entry.OriginalPath, entry.FileMode); err != nil {
+			if err := m.fsys.Chmod(entry.OriginalPath, entry.FileMode); err != nil {
 				errors = append(errors, fmt.Errorf("failed to restore permissions for %s: %w", entry.OriginalPath, err))
 			}
 		}
@@ -262,22 +267,56 @@ func (m *Manager) RestoreBackup(backupID string) error {
 // DeleteBackup removes a backup directory
 func (m *Manager) DeleteBackup(backupID string) error {
 	backupPath := filepath.Join(m.backupDir, backupID)
-	if err := os.RemoveAll(backupPath); err != nil {
+	if err := m.fsys.RemoveAll(backupPath); err != nil {
 		return fmt.Errorf("failed to delete backup: %w", err)
 	}
 	return nil
 }
 
+// Prune deletes the oldest backups so at most keep remain, and returns the
+// IDs of the deleted ones. A keep of 0 or less keeps every backup.
+func (m *Manager) Prune(keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	backups, err := m.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	// IDs are timestamps, so they sort from the oldest to the newest
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID < backups[j].ID })
+
+	var pruned []string
+	for _, metadata := range backups[:len(backups)-keep] {
+		if err := m.DeleteBackup(metadata.ID); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, metadata.ID)
+	}
+	return pruned, nil
+}
+
 // GenerateBackupID generates a unique backup ID based on timestamp
 func GenerateBackupID() string {
 	return time.Now().Format("20060102-150405.000")
 }
 
-// GetDefaultBackupDir returns the default backup directory path
+// GetDefaultBackupDir returns the default backup directory path, in
+// $XDG_DATA_HOME/sokru
 func GetDefaultBackupDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	dataDir, err := paths.DataDir("")
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(homeDir, ".config", "sokru", "backups"), nil
+	return BackupDir("", dataDir), nil
+}
+
+// BackupDir returns the backup directory in dataDir, for the --home dir home
+func BackupDir(home, dataDir string) string {
+	return paths.Locate(home, dataDir, "backups")
 }
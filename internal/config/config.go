@@ -10,26 +10,45 @@ import (
 	"path/filepath"
 	"runtime"
 
-	"gopkg.in/yaml.v3"
+	"github.com/alexlm78/sokru/internal/format"
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/internal/safefile"
 )
 
 // Config represents the application configuration
 type Config struct {
-	DotfilesDir  string `yaml:"dotfiles_dir"`
-	SymlinksFile string `yaml:"symlinks_file"`
-	OS           string `yaml:"os"`
-	Language     string `yaml:"language"`
-	Verbose      bool   `yaml:"verbose"`
-	DryRun       bool   `yaml:"dry_run"`
+	// Version is the version of the file format, see Migrate
+	Version      int    `yaml:"version" config:"readonly" help:"Version of the config file format, upgraded by sok config migrate"`
+	DotfilesDir  string `yaml:"dotfiles_dir" help:"Directory of the dotfiles repository"`
+	SymlinksFile string `yaml:"symlinks_file" help:"File that lists the symlinks"`
+	OS           string `yaml:"os" schema:"enum=linux|darwin|windows" help:"Operating system the links are selected for"`
+	Language     string `yaml:"language" schema:"enum=en|es" help:"Language of the messages"`
+	Verbose      bool   `yaml:"verbose" help:"Print the details of every operation"`
+	DryRun       bool   `yaml:"dry_run" help:"Show the changes without making them"`
+	// Profile is the active profile of symlinks.yaml, empty for none
+	Profile string `yaml:"profile,omitempty" help:"Active profile of the symlinks file, empty for none"`
+	// Variables are custom values available to templates as .Vars
+	Variables map[string]string `yaml:"variables,omitempty" help:"Values available to templates as .Vars"`
+	// Backup configures the backups made before targets are replaced
+	Backup BackupConfig `yaml:"backup,omitempty"`
+
+	// Home holds every file of sok when set, the directory given with
+	// --home. It is not a key of the config file.
+	Home string `yaml:"-"`
+	// StateDir holds the state and the lock, and DataDir the backups and
+	// rendered templates. Resolve sets them from Home or the XDG base
+	// directories; when empty, Dirs finds them from Home.
+	StateDir string `yaml:"-"`
+	DataDir  string `yaml:"-"`
 }
 
-const (
-	configDir  = ".config/sokru"
-	configFile = "config.yaml"
-)
+// BackupConfig configures the backups made before targets are replaced
+type BackupConfig struct {
+	// MaxBackups is the number of backups kept, 0 keeps all of them
+	MaxBackups int `yaml:"max_backups,omitempty" help:"Number of backups kept, older ones are deleted after installing; 0 keeps all"`
+}
 
-// Global configuration instance
-var globalConfig *Config
+const configFile = "config.yaml"
 
 // GetDefaultConfig returns a Config with default values
 func GetDefaultConfig() *Config {
@@ -39,6 +58,7 @@ func GetDefaultConfig() *Config {
 	}
 
 	return &Config{
+		Version:      CurrentVersion,
 		DotfilesDir:  filepath.Join(homeDir, "dotfiles"),
 		SymlinksFile: filepath.Join(homeDir, "dotfiles", "symlinks.yaml"),
 		OS:           runtime.GOOS,
@@ -48,125 +68,188 @@ func GetDefaultConfig() *Config {
 	}
 }
 
-// getConfigPath returns the full path to the config file
-func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// setDirs sets the state and data directories from Home
+func (c *Config) setDirs() error {
+	stateDir, err := paths.StateDir(c.Home)
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return err
+	}
+	dataDir, err := paths.DataDir(c.Home)
+	if err != nil {
+		return err
 	}
+	c.StateDir, c.DataDir = stateDir, dataDir
+	return nil
+}
 
-	return filepath.Join(homeDir, configDir, configFile), nil
+// Dirs returns the state and data directories of c, found from Home when
+// they are not set
+func (c *Config) Dirs() (stateDir, dataDir string, err error) {
+	if c.StateDir == "" || c.DataDir == "" {
+		dirs := *c
+		if err := dirs.setDirs(); err != nil {
+			return "", "", err
+		}
+		return dirs.StateDir, dirs.DataDir, nil
+	}
+	return c.StateDir, c.DataDir, nil
 }
 
-// ensureConfigDir creates the config directory if it doesn't exist
-func ensureConfigDir() error {
-	homeDir, err := os.UserHomeDir()
+// LockFile returns the file sok processes lock while they change the files
+// of c
+func (c *Config) LockFile() (string, error) {
+	stateDir, _, err := c.Dirs()
+	if err != nil {
+		return "", err
+	}
+	return paths.LockFile(stateDir), nil
+}
+
+// getConfigPath returns the full path to the user config file: the first of
+// config.yaml, config.yml, config.toml and config.json that exists in
+// $XDG_CONFIG_HOME/sokru, or home, or config.yaml when there is none yet
+func getConfigPath(home string) (string, error) {
+	dir, err := paths.ConfigDir(home)
 	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
+	}
+
+	if path := findConfigFile(dir); path != "" {
+		return path, nil
+	}
+
+	// Files written before XDG_CONFIG_HOME was honored
+	if home == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			if path := findConfigFile(filepath.Join(homeDir, ".config", paths.App)); path != "" {
+				return path, nil
+			}
+		}
 	}
 
-	configDirPath := filepath.Join(homeDir, configDir)
-	if err := os.MkdirAll(configDirPath, 0755); err != nil {
+	return filepath.Join(dir, configFile), nil
+}
+
+// ensureConfigDir creates the directory of the config file if it doesn't exist
+func ensureConfigDir(configPath string) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
 	return nil
 }
 
-// LoadConfig reads the configuration from ~/.sokru/config.yaml
-// If the file doesn't exist, it returns the default configuration
-func LoadConfig() (*Config, error) {
-	configPath, err := getConfigPath()
+// lockFiles takes the lock sok processes share, so that reading the user
+// config file of home and writing it back is not interleaved with another sok
+func lockFiles(home string) (*safefile.Lock, error) {
+	stateDir, err := paths.StateDir(home)
 	if err != nil {
 		return nil, err
 	}
+	return safefile.Acquire(paths.LockFile(stateDir))
+}
 
-	// If config file doesn't exist, return default config
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return GetDefaultConfig(), nil
-	}
+// LoadConfig resolves the configuration from the defaults, the system file,
+// the user file and the SOKRU_* environment variables, in the XDG base
+// directories. Missing files are skipped, so without any it returns the
+// default configuration.
+func LoadConfig() (*Config, error) {
+	cfg, _, err := Resolve(Options{})
+	return cfg, err
+}
+
+// loadUserConfig returns the defaults overridden by the user file alone, the
+// configuration UpdateConfig writes back
+func loadUserConfig(home string) (*Config, error) {
+	cfg := GetDefaultConfig()
+	cfg.Home = home
 
-	// Read the config file
-	data, err := os.ReadFile(configPath)
+	configPath, err := getConfigPath(home)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
-
-	// Parse YAML
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return cfg, nil
 	}
 
-	return &config, nil
+	if err := mergeFile(cfg, make(Origins), configPath); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-// SaveConfig writes the configuration to ~/.sokru/config.yaml
+// SaveConfig writes the configuration to the config file of its Home, in its
+// format
 func SaveConfig(config *Config) error {
 	if config == nil {
 		return fmt.Errorf("config cannot be nil")
 	}
 
-	// Ensure config directory exists
-	if err := ensureConfigDir(); err != nil {
+	lock, err := lockFiles(config.Home)
+	if err != nil {
 		return err
 	}
+	defer lock.Release()
+
+	return saveConfig(config)
+}
 
+// saveConfig writes config like SaveConfig, with the lock already held
+func saveConfig(config *Config) error {
 	// Get config file path
-	configPath, err := getConfigPath()
+	configPath, err := getConfigPath(config.Home)
 	if err != nil {
 		return err
 	}
 
-	// Marshal config to YAML
-	data, err := yaml.Marshal(config)
+	// Ensure config directory exists
+	if err := ensureConfigDir(configPath); err != nil {
+		return err
+	}
+
+	// Files of older versions are kept before they are rewritten
+	if err := backupOldVersion(configPath); err != nil {
+		return err
+	}
+
+	// Marshal config in the format of the file
+	versioned := *config
+	versioned.Version = CurrentVersion
+	data, err := format.Marshal(configPath, &versioned)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// Write to file, replacing it at once so it is never left half written
+	if err := safefile.WriteFile(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
 
-// GetConfig returns the global configuration instance
-// If not loaded yet, it loads the configuration
-func GetConfig() (*Config, error) {
-	if globalConfig == nil {
-		config, err := LoadConfig()
-		if err != nil {
-			return nil, err
-		}
-		globalConfig = config
+// UpdateConfig updates specific fields in the user config file of home. Values
+// of the other layers are not written to the file, and configurations already
+// resolved are left as they are: resolve a new one to see the change.
+func UpdateConfig(home string, updateFunc func(*Config)) error {
+	lock, err := lockFiles(home)
+	if err != nil {
+		return err
 	}
-	return globalConfig, nil
-}
-
-// SetConfig sets the global configuration instance
-func SetConfig(config *Config) {
-	globalConfig = config
-}
+	defer lock.Release()
 
-// UpdateConfig updates specific fields in the configuration and saves it
-func UpdateConfig(updateFunc func(*Config)) error {
-	config, err := GetConfig()
+	config, err := loadUserConfig(home)
 	if err != nil {
 		return err
 	}
 
 	updateFunc(config)
 
-	if err := SaveConfig(config); err != nil {
-		return err
-	}
-
-	return nil
+	return saveConfig(config)
 }
 
-// GetConfigPath returns the path to the config file (exported for external use)
-func GetConfigPath() (string, error) {
-	return getConfigPath()
+// GetConfigPath returns the path to the user config file of home, the XDG
+// one when home is empty (exported for external use)
+func GetConfigPath(home string) (string, error) {
+	return getConfigPath(home)
 }
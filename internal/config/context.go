@@ -0,0 +1,52 @@
+// Package config
+// Description: This file carries the configuration in a context, so every operation uses the one it was given instead of a shared global.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package config
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoConfig is returned by FromContext when the context carries no
+// configuration
+var ErrNoConfig = errors.New("no configuration in the context")
+
+// contextKey is the key of the configuration in a context
+type contextKey struct{}
+
+// originsKey is the key of the origins of the configuration in a context
+type originsKey struct{}
+
+// WithConfig returns a copy of ctx carrying cfg. Operations given the context
+// read cfg from it with FromContext; cfg must not be changed once shared.
+func WithConfig(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// FromContext returns the configuration carried by ctx, or ErrNoConfig when
+// it carries none. It never loads one from disk: resolve it with Resolve and
+// store it with WithConfig first.
+func FromContext(ctx context.Context) (*Config, error) {
+	if ctx != nil {
+		if cfg, ok := ctx.Value(contextKey{}).(*Config); ok && cfg != nil {
+			return cfg, nil
+		}
+	}
+	return nil, ErrNoConfig
+}
+
+// WithOrigins returns a copy of ctx carrying the origins of its configuration
+func WithOrigins(ctx context.Context, origins Origins) context.Context {
+	return context.WithValue(ctx, originsKey{}, origins)
+}
+
+// OriginsFromContext returns the origins carried by ctx, nil without them
+func OriginsFromContext(ctx context.Context) Origins {
+	if ctx == nil {
+		return nil
+	}
+	origins, _ := ctx.Value(originsKey{}).(Origins)
+	return origins
+}
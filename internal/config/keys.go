@@ -0,0 +1,395 @@
+// Package config
+// Description: This file lists the configuration keys, with their types and checks, for sok config get, set, unset and list and for the environment and flag layers.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/format"
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/internal/safefile"
+	"gopkg.in/yaml.v3"
+)
+
+// Key is a configuration key, named by its dotted path in the config file
+type Key struct {
+	// Name is the dotted path of the key, like backup.max_backups
+	Name string
+	// Type is string, boolean, integer or map
+	Type string
+	// Help describes the key, from the help tag of its field
+	Help string
+	// Enum lists the allowed values, from the schema tag of its field
+	Enum []string
+	// ReadOnly keys are managed by sok, tagged config:"readonly"
+	ReadOnly bool
+	// index locates the field of the key in Config
+	index []int
+}
+
+// checks validate and normalize the values of some keys, after their type
+// and allowed values are checked
+var checks = map[string]func(value any) (any, error){
+	"dotfiles_dir":       expandPath,
+	"symlinks_file":      expandPath,
+	"backup.max_backups": nonNegative,
+}
+
+// keys are the keys of Config, nested structs included
+var keys = collectKeys(reflect.TypeOf(Config{}), "", nil)
+
+// collectKeys returns the keys of the fields of t, named after their yaml tags
+func collectKeys(t reflect.Type, prefix string, index []int) []Key {
+	var result []Key
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		name = prefix + name
+		fieldIndex := append(slices.Clone(index), i)
+
+		if f.Type.Kind() == reflect.Struct {
+			result = append(result, collectKeys(f.Type, name+".", fieldIndex)...)
+			continue
+		}
+
+		key := Key{Name: name, Help: f.Tag.Get("help"), ReadOnly: f.Tag.Get("config") == "readonly", index: fieldIndex}
+		switch f.Type.Kind() {
+		case reflect.Bool:
+			key.Type = "boolean"
+		case reflect.Int:
+			key.Type = "integer"
+		case reflect.Map:
+			key.Type = "map"
+		default:
+			key.Type = "string"
+		}
+		for _, option := range strings.Split(f.Tag.Get("schema"), ",") {
+			if values, ok := strings.CutPrefix(option, "enum="); ok {
+				key.Enum = strings.Split(values, "|")
+			}
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// Keys returns the configuration keys, in the order of the fields of Config
+func Keys() []Key {
+	return slices.Clone(keys)
+}
+
+// LookupKey returns the key called name. Entries of maps are named after
+// their map, like variables.email, which returns the key of variables and
+// the name of the entry.
+func LookupKey(name string) (Key, string, error) {
+	for _, key := range keys {
+		if key.Name == name {
+			return key, "", nil
+		}
+		if entry, ok := strings.CutPrefix(name, key.Name+"."); ok && key.Type == "map" && entry != "" {
+			return key, entry, nil
+		}
+	}
+	return Key{}, "", fmt.Errorf("unknown configuration key %q", name)
+}
+
+// EnvName returns the environment variable that sets the key
+func (k Key) EnvName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(k.Name, ".", "_"))
+}
+
+// FlagName returns the command-line flag that sets the key
+func (k Key) FlagName() string {
+	return "--" + strings.NewReplacer("_", "-", ".", "-").Replace(k.Name)
+}
+
+// Parse converts text to a value of the type of the key and checks it. Map
+// keys parse the value of one of their entries.
+func (k Key) Parse(text string) (any, error) {
+	if k.ReadOnly {
+		return nil, fmt.Errorf("%s is managed by sok and cannot be set", k.Name)
+	}
+
+	var value any
+	switch k.Type {
+	case "boolean":
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", text)
+		}
+		value = b
+	case "integer":
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		value = n
+	default:
+		value = text
+	}
+
+	if len(k.Enum) > 0 {
+		lower := strings.ToLower(text)
+		if !slices.Contains(k.Enum, lower) {
+			return nil, fmt.Errorf("%q is not one of %s", text, strings.Join(k.Enum, ", "))
+		}
+		value = lower
+	}
+
+	if check, ok := checks[k.Name]; ok {
+		return check(value)
+	}
+	return value, nil
+}
+
+// field returns the field of the key in cfg
+func (k Key) field(cfg *Config) reflect.Value {
+	return reflect.ValueOf(cfg).Elem().FieldByIndex(k.index)
+}
+
+// Get returns the value of the key called name in cfg, as text
+func Get(cfg *Config, name string) (string, error) {
+	key, entry, err := LookupKey(name)
+	if err != nil {
+		return "", err
+	}
+
+	v := key.field(cfg)
+	if key.Type != "map" {
+		return fmt.Sprint(v.Interface()), nil
+	}
+	if entry == "" {
+		return "", fmt.Errorf("%s is a map, get one of its entries like %s.<name>", key.Name, key.Name)
+	}
+	value := v.MapIndex(reflect.ValueOf(entry))
+	if !value.IsValid() {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+	return value.String(), nil
+}
+
+// Entries returns the names of the entries of a map key in cfg, like
+// variables.email, sorted
+func Entries(cfg *Config, key Key) []string {
+	var names []string
+	if key.Type != "map" {
+		return names
+	}
+	for _, entry := range key.field(cfg).MapKeys() {
+		names = append(names, key.Name+"."+entry.String())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Set parses text and sets the key called name in cfg
+func Set(cfg *Config, name, text string) error {
+	key, entry, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	if key.Type == "map" && entry == "" {
+		return fmt.Errorf("%s is a map, set one of its entries like %s.<name>", key.Name, key.Name)
+	}
+
+	value, err := key.Parse(text)
+	if err != nil {
+		return err
+	}
+
+	v := key.field(cfg)
+	if key.Type == "map" {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(reflect.ValueOf(entry), reflect.ValueOf(value))
+		return nil
+	}
+	v.Set(reflect.ValueOf(value))
+	return nil
+}
+
+// SetKey sets the key called name to text in the user config file of home and
+// returns the value written, once checked. The rest of the file, comments
+// included in YAML, is kept as it is.
+func SetKey(home, name, text string) (string, error) {
+	key, entry, err := LookupKey(name)
+	if err != nil {
+		return "", err
+	}
+	if key.Type == "map" && entry == "" {
+		return "", fmt.Errorf("%s is a map, set one of its entries like %s.<name>", key.Name, key.Name)
+	}
+
+	value, err := key.Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return "", err
+	}
+
+	err = editUserFile(home, func(root *yaml.Node) {
+		setNode(root, keyPath(key, entry), &node)
+	})
+	return fmt.Sprint(value), err
+}
+
+// UnsetKey removes the key called name from the user config file of home, so its
+// value comes from the other layers again
+func UnsetKey(home, name string) error {
+	key, entry, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	if key.ReadOnly {
+		return fmt.Errorf("%s is managed by sok and cannot be unset", key.Name)
+	}
+
+	return editUserFile(home, func(root *yaml.Node) {
+		removeNode(root, keyPath(key, entry))
+	})
+}
+
+// keyPath returns the path of mapping keys of a key in the config file
+func keyPath(key Key, entry string) []string {
+	path := strings.Split(key.Name, ".")
+	if entry != "" {
+		path = append(path, entry)
+	}
+	return path
+}
+
+// editUserFile applies edit to the root mapping of the user config file of
+// home, creating the file when it doesn't exist
+func editUserFile(home string, edit func(root *yaml.Node)) error {
+	lock, err := lockFiles(home)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	configPath, err := getConfigPath(home)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		data, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Files of older versions are migrated, and kept, before they are edited
+	root, err := parseMapping(configPath, data)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		setVersion(root, CurrentVersion)
+	}
+	from, err := fileVersion(configPath, root)
+	if err != nil {
+		return err
+	}
+	applied, err := migrateNode(configPath, root)
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		if _, err := backupConfigFile(configPath, from, data); err != nil {
+			return err
+		}
+	}
+
+	edit(root)
+
+	data, err = format.Marshal(configPath, root)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := ensureConfigDir(configPath); err != nil {
+		return err
+	}
+	if err := safefile.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// setNode sets the value at path in a mapping, creating the mappings on the
+// way
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content[i+1] = value
+			return
+		}
+		if mapping.Content[i+1].Kind != yaml.MappingNode {
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		setNode(mapping.Content[i+1], path[1:], value)
+		return
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, keyNode, value)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, keyNode, child)
+	setNode(child, path[1:], value)
+}
+
+// removeNode removes the value at path from a mapping, and the mappings on
+// the way that are left empty
+func removeNode(mapping *yaml.Node, path []string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			child := mapping.Content[i+1]
+			if child.Kind != yaml.MappingNode {
+				return
+			}
+			removeNode(child, path[1:])
+			if len(child.Content) > 0 {
+				return
+			}
+		}
+		mapping.Content = slices.Delete(mapping.Content, i, i+2)
+		return
+	}
+}
+
+// expandPath expands ~ and variables in a path
+func expandPath(value any) (any, error) {
+	return paths.Expand(value.(string))
+}
+
+// nonNegative refuses negative numbers
+func nonNegative(value any) (any, error) {
+	if value.(int) < 0 {
+		return nil, fmt.Errorf("%d is negative", value)
+	}
+	return value, nil
+}
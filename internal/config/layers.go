@@ -0,0 +1,191 @@
+// Package config
+// Description: This file resolves the configuration from its layers: defaults, the system file, the user file, SOKRU_* environment variables, a --config file and command-line flags.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/format"
+	"gopkg.in/yaml.v3"
+)
+
+// OriginDefault is the origin of the values no layer sets
+const OriginDefault = "default"
+
+// EnvPrefix prefixes the environment variables that set configuration keys,
+// like SOKRU_DOTFILES_DIR for dotfiles_dir
+const EnvPrefix = "SOKRU_"
+
+// SystemConfigDir is the directory of the system-wide config file, read
+// before the user one
+var SystemConfigDir = "/etc/sokru"
+
+// Origins maps each configuration key to where its value came from: "default",
+// the path of a config file, an environment variable or a flag. The entries
+// of variables are keyed as variables.<name>.
+type Origins map[string]string
+
+// Options are the layers of Resolve given by the caller
+type Options struct {
+	// File is a config file given with --config, read after the environment.
+	// Unlike the system and user files, it must exist.
+	File string
+	// Flags are the values set on the command line, by configuration key.
+	// They override every other layer.
+	Flags map[string]string
+	// Home keeps every file of sok in this directory instead of the XDG base
+	// directories, like --home. The system file is not read then.
+	Home string
+}
+
+// Resolve builds the configuration from its layers, each one overriding the
+// keys it sets in the previous ones:
+//
+//  1. Defaults
+//  2. The system file, /etc/sokru/config.yaml, skipped with --home
+//  3. The user file, $XDG_CONFIG_HOME/sokru/config.yaml
+//  4. SOKRU_* environment variables
+//  5. The file of opts.File
+//  6. opts.Flags
+//
+// The files may be written in any of the supported formats.
+func Resolve(opts Options) (*Config, Origins, error) {
+	cfg := GetDefaultConfig()
+	cfg.Home = opts.Home
+	if err := cfg.setDirs(); err != nil {
+		return nil, nil, err
+	}
+	origins := make(Origins)
+	for _, key := range keys {
+		if key.Type != "map" {
+			origins[key.Name] = OriginDefault
+		}
+	}
+
+	// --home isolates sok from the rest of the machine
+	if systemPath := findConfigFile(SystemConfigDir); systemPath != "" && opts.Home == "" {
+		if err := mergeFile(cfg, origins, systemPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	userPath, err := getConfigPath(opts.Home)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(userPath); err == nil {
+		if err := mergeFile(cfg, origins, userPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, key := range keys {
+		value, ok := os.LookupEnv(key.EnvName())
+		if !ok || key.Type == "map" {
+			continue
+		}
+		if err := Set(cfg, key.Name, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", key.EnvName(), err)
+		}
+		origins[key.Name] = key.EnvName()
+	}
+
+	if opts.File != "" {
+		if err := mergeFile(cfg, origins, opts.File); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, key := range keys {
+		value, ok := opts.Flags[key.Name]
+		if !ok {
+			continue
+		}
+		if err := Set(cfg, key.Name, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", key.FlagName(), err)
+		}
+		origins[key.Name] = key.FlagName()
+	}
+
+	return cfg, origins, nil
+}
+
+// findConfigFile returns the first of config.yaml, config.yml, config.toml
+// and config.json that exists in dir, or an empty string when there is none
+func findConfigFile(dir string) string {
+	base := strings.TrimSuffix(configFile, filepath.Ext(configFile))
+	for _, ext := range format.Extensions {
+		path := filepath.Join(dir, base+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// mergeFile sets the keys present in the config file at path. Entries of
+// variables are merged one by one, so a file can add a variable without
+// repeating the others.
+func mergeFile(cfg *Config, origins Origins, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	root, err := parseMapping(path, data)
+	if err != nil {
+		return err
+	}
+	if _, err := migrateNode(path, root); err != nil {
+		return err
+	}
+
+	var layer Config
+	if err := root.Decode(&layer); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	present := make(map[string]bool)
+	collectPaths(root, "", present)
+
+	dst, src := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(&layer).Elem()
+	for _, key := range keys {
+		if !present[key.Name] {
+			continue
+		}
+		if key.Type != "map" {
+			dst.FieldByIndex(key.index).Set(src.FieldByIndex(key.index))
+			origins[key.Name] = path
+			continue
+		}
+
+		entries, merged := src.FieldByIndex(key.index), dst.FieldByIndex(key.index)
+		if merged.IsNil() && entries.Len() > 0 {
+			merged.Set(reflect.MakeMap(merged.Type()))
+		}
+		for _, name := range entries.MapKeys() {
+			merged.SetMapIndex(name, entries.MapIndex(name))
+			origins[key.Name+"."+name.String()] = path
+		}
+	}
+
+	return nil
+}
+
+// collectPaths records the dotted path of every key of a mapping, nested
+// mappings included
+func collectPaths(mapping *yaml.Node, prefix string, present map[string]bool) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		path := prefix + mapping.Content[i].Value
+		present[path] = true
+		if value := mapping.Content[i+1]; value.Kind == yaml.MappingNode {
+			collectPaths(value, path+".", present)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package config
+// Description: This file versions the config files and upgrades old ones through a pipeline of migrations, keeping a backup of the original.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alexlm78/sokru/internal/format"
+	"github.com/alexlm78/sokru/internal/safefile"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the config files written by this sok.
+// Files without a version key are version 1.
+const CurrentVersion = 2
+
+// Migration upgrades a config file from one version to the next
+type Migration struct {
+	// From is the version the migration upgrades, to From+1
+	From int
+	// Description says what the migration changes
+	Description string
+	// Apply edits the root mapping of the file. The version key is set after
+	// it runs.
+	Apply func(root *yaml.Node) error
+}
+
+// migrations upgrade config files, in order. Renaming or moving a key means
+// adding one here and bumping CurrentVersion.
+var migrations = []Migration{
+	// Version 2 only adds the version key, which Migrate sets after every
+	// migration, so there is nothing to rewrite
+	{
+		From:        1,
+		Description: "Add the version key",
+		Apply:       func(root *yaml.Node) error { return nil },
+	},
+}
+
+// MigrationResult is the outcome of migrating a config file
+type MigrationResult struct {
+	Path string
+	// From and To are the versions before and after, equal when the file was
+	// already current
+	From int
+	To   int
+	// Applied are the migrations run, in order
+	Applied []Migration
+	// Original and Migrated are the contents of the file before and after
+	Original []byte
+	Migrated []byte
+	// Backup is the copy of the original file, empty on dry runs or when
+	// nothing changed
+	Backup string
+}
+
+// Migrate upgrades the config file at path to CurrentVersion. The original
+// is copied next to it first, as <file>.v<version>-<time>.bak. With dryRun
+// nothing is written. The lock of the --home dir home is held meanwhile.
+func Migrate(home, path string, dryRun bool) (*MigrationResult, error) {
+	lock, err := lockFiles(home)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	root, err := parseMapping(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{Path: path, Original: data, Migrated: data}
+	result.From, err = fileVersion(path, root)
+	if err != nil {
+		return nil, err
+	}
+	result.Applied, err = migrateNode(path, root)
+	if err != nil {
+		return nil, err
+	}
+	result.To = CurrentVersion
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	result.Migrated, err = format.Marshal(path, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	result.Backup, err = backupConfigFile(path, result.From, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := safefile.WriteFile(path, result.Migrated, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write config file: %w", err)
+	}
+	return result, nil
+}
+
+// parseMapping parses a config file, which must hold a mapping of keys. An
+// empty file is an empty mapping.
+func parseMapping(path string, data []byte) (*yaml.Node, error) {
+	root, err := format.Parse(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if root == nil {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse config file %s: expected a mapping of keys", path)
+	}
+	return root, nil
+}
+
+// fileVersion returns the version key of a config file, 1 when it has none
+func fileVersion(path string, root *yaml.Node) (int, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "version" {
+			continue
+		}
+		version, err := strconv.Atoi(root.Content[i+1].Value)
+		if err != nil || version < 1 {
+			return 0, fmt.Errorf("config file %s has an invalid version %q", path, root.Content[i+1].Value)
+		}
+		if version > CurrentVersion {
+			return 0, fmt.Errorf("config file %s has version %d, this sok only reads up to version %d", path, version, CurrentVersion)
+		}
+		return version, nil
+	}
+	return 1, nil
+}
+
+// migrateNode runs the pending migrations on the root mapping of a config
+// file and returns them. Files are read through it, so old files work before
+// they are migrated on disk.
+func migrateNode(path string, root *yaml.Node) ([]Migration, error) {
+	version, err := fileVersion(path, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.From < version {
+			continue
+		}
+		if err := migration.Apply(root); err != nil {
+			return applied, fmt.Errorf("failed to migrate config file %s to version %d: %w", path, migration.From+1, err)
+		}
+		version = migration.From + 1
+		setVersion(root, version)
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// setVersion sets the version key of a config file, first in the file when
+// it is added
+func setVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			root.Content[i+1] = value
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		// Comments at the top of the file stay there
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
+
+// backupConfigFile copies the original contents of a config file next to it
+// before it is migrated, and returns the path of the copy. A counter is added
+// to the name when a backup of the same second exists, so none is replaced.
+func backupConfigFile(path string, version int, data []byte) (string, error) {
+	name := fmt.Sprintf("%s.v%d-%s", path, version, time.Now().Format("20060102-150405"))
+	for n := 1; ; n++ {
+		backupPath := name + ".bak"
+		if n > 1 {
+			backupPath = fmt.Sprintf("%s-%d.bak", name, n)
+		}
+
+		// Claim the name before writing the copy over it
+		file, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to back up config file: %w", err)
+		}
+		file.Close()
+
+		if err := safefile.WriteFile(backupPath, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to back up config file: %w", err)
+		}
+		return backupPath, nil
+	}
+}
+
+// backupOldVersion backs up the config file at path when it exists and is of
+// an older version, before sok rewrites it
+func backupOldVersion(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Files that cannot be read are replaced, like before versions existed
+	root, err := parseMapping(path, data)
+	if err != nil {
+		return nil
+	}
+	// Files of newer versions are not downgraded
+	version, err := fileVersion(path, root)
+	if err != nil || version == CurrentVersion {
+		return err
+	}
+	_, err = backupConfigFile(path, version, data)
+	return err
+}
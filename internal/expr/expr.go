@@ -0,0 +1,533 @@
+// Package expr
+// Description: A small, side effect free expression language for conditions in symlinks.yaml
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expressions support string literals, true and false, variables, env.NAME,
+// function calls, ==, !=, !, &&, || and parentheses, e.g.
+//
+//	os == "linux" && env.WORK == "1" && exists("~/.cargo")
+//
+// Strings are true in a boolean context when they are not empty.
+
+// Context provides the values an expression can refer to
+type Context struct {
+	// Vars are the plain identifiers, holding a string, a bool or a Set
+	Vars map[string]any
+	// Env returns the value of an environment variable for env.NAME
+	Env func(name string) string
+	// Funcs are the callable functions
+	Funcs map[string]func(args ...string) (any, error)
+}
+
+// Set is a variable with several values, like the desktops listed in
+// XDG_CURRENT_DESKTOP. It equals any string it contains, ignoring case, and
+// is true when it is not empty.
+type Set []string
+
+// contains reports whether value is one of the strings of s
+func (s Set) contains(value string) bool {
+	for _, member := range s {
+		if strings.EqualFold(member, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expr is a parsed expression
+type Expr struct {
+	source string
+	root   node
+}
+
+// Error is a parse or evaluation error at a position of the expression
+type Error struct {
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+// Parse parses an expression
+func Parse(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.pos, "unexpected %s", tok)
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// Eval parses and evaluates an expression in one step
+func Eval(source string, ctx Context) (bool, error) {
+	e, err := Parse(source)
+	if err != nil {
+		return false, err
+	}
+	return e.Eval(ctx)
+}
+
+// Eval evaluates the expression to a boolean
+func (e *Expr) Eval(ctx Context) (bool, error) {
+	value, err := e.root.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+func errorAt(pos int, format string, args ...any) *Error {
+	return &Error{Column: pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+// truthy converts a value to a boolean
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case Set:
+		return len(v) > 0
+	}
+	return false
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// tokenize splits source into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			value, end, err := readString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: i})
+			i = end
+		case isIdentStart(c):
+			start := i
+			for i < len(source) && (isIdentStart(source[i]) || isDigit(source[i]) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: source[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "&&", "||", "!", "(", ")", ","} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorAt(i, "unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokenOp, value: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// readString reads the quoted string starting at start and returns its value
+// and the index after the closing quote
+func readString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var value strings.Builder
+
+	for i := start + 1; i < len(source); i++ {
+		switch source[i] {
+		case quote:
+			return value.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(source) {
+				i++
+			}
+		}
+		value.WriteByte(source[i])
+	}
+
+	return "", 0, errorAt(start, "unterminated string")
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.value == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return errorAt(tok.pos, "expected %q, found %s", op, tok)
+	}
+	p.next()
+	return nil
+}
+
+// parseOr parses: and ("||" and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary ("&&" unary)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses: "!" unary | comparison
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses: primary (("==" | "!=") primary)?
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("==") || p.isOp("!=") {
+		tok := p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: tok.value, pos: tok.pos, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// parsePrimary parses literals, variables, calls and parenthesized expressions
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenString:
+		return &literalNode{value: tok.value}, nil
+	case tokenIdent:
+		switch {
+		case tok.value == "true" || tok.value == "false":
+			return &literalNode{value: tok.value == "true"}, nil
+		case p.isOp("("):
+			return p.parseCall(tok)
+		case strings.HasPrefix(tok.value, "env."):
+			return &envNode{name: strings.TrimPrefix(tok.value, "env."), pos: tok.pos}, nil
+		}
+		return &varNode{name: tok.value, pos: tok.pos}, nil
+	case tokenOp:
+		if tok.value == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, errorAt(tok.pos, "unexpected %s", tok)
+}
+
+// parseCall parses the arguments of a function call
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // (
+
+	call := &callNode{name: name.value, pos: name.pos}
+	for !p.isOp(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next() // )
+
+	return call, nil
+}
+
+// Nodes
+
+type node interface {
+	eval(ctx Context) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(Context) (any, error) {
+	return n.value, nil
+}
+
+type varNode struct {
+	name string
+	pos  int
+}
+
+func (n *varNode) eval(ctx Context) (any, error) {
+	value, ok := ctx.Vars[n.name]
+	if !ok {
+		return nil, errorAt(n.pos, "unknown identifier %q", n.name)
+	}
+	return value, nil
+}
+
+type envNode struct {
+	name string
+	pos  int
+}
+
+func (n *envNode) eval(ctx Context) (any, error) {
+	if n.name == "" {
+		return nil, errorAt(n.pos, "missing environment variable name after env.")
+	}
+	if ctx.Env == nil {
+		return "", nil
+	}
+	return ctx.Env(n.name), nil
+}
+
+type callNode struct {
+	name string
+	pos  int
+	args []node
+}
+
+func (n *callNode) eval(ctx Context) (any, error) {
+	fn, ok := ctx.Funcs[n.name]
+	if !ok {
+		return nil, errorAt(n.pos, "unknown function %q", n.name)
+	}
+
+	args := make([]string, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		str, ok := value.(string)
+		if !ok {
+			return nil, errorAt(n.pos, "%s() expects string arguments", n.name)
+		}
+		args = append(args, str)
+	}
+
+	result, err := fn(args...)
+	if err != nil {
+		return nil, errorAt(n.pos, "%s(): %v", n.name, err)
+	}
+	return result, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(ctx Context) (any, error) {
+	value, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(ctx Context) (any, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit so the right side is only evaluated when needed
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	pos         int
+	left, right node
+}
+
+func (n *compareNode) eval(ctx Context) (any, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	equal, ok := equals(left, right)
+	if !ok {
+		return nil, errorAt(n.pos, "cannot compare %v and %v", describe(left), describe(right))
+	}
+	if n.op == "!=" {
+		return !equal, nil
+	}
+	return equal, nil
+}
+
+// equals compares two values. A Set equals a string it contains. ok is false
+// when the values cannot be compared.
+func equals(left, right any) (equal, ok bool) {
+	if _, isSet := right.(Set); isSet {
+		left, right = right, left
+	}
+	if set, isSet := left.(Set); isSet {
+		str, isString := right.(string)
+		return isString && set.contains(str), isString
+	}
+	if describe(left) != describe(right) {
+		return false, false
+	}
+	return left == right, true
+}
+
+// describe names the type of a value for error messages
+func describe(value any) string {
+	switch value.(type) {
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case Set:
+		return "a list of strings"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,164 @@
+// Package format
+// Description: This file reads and writes configuration files in YAML, JSON or TOML, chosen by their extension, through yaml nodes so every format decodes into the same types.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a configuration file
+type Format string
+
+// Supported formats
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+	TOML Format = "toml"
+)
+
+// Extensions are the accepted file extensions, in order of preference
+var Extensions = []string{".yaml", ".yml", ".toml", ".json"}
+
+// SyntaxError is a syntax error at a position of a file. Line and Column
+// are 0 when the parser does not report them.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error formats the error as line:column: message
+func (e *SyntaxError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%d: %s", e.Line, e.Message)
+	default:
+		return e.Message
+	}
+}
+
+// yamlErrorPattern matches the position in the errors of the yaml parser
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (?:column (\d+): )?(.*)$`)
+
+// FromPath returns the format of a file by its extension. Files with any
+// other extension are read as YAML, like before other formats existed.
+func FromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".toml":
+		return TOML
+	default:
+		return YAML
+	}
+}
+
+// Parse returns the root node of the document in data, or nil when it is
+// empty. YAML and JSON nodes carry their line and column, TOML nodes do not.
+func Parse(path string, data []byte) (*yaml.Node, error) {
+	switch FromPath(path) {
+	case JSON:
+		// JSON is YAML, but its own parser reports JSON mistakes better
+		if len(bytes.TrimSpace(data)) > 0 && !json.Valid(data) {
+			var v any
+			return nil, jsonSyntaxError(data, json.Unmarshal(data, &v))
+		}
+	case TOML:
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			var parseErr toml.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &SyntaxError{Line: parseErr.Position.Line, Column: parseErr.Position.Col, Message: parseErr.Message}
+			}
+			return nil, err
+		}
+		var root yaml.Node
+		if err := root.Encode(v); err != nil {
+			return nil, err
+		}
+		return &root, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if match := yamlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			column, _ := strconv.Atoi(match[2])
+			return nil, &SyntaxError{Line: line, Column: column, Message: match[3]}
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// jsonSyntaxError locates a JSON syntax error by its byte offset
+func jsonSyntaxError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	// Offset counts the bytes read, the offending one included
+	before := data[:min(max(int(syntaxErr.Offset)-1, 0), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return &SyntaxError{Line: line, Column: column, Message: syntaxErr.Error()}
+}
+
+// Unmarshal decodes data, in the format of path, into out using its yaml tags
+func Unmarshal(path string, data []byte, out any) error {
+	root, err := Parse(path, data)
+	if err != nil || root == nil {
+		return err
+	}
+	return root.Decode(out)
+}
+
+// Marshal encodes v, using its yaml tags, in the format of path
+func Marshal(path string, v any) ([]byte, error) {
+	format := FromPath(path)
+	if format == YAML {
+		return yaml.Marshal(v)
+	}
+
+	// Go through yaml so the keys are the same in every format
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	var generic map[string]any
+	if err := node.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("%s files must hold a mapping: %w", format, err)
+	}
+
+	if format == JSON {
+		data, err := json.MarshalIndent(generic, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
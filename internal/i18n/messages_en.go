@@ -7,16 +7,23 @@ package i18n
 // Message keys
 const (
 	// Common messages
-	MsgErrorLoadingConfig   MessageKey = "error_loading_config"
-	MsgErrorReadingFile     MessageKey = "error_reading_file"
-	MsgErrorParsingYAML     MessageKey = "error_parsing_yaml"
-	MsgErrorCreatingSymlink MessageKey = "error_creating_symlink"
-	MsgErrorRemovingSymlink MessageKey = "error_removing_symlink"
-	MsgErrorCheckingFile    MessageKey = "error_checking_file"
-	MsgErrorReadingSymlink  MessageKey = "error_reading_symlink"
-	MsgFileNotFound         MessageKey = "file_not_found"
-	MsgInvalidBoolValue     MessageKey = "invalid_bool_value"
-	MsgInvalidOS            MessageKey = "invalid_os"
+	MsgErrorLoadingConfig    MessageKey = "error_loading_config"
+	MsgErrorReadingFile      MessageKey = "error_reading_file"
+	MsgErrorParsingYAML      MessageKey = "error_parsing_yaml"
+	MsgErrorCreatingSymlink  MessageKey = "error_creating_symlink"
+	MsgErrorRemovingSymlink  MessageKey = "error_removing_symlink"
+	MsgErrorCheckingFile     MessageKey = "error_checking_file"
+	MsgErrorReadingSymlink   MessageKey = "error_reading_symlink"
+	MsgFileNotFound          MessageKey = "file_not_found"
+	MsgInvalidBoolValue      MessageKey = "invalid_bool_value"
+	MsgInvalidOS             MessageKey = "invalid_os"
+	MsgErrorResolvingLinks   MessageKey = "error_resolving_links"
+	MsgErrorSelectingProfile MessageKey = "error_selecting_profile"
+	MsgErrorLoadingSymlinks  MessageKey = "error_loading_symlinks"
+	MsgValidationFailed      MessageKey = "validation_failed"
+	MsgOverlappingTargets    MessageKey = "overlapping_targets"
+	MsgWaitingForLock        MessageKey = "waiting_for_lock"
+	MsgErrorLocking          MessageKey = "error_locking"
 
 	// Symlinks messages
 	MsgSymlinkFileNotFound    MessageKey = "symlink_file_not_found"
@@ -55,6 +62,27 @@ const (
 	MsgFilteringByOS          MessageKey = "filtering_by_os"
 	MsgUsingCommonLinks       MessageKey = "using_common_links"
 	MsgUsingOSSpecificLinks   MessageKey = "using_os_specific_links"
+	MsgErrorRenderingTemplate MessageKey = "error_rendering_template"
+	MsgDryRunWouldRender      MessageKey = "dry_run_would_render"
+	MsgTemplateRendered       MessageKey = "template_rendered"
+
+	// Copy mode messages
+	MsgErrorLoadingState  MessageKey = "error_loading_state"
+	MsgErrorSavingState   MessageKey = "error_saving_state"
+	MsgErrorCopying       MessageKey = "error_copying"
+	MsgDryRunWouldCopy    MessageKey = "dry_run_would_copy"
+	MsgCopied             MessageKey = "copied"
+	MsgCopyUpToDate       MessageKey = "copy_up_to_date"
+	MsgCopyDrifted        MessageKey = "copy_drifted"
+	MsgCopyUnmanaged      MessageKey = "copy_unmanaged"
+	MsgLocalChanges       MessageKey = "local_changes"
+	MsgLegendDrifted      MessageKey = "legend_drifted"
+	MsgPullConfirm        MessageKey = "pull_confirm"
+	MsgPulled             MessageKey = "pulled"
+	MsgDryRunWouldPull    MessageKey = "dry_run_would_pull"
+	MsgCannotPullTemplate MessageKey = "cannot_pull_template"
+	MsgNoLocalChanges     MessageKey = "no_local_changes"
+	MsgPullSummary        MessageKey = "pull_summary"
 
 	// Rollback messages
 	MsgRollbackStarting  MessageKey = "rollback_starting"
@@ -88,6 +116,8 @@ const (
 	MsgBackupInProgress      MessageKey = "backup_in_progress"
 	MsgBackupComplete        MessageKey = "backup_complete"
 	MsgBackupFailed          MessageKey = "backup_failed"
+	MsgBackupsPruned         MessageKey = "backups_pruned"
+	MsgErrorPruningBackups   MessageKey = "error_pruning_backups"
 	MsgBackingUpFile         MessageKey = "backing_up_file"
 
 	// Apply messages
@@ -148,21 +178,68 @@ const (
 
 	// Version messages
 	MsgVersion MessageKey = "version"
+
+	// Symlinks file issues
+	MsgIssueError             MessageKey = "issue_error"
+	MsgIssueWarning           MessageKey = "issue_warning"
+	MsgIssueOnOS              MessageKey = "issue_on_os"
+	MsgIssueProfile           MessageKey = "issue_profile"
+	MsgSyntaxError            MessageKey = "syntax_error"
+	MsgExpectedEntries        MessageKey = "expected_entries"
+	MsgExpectedEntry          MessageKey = "expected_entry"
+	MsgInvalidEntry           MessageKey = "invalid_entry"
+	MsgIncludeWithOtherKeys   MessageKey = "include_with_other_keys"
+	MsgIncludeCycle           MessageKey = "include_cycle"
+	MsgIncludeTopLevel        MessageKey = "include_top_level"
+	MsgIncludeDuplicate       MessageKey = "include_duplicate"
+	MsgIncludeUnreadable      MessageKey = "include_unreadable"
+	MsgInvalidInclude         MessageKey = "invalid_include"
+	MsgIncludeNotFound        MessageKey = "include_not_found"
+	MsgUnknownKey             MessageKey = "unknown_key"
+	MsgUnknownKeySuggestion   MessageKey = "unknown_key_suggestion"
+	MsgOr                     MessageKey = "or"
+	MsgWrongType              MessageKey = "wrong_type"
+	MsgValueNotAllowed        MessageKey = "value_not_allowed"
+	MsgMissingKey             MessageKey = "missing_key"
+	MsgEmptySource            MessageKey = "empty_source"
+	MsgTypeString             MessageKey = "type_string"
+	MsgTypeBoolean            MessageKey = "type_boolean"
+	MsgTypeInteger            MessageKey = "type_integer"
+	MsgTypeNumber             MessageKey = "type_number"
+	MsgTypeObject             MessageKey = "type_object"
+	MsgTypeArray              MessageKey = "type_array"
+	MsgTypeNull               MessageKey = "type_null"
+	MsgDuplicateTargetInEntry MessageKey = "duplicate_target_in_entry"
+	MsgDuplicateTarget        MessageKey = "duplicate_target"
+	MsgSourceNotFound         MessageKey = "source_not_found"
+	MsgTargetInDotfiles       MessageKey = "target_in_dotfiles"
+	MsgLinkCycle              MessageKey = "link_cycle"
+	MsgDuplicateEntryName     MessageKey = "duplicate_entry_name"
+	MsgDependencyCycle        MessageKey = "dependency_cycle"
+	MsgNestedTarget           MessageKey = "nested_target"
+	MsgResolvesIntoDotfiles   MessageKey = "resolves_into_dotfiles"
 )
 
 func getEnglishMessages() map[MessageKey]string {
 	return map[MessageKey]string{
 		// Common messages
-		MsgErrorLoadingConfig:   "Error loading configuration: %v",
-		MsgErrorReadingFile:     "Error reading file: %v",
-		MsgErrorParsingYAML:     "Error parsing YAML: %v",
-		MsgErrorCreatingSymlink: "Error creating symlink from %s to %s: %v",
-		MsgErrorRemovingSymlink: "Error removing symlink at %s: %v",
-		MsgErrorCheckingFile:    "Error checking file at %s: %v",
-		MsgErrorReadingSymlink:  "Error reading symlink at %s: %v",
-		MsgFileNotFound:         "File not found: %s",
-		MsgInvalidBoolValue:     "Invalid boolean value. Use 'true' or 'false'",
-		MsgInvalidOS:            "Invalid OS '%s'. Valid options are: linux, darwin, windows",
+		MsgErrorLoadingConfig:    "Error loading configuration: %v",
+		MsgErrorReadingFile:      "Error reading file: %v",
+		MsgErrorParsingYAML:      "Error parsing YAML: %v",
+		MsgErrorCreatingSymlink:  "Error creating symlink from %s to %s: %v",
+		MsgErrorRemovingSymlink:  "Error removing symlink at %s: %v",
+		MsgErrorCheckingFile:     "Error checking file at %s: %v",
+		MsgErrorReadingSymlink:   "Error reading symlink at %s: %v",
+		MsgFileNotFound:          "File not found: %s",
+		MsgInvalidBoolValue:      "Invalid boolean value. Use 'true' or 'false'",
+		MsgInvalidOS:             "Invalid OS '%s'. Valid options are: linux, darwin, windows",
+		MsgErrorResolvingLinks:   "Error resolving links: %v",
+		MsgErrorSelectingProfile: "Error selecting profile: %v",
+		MsgErrorLoadingSymlinks:  "Error loading symlinks file: %v",
+		MsgValidationFailed:      "Validation failed with %d error(s), fix them or use --skip-validation",
+		MsgOverlappingTargets:    "%d target(s) would be written through another link into the dotfiles repository, nothing was changed",
+		MsgWaitingForLock:        "Waiting for another sok process to finish (%s)...",
+		MsgErrorLocking:          "Error locking sok's files: %v",
 
 		// Symlinks messages
 		MsgSymlinkFileNotFound:    "Symlinks file not found: %s\nPlease create the file or update the configuration with: sok config symlinkfile <path>",
@@ -201,6 +278,27 @@ func getEnglishMessages() map[MessageKey]string {
 		MsgFilteringByOS:          "Filtering symlinks for OS: %s",
 		MsgUsingCommonLinks:       "Using common links (all OS)",
 		MsgUsingOSSpecificLinks:   "Using OS-specific links for: %s",
+		MsgErrorRenderingTemplate: "Error rendering template %v",
+		MsgDryRunWouldRender:      "[DRY-RUN] Would render template: %s <- %s",
+		MsgTemplateRendered:       "Template rendered: %s -> %s",
+
+		// Copy mode messages
+		MsgErrorLoadingState:  "Error loading state: %v",
+		MsgErrorSavingState:   "Error saving state: %v",
+		MsgErrorCopying:       "Error writing %s from %s: %v",
+		MsgDryRunWouldCopy:    "[DRY-RUN] Would %s: %s => %s",
+		MsgCopied:             "Materialized (%s): %s => %s",
+		MsgCopyUpToDate:       "Target is up to date: %s",
+		MsgCopyDrifted:        "%s has local changes, skipping (run 'sok symlinks pull' to keep them)",
+		MsgCopyUnmanaged:      "%s exists and is not managed by sokru, skipping",
+		MsgLocalChanges:       "Local changes:         %d",
+		MsgLegendDrifted:      "✏️  = Copied file has local changes (=> marks copy and hardlink targets)",
+		MsgPullConfirm:        "Pull local changes from %s into %s? [y/N] ",
+		MsgPulled:             "Pulled local changes: %s -> %s",
+		MsgDryRunWouldPull:    "[DRY-RUN] Would pull local changes: %s -> %s",
+		MsgCannotPullTemplate: "%s is rendered from a template, edit %s instead",
+		MsgNoLocalChanges:     "No local changes to pull",
+		MsgPullSummary:        "Pulled: %d file(s)",
 
 		// Rollback messages
 		MsgRollbackStarting:  "Error occurred, starting rollback of %d action(s)...",
@@ -234,6 +332,8 @@ func getEnglishMessages() map[MessageKey]string {
 		MsgBackupInProgress:      "Creating backup...",
 		MsgBackupComplete:        "Backup completed: %s",
 		MsgBackupFailed:          "Backup failed: %v",
+		MsgBackupsPruned:         "Deleted %d old backup(s)",
+		MsgErrorPruningBackups:   "Error deleting old backups: %v",
 		MsgBackingUpFile:         "Backing up: %s",
 
 		// Apply messages
@@ -294,5 +394,45 @@ func getEnglishMessages() map[MessageKey]string {
 
 		// Version messages
 		MsgVersion: "Sok v0.1 -- HEAD",
+
+		// Symlinks file issues
+		MsgIssueError:             "error",
+		MsgIssueWarning:           "warning",
+		MsgIssueOnOS:              "on %s",
+		MsgIssueProfile:           "profile %s",
+		MsgSyntaxError:            "syntax error: %s",
+		MsgExpectedEntries:        "expected a list of entries",
+		MsgExpectedEntry:          "an entry must be a mapping, not %s",
+		MsgInvalidEntry:           "invalid entry: %v",
+		MsgIncludeWithOtherKeys:   "include cannot be combined with other keys",
+		MsgIncludeCycle:           "include cycle: %s",
+		MsgIncludeTopLevel:        "%s is the top level symlinks file",
+		MsgIncludeDuplicate:       "%s is already included from %s",
+		MsgIncludeUnreadable:      "cannot read included file: %v",
+		MsgInvalidInclude:         "invalid include %q: %v",
+		MsgIncludeNotFound:        "included file %s does not exist",
+		MsgUnknownKey:             "unknown key %q",
+		MsgUnknownKeySuggestion:   "unknown key %q, did you mean %s?",
+		MsgOr:                     "or",
+		MsgWrongType:              "%q must be %s, not %s",
+		MsgValueNotAllowed:        "%q must be one of %s, not %q",
+		MsgMissingKey:             "%q is required",
+		MsgEmptySource:            "empty source for target %s",
+		MsgTypeString:             "a string",
+		MsgTypeBoolean:            "true or false",
+		MsgTypeInteger:            "an integer",
+		MsgTypeNumber:             "a number",
+		MsgTypeObject:             "a mapping",
+		MsgTypeArray:              "a list",
+		MsgTypeNull:               "empty",
+		MsgDuplicateTargetInEntry: "duplicate target %s, also defined at line %d",
+		MsgDuplicateTarget:        "duplicate target %s, also defined at %s",
+		MsgSourceNotFound:         "source %s does not exist",
+		MsgTargetInDotfiles:       "target %s is inside the dotfiles directory %s",
+		MsgLinkCycle:              "link cycle: %s",
+		MsgDuplicateEntryName:     "entry name %q is already used at %s",
+		MsgDependencyCycle:        "dependency cycle between entries: %s",
+		MsgNestedTarget:           "target %s is nested under %s, managed at %s",
+		MsgResolvesIntoDotfiles:   "target %s resolves into the dotfiles directory as %s",
 	}
 }
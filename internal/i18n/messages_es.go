@@ -17,6 +17,13 @@ func getSpanishMessages() map[MessageKey]string {
 		MsgFileNotFound:         "Archivo no encontrado: %s",
 		MsgInvalidBoolValue:     "Valor booleano inválido. Use 'true' o 'false'",
 		MsgInvalidOS:            "SO inválido '%s'. Las opciones válidas son: linux, darwin, windows",
+		MsgErrorResolvingLinks:  "Error al resolver enlaces: %v",
+		MsgErrorSelectingProfile: "Error al seleccionar el perfil: %v",
+		MsgErrorLoadingSymlinks: "Error al cargar el archivo de enlaces: %v",
+		MsgValidationFailed: "La validación falló con %d error(es), corrígelos o usa --skip-validation",
+		MsgOverlappingTargets: "%d destino(s) se escribirían a través de otro enlace dentro del repositorio de dotfiles, no se cambió nada",
+		MsgWaitingForLock: "Esperando a que termine otro proceso de sok (%s)...",
+		MsgErrorLocking: "Error al bloquear los archivos de sok: %v",
 
 		// Symlinks messages
 		MsgSymlinkFileNotFound:    "Archivo de enlaces simbólicos no encontrado: %s\nPor favor cree el archivo o actualice la configuración con: sok config symlinkfile <ruta>",
@@ -55,6 +62,27 @@ func getSpanishMessages() map[MessageKey]string {
 		MsgFilteringByOS:          "Filtrando enlaces simbólicos para SO: %s",
 		MsgUsingCommonLinks:       "Usando enlaces comunes (todos los SO)",
 		MsgUsingOSSpecificLinks:   "Usando enlaces específicos del SO para: %s",
+		MsgErrorRenderingTemplate: "Error al renderizar plantilla %v",
+		MsgDryRunWouldRender:      "[SIMULACIÓN] Se renderizaría plantilla: %s <- %s",
+		MsgTemplateRendered:       "Plantilla renderizada: %s -> %s",
+
+		// Copy mode messages
+		MsgErrorLoadingState:  "Error al cargar el estado: %v",
+		MsgErrorSavingState:   "Error al guardar el estado: %v",
+		MsgErrorCopying:       "Error al escribir %s desde %s: %v",
+		MsgDryRunWouldCopy:    "[SIMULACIÓN] Se haría %s: %s => %s",
+		MsgCopied:             "Materializado (%s): %s => %s",
+		MsgCopyUpToDate:       "El destino está actualizado: %s",
+		MsgCopyDrifted:        "%s tiene cambios locales, omitiendo (ejecute 'sok symlinks pull' para conservarlos)",
+		MsgCopyUnmanaged:      "%s existe y no es administrado por sokru, omitiendo",
+		MsgLocalChanges:       "Cambios locales:             %d",
+		MsgLegendDrifted:      "✏️  = El archivo copiado tiene cambios locales (=> indica destinos copiados o enlazados)",
+		MsgPullConfirm:        "¿Traer los cambios locales de %s a %s? [s/N] ",
+		MsgPulled:             "Cambios locales traídos: %s -> %s",
+		MsgDryRunWouldPull:    "[SIMULACIÓN] Se traerían los cambios locales: %s -> %s",
+		MsgCannotPullTemplate: "%s se genera desde una plantilla, edite %s en su lugar",
+		MsgNoLocalChanges:     "No hay cambios locales para traer",
+		MsgPullSummary:        "Traídos: %d archivo(s)",
 		
 		// Rollback messages
 		MsgRollbackStarting:       "Ocurrió un error, iniciando reversión de %d acción(es)...",
@@ -88,6 +116,8 @@ func getSpanishMessages() map[MessageKey]string {
 		MsgBackupInProgress:       "Creando respaldo...",
 		MsgBackupComplete:         "Respaldo completado: %s",
 		MsgBackupFailed:           "Respaldo fallido: %v",
+		MsgBackupsPruned: "Se eliminaron %d respaldo(s) antiguo(s)",
+		MsgErrorPruningBackups: "Error al eliminar respaldos antiguos: %v",
 		MsgBackingUpFile:          "Respaldando: %s",
 
 		// Apply messages
@@ -148,5 +178,45 @@ func getSpanishMessages() map[MessageKey]string {
 
 		// Version messages
 		MsgVersion: "Sok v0.1 -- HEAD",
+
+		// Symlinks file issues
+		MsgIssueError: "error",
+		MsgIssueWarning: "advertencia",
+		MsgIssueOnOS: "en %s",
+		MsgIssueProfile: "perfil %s",
+		MsgSyntaxError: "error de sintaxis: %s",
+		MsgExpectedEntries: "se esperaba una lista de entradas",
+		MsgExpectedEntry: "una entrada debe ser un mapa, no %s",
+		MsgInvalidEntry: "entrada inválida: %v",
+		MsgIncludeWithOtherKeys: "include no se puede combinar con otras claves",
+		MsgIncludeCycle: "ciclo de inclusión: %s",
+		MsgIncludeTopLevel: "%s es el archivo de enlaces principal",
+		MsgIncludeDuplicate: "%s ya está incluido desde %s",
+		MsgIncludeUnreadable: "no se puede leer el archivo incluido: %v",
+		MsgInvalidInclude: "include inválido %q: %v",
+		MsgIncludeNotFound: "el archivo incluido %s no existe",
+		MsgUnknownKey: "clave desconocida %q",
+		MsgUnknownKeySuggestion: "clave desconocida %q, ¿quisiste decir %s?",
+		MsgOr: "o",
+		MsgWrongType: "%q debe ser %s, no %s",
+		MsgValueNotAllowed: "%q debe ser uno de %s, no %q",
+		MsgMissingKey: "%q es obligatorio",
+		MsgEmptySource: "origen vacío para el destino %s",
+		MsgTypeString: "un texto",
+		MsgTypeBoolean: "true o false",
+		MsgTypeInteger: "un entero",
+		MsgTypeNumber: "un número",
+		MsgTypeObject: "un mapa",
+		MsgTypeArray: "una lista",
+		MsgTypeNull: "vacío",
+		MsgDuplicateTargetInEntry: "destino duplicado %s, también definido en la línea %d",
+		MsgDuplicateTarget: "destino duplicado %s, también definido en %s",
+		MsgSourceNotFound: "el origen %s no existe",
+		MsgTargetInDotfiles: "el destino %s está dentro del directorio de dotfiles %s",
+		MsgLinkCycle: "ciclo de enlaces: %s",
+		MsgDuplicateEntryName: "el nombre de entrada %q ya se usa en %s",
+		MsgDependencyCycle: "ciclo de dependencias entre entradas: %s",
+		MsgNestedTarget: "el destino %s está dentro de %s, gestionado en %s",
+		MsgResolvesIntoDotfiles: "el destino %s se resuelve dentro del directorio de dotfiles como %s",
 	}
 }
@@ -0,0 +1,228 @@
+// Package paths
+// Description: Path expansion for home directories, environment variables and XDG base directories
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrUndefinedVariable is returned when a path references an environment
+// variable that is not set and has no default
+var ErrUndefinedVariable = errors.New("undefined variable")
+
+// xdgDefaults holds the XDG base directories relative to the home directory,
+// used when the corresponding environment variable is not set
+var xdgDefaults = map[string]string{
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_DATA_HOME":   filepath.Join(".local", "share"),
+	"XDG_STATE_HOME":  filepath.Join(".local", "state"),
+	"XDG_CACHE_HOME":  ".cache",
+	"XDG_BIN_HOME":    filepath.Join(".local", "bin"),
+}
+
+// Expander expands paths. The zero value is not usable, use NewExpander.
+type Expander struct {
+	// LookupEnv looks up an environment variable
+	LookupEnv func(key string) (string, bool)
+	// HomeDir returns the current user's home directory
+	HomeDir func() (string, error)
+	// UserHomeDir returns the home directory of the named user
+	UserHomeDir func(name string) (string, error)
+	// Windows enables %VAR% expansion and backslash separators after ~
+	Windows bool
+}
+
+// NewExpander returns an Expander backed by the process environment
+func NewExpander() *Expander {
+	return &Expander{
+		LookupEnv: os.LookupEnv,
+		HomeDir:   os.UserHomeDir,
+		UserHomeDir: func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.HomeDir, nil
+		},
+		Windows: runtime.GOOS == "windows",
+	}
+}
+
+// Expand expands path using the process environment
+func Expand(path string) (string, error) {
+	return NewExpander().Expand(path)
+}
+
+// Expand expands a leading ~ or ~user, $VAR, ${VAR} and ${VAR:-default}
+// references and, on Windows, %VAR% references. XDG base directory variables
+// fall back to their spec defaults when unset. Referencing any other unset
+// variable without a default is an error.
+func (e *Expander) Expand(path string) (string, error) {
+	home, rest, err := e.splitTilde(path)
+	if err != nil {
+		return "", err
+	}
+
+	expanded, err := e.expandVars(rest)
+	if err != nil {
+		return "", err
+	}
+
+	if home == "" {
+		return expanded, nil
+	}
+	return filepath.Join(home, expanded), nil
+}
+
+// splitTilde resolves a leading ~ or ~user and returns the home directory and
+// the remainder of the path. home is empty when path has no tilde prefix.
+func (e *Expander) splitTilde(path string) (string, string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return "", path, nil
+	}
+
+	end := strings.IndexFunc(path, e.isSeparator)
+	if end == -1 {
+		end = len(path)
+	}
+
+	name := path[1:end]
+	rest := strings.TrimLeftFunc(path[end:], e.isSeparator)
+
+	if name == "" {
+		home, err := e.HomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return home, rest, nil
+	}
+
+	home, err := e.UserHomeDir(name)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get home directory of user %q: %w", name, err)
+	}
+	return home, rest, nil
+}
+
+// isSeparator reports whether r separates the ~user prefix from the path
+func (e *Expander) isSeparator(r rune) bool {
+	return r == '/' || (e.Windows && r == '\\')
+}
+
+// expandVars expands variable references in s
+func (e *Expander) expandVars(s string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '$' && i+1 < len(s) && s[i+1] == '$':
+			// $$ is a literal dollar sign
+			b.WriteByte('$')
+			i++
+
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			value, err := e.expandBraced(s[i+2 : i+2+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end + 2
+
+		case c == '$':
+			n := nameLength(s[i+1:])
+			if n == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			value, err := e.lookup(s[i+1:i+1+n], nil)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += n
+
+		case c == '%' && e.Windows:
+			end := strings.IndexByte(s[i+1:], '%')
+			if end <= 0 || nameLength(s[i+1:i+1+end]) != end {
+				b.WriteByte(c)
+				continue
+			}
+			value, err := e.lookup(s[i+1:i+1+end], nil)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end + 1
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// expandBraced expands the inside of a ${...} reference
+func (e *Expander) expandBraced(expr string) (string, error) {
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	if nameLength(name) != len(name) || name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+	}
+	if !hasDefault {
+		return e.lookup(name, nil)
+	}
+	return e.lookup(name, &def)
+}
+
+// lookup returns the value of a variable. An empty or unset variable uses
+// def when given; XDG variables fall back to their defaults.
+func (e *Expander) lookup(name string, def *string) (string, error) {
+	if value, ok := e.LookupEnv(name); ok && value != "" {
+		return value, nil
+	}
+
+	if def != nil {
+		return e.Expand(*def)
+	}
+
+	if name == "HOME" {
+		return e.HomeDir()
+	}
+
+	if rel, ok := xdgDefaults[name]; ok {
+		home, err := e.HomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, rel), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, name)
+}
+
+// nameLength returns the length of the variable name at the start of s
+func nameLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return i
+		}
+	}
+	return len(s)
+}
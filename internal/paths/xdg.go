@@ -0,0 +1,81 @@
+// Package paths
+// Description: This file places sok's own files under the XDG base directories, or under a single directory given with --home.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// App names the directories of sok under each XDG base directory
+const App = "sokru"
+
+// The functions below take home, the directory given with --home. When it
+// is set every file of sok is kept directly in it, instead of the XDG base
+// directories.
+
+// ConfigDir returns the directory of the config file, $XDG_CONFIG_HOME/sokru
+// or home
+func ConfigDir(home string) (string, error) {
+	return appDir(home, "XDG_CONFIG_HOME")
+}
+
+// StateDir returns the directory of state that should survive restarts but
+// is not worth backing up, like state.json, $XDG_STATE_HOME/sokru or home
+func StateDir(home string) (string, error) {
+	return appDir(home, "XDG_STATE_HOME")
+}
+
+// DataDir returns the directory of the data sok keeps for the user, like
+// backups and rendered templates, $XDG_DATA_HOME/sokru or home
+func DataDir(home string) (string, error) {
+	return appDir(home, "XDG_DATA_HOME")
+}
+
+// LockFile returns the file sok processes lock while they change the config,
+// state or backups, sok.lock in stateDir
+func LockFile(stateDir string) string {
+	return filepath.Join(stateDir, "sok.lock")
+}
+
+// appDir returns the sokru directory under an XDG base directory. Relative
+// values of the variable are ignored, as the spec requires.
+func appDir(home, variable string) (string, error) {
+	if home != "" {
+		return home, nil
+	}
+
+	if base := os.Getenv(variable); filepath.IsAbs(base) {
+		return filepath.Join(base, App), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, xdgDefaults[variable], App), nil
+}
+
+// Locate returns the path of name in dir. While name only exists in
+// ~/.config/sokru, where sok kept all of its files before it followed the XDG
+// spec, it returns that one so existing backups and state are still found.
+// With a home there are no older files to find.
+func Locate(home, dir, name string) string {
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil || home != "" {
+		return path
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	legacy := filepath.Join(homeDir, ".config", App, name)
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	return path
+}
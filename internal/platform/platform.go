@@ -0,0 +1,170 @@
+// Package platform
+// Description: Detection of the machine properties symlinks.yaml entries can select on
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package platform
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// Info describes the machine links are resolved for
+type Info struct {
+	OS       string
+	Hostname string
+	Arch     string
+	// Distro is the ID from /etc/os-release and DistroLike its ID_LIKE
+	Distro     string
+	DistroLike []string
+	// WSL is true when running under the Windows Subsystem for Linux
+	WSL bool
+	// Desktops are the entries of XDG_CURRENT_DESKTOP
+	Desktops []string
+}
+
+// Detect returns the properties of the current machine. osName overrides
+// the detected OS so links follow the configured one.
+func Detect(osName string) Info {
+	if osName == "" {
+		osName = runtime.GOOS
+	}
+
+	hostname, _ := os.Hostname()
+
+	info := Info{
+		OS:       osName,
+		Hostname: strings.ToLower(hostname),
+		Arch:     runtime.GOARCH,
+		Desktops: splitList(os.Getenv("XDG_CURRENT_DESKTOP"), ":"),
+	}
+
+	if runtime.GOOS == "linux" {
+		if file, err := os.Open("/etc/os-release"); err == nil {
+			release := ParseOSRelease(file)
+			file.Close()
+			info.Distro = strings.ToLower(release["ID"])
+			info.DistroLike = splitList(release["ID_LIKE"], " ")
+		}
+		info.WSL = detectWSL()
+	}
+
+	return info
+}
+
+// detectWSL reports whether the kernel is the one shipped with WSL
+func detectWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// ParseOSRelease parses the KEY=value lines of an os-release file
+func ParseOSRelease(r io.Reader) map[string]string {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+
+	return values
+}
+
+// archAliases maps the names used by uname to Go architecture names
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x64":     "amd64",
+	"aarch64": "arm64",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// NormalizeArch returns the Go name of an architecture
+func NormalizeArch(arch string) string {
+	arch = strings.ToLower(arch)
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// MatchArch reports whether the machine has the given architecture
+func (i Info) MatchArch(arch string) bool {
+	return NormalizeArch(arch) == NormalizeArch(i.Arch)
+}
+
+// MatchDistro reports whether the machine runs distro or a distro based on it
+func (i Info) MatchDistro(distro string) bool {
+	distro = strings.ToLower(distro)
+	if distro == i.Distro {
+		return true
+	}
+	for _, like := range i.DistroLike {
+		if distro == like {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDesktop reports whether desktop is one of the current desktops
+func (i Info) MatchDesktop(desktop string) bool {
+	for _, current := range i.Desktops {
+		if strings.EqualFold(desktop, current) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchHost reports whether pattern matches hostname. Patterns use glob
+// syntax and are compared case-insensitively against both the full hostname
+// and its first label, so "build-*" matches "build-01.example.com".
+func MatchHost(pattern, hostname string) (bool, error) {
+	pattern = strings.ToLower(pattern)
+	hostname = strings.ToLower(hostname)
+
+	matched, err := path.Match(pattern, hostname)
+	if err != nil || matched {
+		return matched, err
+	}
+
+	if short, _, found := strings.Cut(hostname, "."); found {
+		return path.Match(pattern, short)
+	}
+	return false, nil
+}
+
+// IsHostPattern reports whether pattern contains glob characters
+func IsHostPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// splitList splits a separated list into lower case, non-empty entries
+func splitList(value, sep string) []string {
+	var result []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+// Package render
+// Description: Rendering of templated dotfiles using text/template
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// Data is the set of values available to templates
+type Data struct {
+	Hostname string
+	OS       string
+	Arch     string
+	User     string
+	Home     string
+	Vars     map[string]string
+}
+
+// NewData returns template data for the current machine. osName overrides
+// the detected OS so templates follow the configured one.
+func NewData(osName string, vars map[string]string) Data {
+	hostname, _ := os.Hostname()
+	home, _ := os.UserHomeDir()
+
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	if osName == "" {
+		osName = runtime.GOOS
+	}
+
+	return Data{
+		Hostname: hostname,
+		OS:       osName,
+		Arch:     runtime.GOARCH,
+		User:     username,
+		Home:     home,
+		Vars:     copyVars(vars),
+	}
+}
+
+// WithVars returns a copy of the data with vars layered over the existing ones
+func (d Data) WithVars(vars map[string]string) Data {
+	merged := copyVars(d.Vars)
+	for key, value := range vars {
+		merged[key] = value
+	}
+	d.Vars = merged
+	return d
+}
+
+// copyVars returns a copy of vars that is never nil
+func copyVars(vars map[string]string) map[string]string {
+	result := make(map[string]string, len(vars))
+	for key, value := range vars {
+		result[key] = value
+	}
+	return result
+}
+
+// Render executes the template file at path with data. Referencing a
+// missing variable is an error.
+func Render(path string, data Data) ([]byte, error) {
+	return RenderFS(fsys.OS{}, path, data)
+}
+
+// RenderFS is like Render, reading the template file from files
+func RenderFS(files fsys.FS, path string, data Data) ([]byte, error) {
+	content, err := files.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": os.Getenv}).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// RenderedPath returns the path inside dir where the output for target is stored
+func RenderedPath(dir, target string) string {
+	sum := sha256.Sum256([]byte(target))
+	name := fmt.Sprintf("%s-%s", filepath.Base(target), hex.EncodeToString(sum[:4]))
+	return filepath.Join(dir, name)
+}
+
+// GetDefaultRenderDir returns the default directory for rendered templates,
+// in $XDG_DATA_HOME/sokru
+func GetDefaultRenderDir() (string, error) {
+	dataDir, err := paths.DataDir("")
+	if err != nil {
+		return "", err
+	}
+	return RenderDir("", dataDir), nil
+}
+
+// RenderDir returns the directory for rendered templates in dataDir, for the
+// --home dir home
+func RenderDir(home, dataDir string) string {
+	return paths.Locate(home, dataDir, "rendered")
+}
+
+// Diff returns the changed lines between oldContent and newContent, prefixed
+// with "- " for removed lines and "+ " for added ones. It is empty when both
+// are equal.
+func Diff(oldContent, newContent []byte) string {
+	if bytes.Equal(oldContent, newContent) {
+		return ""
+	}
+
+	a := splitLines(oldContent)
+	b := splitLines(newContent)
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+
+	return out.String()
+}
+
+// splitLines splits content into lines without trailing newline characters
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
@@ -0,0 +1,39 @@
+// Package render
+// Description: Unit tests for how content is split into lines before it is compared.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "empty", content: "", want: nil},
+		{name: "single newline", content: "\n", want: []string{""}},
+		{name: "trailing newline", content: "a\nb\n", want: []string{"a", "b"}},
+		{name: "no trailing newline", content: "a\nb", want: []string{"a", "b"}},
+		{name: "blank line kept", content: "a\n\nb\n", want: []string{"a", "", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitLines([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitLines(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffMissingNewline(t *testing.T) {
+	// Only the final newline differs, the lines are the same
+	if got := Diff([]byte("a\nb"), []byte("a\nb\n")); got != "" {
+		t.Errorf("Diff() = %q, want no changed lines", got)
+	}
+}
@@ -6,7 +6,10 @@ package rollback
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+
+	"github.com/alexlm78/sokru/pkg/fsys"
 )
 
 // SymlinkAction represents an action performed on a symlink
@@ -14,8 +17,11 @@ type SymlinkAction struct {
 	Type         ActionType
 	TargetPath   string
 	SourcePath   string
-	PreviousLink string // For updates, stores the previous symlink target
-	WasSymlink   bool   // Whether the target was a symlink before
+	PreviousLink string      // For updates, stores the previous symlink target
+	WasSymlink   bool        // Whether the target was a symlink before
+	Previous     []byte      // For written files, the previous content
+	Perm         fs.FileMode // For written files, the previous permissions
+	Existed      bool        // For written files, whether the file existed before
 }
 
 // ActionType represents the type of action performed
@@ -25,19 +31,27 @@ const (
 	ActionCreated ActionType = iota // New symlink created
 	ActionUpdated                   // Existing symlink updated
 	ActionRemoved                   // Symlink removed
+	ActionWritten                   // Regular file written
 )
 
 // Tracker tracks symlink operations for rollback
 type Tracker struct {
 	actions []SymlinkAction
 	enabled bool
+	fsys    fsys.FS
 }
 
-// NewTracker creates a new rollback tracker
+// NewTracker creates a new rollback tracker on the real filesystem
 func NewTracker() *Tracker {
+	return NewTrackerFS(fsys.OS{})
+}
+
+// NewTrackerFS creates a new rollback tracker that undoes actions on fsys
+func NewTrackerFS(fsys fsys.FS) *Tracker {
 	return &Tracker{
 		actions: make([]SymlinkAction, 0),
 		enabled: true,
+		fsys:    fsys,
 	}
 }
 
@@ -98,6 +112,22 @@ func (t *Tracker) TrackRemoved(targetPath, sourcePath string) {
 	})
 }
 
+// TrackWritten records a regular file write, keeping its previous content
+// and permissions
+func (t *Tracker) TrackWritten(targetPath string, previous []byte, perm fs.FileMode, existed bool) {
+	if !t.enabled {
+		return
+	}
+
+	t.actions = append(t.actions, SymlinkAction{
+		Type:       ActionWritten,
+		TargetPath: targetPath,
+		Previous:   previous,
+		Perm:       perm.Perm(),
+		Existed:    existed,
+	})
+}
+
 // GetActions returns all tracked actions
 func (t *Tracker) GetActions() []SymlinkAction {
 	return t.actions
@@ -123,21 +153,21 @@ func (t *Tracker) Rollback() error {
 		switch action.Type {
 		case ActionCreated:
 			// Remove the created symlink
-			if err := os.Remove(action.TargetPath); err != nil && !os.IsNotExist(err) {
+			if err := t.fsys.Remove(action.TargetPath); err != nil && !os.IsNotExist(err) {
 				errors = append(errors, fmt.Errorf("failed to remove %s: %w", action.TargetPath, err))
 			}
 
 		case ActionUpdated:
 			// Restore the previous symlink
 			// First remove the current one
-			if err := os.Remove(action.TargetPath); err != nil && !os.IsNotExist(err) {
+			if err := t.fsys.Remove(action.TargetPath); err != nil && !os.IsNotExist(err) {
 				errors = append(errors, fmt.Errorf("failed to remove %s: %w", action.TargetPath, err))
 				continue
 			}
 
 			// Recreate the previous symlink
 			if action.PreviousLink != "" {
-				if err := os.Symlink(action.PreviousLink, action.TargetPath); err != nil {
+				if err := t.fsys.Symlink(action.PreviousLink, action.TargetPath); err != nil {
 					errors = append(errors, fmt.Errorf("failed to restore %s -> %s: %w",
 						action.TargetPath, action.PreviousLink, err))
 				}
@@ -145,10 +175,26 @@ func (t *Tracker) Rollback() error {
 
 		case ActionRemoved:
 			// Recreate the removed symlink
-			if err := os.Symlink(action.SourcePath, action.TargetPath); err != nil {
+			if err := t.fsys.Symlink(action.SourcePath, action.TargetPath); err != nil {
 				errors = append(errors, fmt.Errorf("failed to recreate %s -> %s: %w",
 					action.TargetPath, action.SourcePath, err))
 			}
+
+		case ActionWritten:
+			// Remove the written file first so a hard linked source is never
+			// written through, then restore the previous content if any
+			if err := t.fsys.Remove(action.TargetPath); err != nil && !os.IsNotExist(err) {
+				errors = append(errors, fmt.Errorf("failed to remove %s: %w", action.TargetPath, err))
+				continue
+			}
+			if action.Existed {
+				// Chmod as well, the umask may have dropped bits of the mode
+				if err := t.fsys.WriteFile(action.TargetPath, action.Previous, action.Perm); err != nil {
+					errors = append(errors, fmt.Errorf("failed to restore %s: %w", action.TargetPath, err))
+				} else if err := t.fsys.Chmod(action.TargetPath, action.Perm); err != nil {
+					errors = append(errors, fmt.Errorf("failed to restore the mode of %s: %w", action.TargetPath, err))
+				}
+			}
 		}
 	}
 
@@ -0,0 +1,110 @@
+// Package rollback
+// Description: Unit tests for how written files are rolled back, content and permissions.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package rollback
+
+import (
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+func TestRollbackWrittenRestoresMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode fs.FileMode
+		want fs.FileMode
+	}{
+		{name: "executable", mode: 0755, want: 0755},
+		{name: "private", mode: 0600, want: 0600},
+		{name: "type bits are dropped", mode: fs.ModeSetuid | 0640, want: 0640},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := fsys.NewMemFS()
+			if err := mem.WriteFile("/file", []byte("new\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			tracker := NewTrackerFS(mem)
+			tracker.TrackWritten("/file", []byte("old\n"), tt.mode, true)
+			if perm := tracker.GetActions()[0].Perm; perm != tt.want {
+				t.Errorf("tracked Perm = %v, want %v", perm, tt.want)
+			}
+			if err := tracker.Rollback(); err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+
+			info, err := mem.Stat("/file")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.want {
+				t.Errorf("mode after Rollback() = %v, want %v", info.Mode().Perm(), tt.want)
+			}
+			if content, _ := mem.ReadFile("/file"); string(content) != "old\n" {
+				t.Errorf("content after Rollback() = %q, want the old content", content)
+			}
+		})
+	}
+}
+
+func TestRollbackWrittenFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault fsys.Fault
+		want  string
+	}{
+		{
+			name:  "content cannot be restored",
+			fault: fsys.Fault{Op: fsys.OpWriteFile, Err: syscall.ENOSPC},
+			want:  "",
+		},
+		{
+			name:  "mode cannot be restored",
+			fault: fsys.Fault{Op: fsys.OpChmod, Err: syscall.EPERM},
+			want:  "old\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := fsys.NewMemFS()
+			if err := mem.WriteFile("/file", []byte("new\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			tracker := NewTrackerFS(fsys.NewFaulty(mem, tt.fault))
+			tracker.TrackWritten("/file", []byte("old\n"), 0600, true)
+			if err := tracker.Rollback(); err == nil {
+				t.Error("Rollback() error = nil, want the injected failure")
+			}
+
+			content, _ := mem.ReadFile("/file")
+			if string(content) != tt.want {
+				t.Errorf("content after Rollback() = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackWrittenNewFile(t *testing.T) {
+	// Files that did not exist are removed, whatever their mode
+	mem := fsys.NewMemFS()
+	if err := mem.WriteFile("/file", []byte("new\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewTrackerFS(mem)
+	tracker.TrackWritten("/file", nil, 0, false)
+	if err := tracker.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := mem.Lstat("/file"); err == nil {
+		t.Error("new file still exists after Rollback()")
+	}
+}
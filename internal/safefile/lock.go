@@ -0,0 +1,137 @@
+// Package safefile
+// Description: This file holds advisory locks on files, so concurrent sok processes take turns changing its files.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package safefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrLocked is returned by TryLock when another process, or another
+// goroutine of this one, holds the lock
+var ErrLocked = errors.New("locked by another process")
+
+// Lock is an advisory lock on a file. It serializes sok processes with the
+// file lock of the operating system, and the goroutines of a process with a
+// mutex, so it is not reentrant: taking a lock already held blocks until it
+// is released. The operating system releases it when the process exits, even
+// if it crashes.
+type Lock struct {
+	path string
+	held *heldLock
+}
+
+// held are the locks of this process by path, with the goroutines holding or
+// waiting for them
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]*heldLock)
+)
+
+type heldLock struct {
+	// mu is held by the goroutine holding the lock
+	mu   sync.Mutex
+	file *os.File
+	refs int
+}
+
+// Acquire takes the lock on path, creating the file and its directory if
+// needed, and waits while another process or goroutine holds it
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+// TryLock takes the lock on path like Acquire, but returns ErrLocked instead
+// of waiting
+func TryLock(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+func acquire(path string, wait bool) (*Lock, error) {
+	path = filepath.Clean(path)
+	h := reference(path)
+
+	if wait {
+		h.mu.Lock()
+	} else if !h.mu.TryLock() {
+		unreference(path, h)
+		return nil, ErrLocked
+	}
+
+	file, err := lockPath(path, wait)
+	if err != nil {
+		h.mu.Unlock()
+		unreference(path, h)
+		return nil, err
+	}
+	h.file = file
+	return &Lock{path: path, held: h}, nil
+}
+
+// reference returns the lock of path in this process, counting the caller
+func reference(path string) *heldLock {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+
+	h, ok := held[path]
+	if !ok {
+		h = &heldLock{}
+		held[path] = h
+	}
+	h.refs++
+	return h
+}
+
+// unreference forgets the lock of path once no goroutine needs it
+func unreference(path string, h *heldLock) {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+
+	h.refs--
+	if h.refs == 0 {
+		delete(held, path)
+	}
+}
+
+// lockPath opens path and takes its file lock
+func lockPath(path string, wait bool) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(file, wait); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Release gives the lock back. Releasing it again does nothing.
+func (l *Lock) Release() error {
+	h := l.held
+	if h == nil {
+		return nil
+	}
+	l.held = nil
+
+	err := unlockFile(h.file)
+	if closeErr := h.file.Close(); err == nil {
+		err = closeErr
+	}
+	h.file = nil
+	h.mu.Unlock()
+	unreference(l.path, h)
+	return err
+}
@@ -0,0 +1,41 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+// Package safefile
+// Description: Unit tests for the file lock other processes see.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package safefile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLockContention(t *testing.T) {
+	// Another open file of the lock, like another process has, is refused
+	path := filepath.Join(t.TempDir(), "sok.lock")
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	if file, err := lockPath(path, false); !errors.Is(err, ErrLocked) {
+		if file != nil {
+			file.Close()
+		}
+		t.Fatalf("lockPath() while held = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	file, err := lockPath(path, false)
+	if err != nil {
+		t.Fatalf("lockPath() after Release() error = %v", err)
+	}
+	if err := unlockFile(file); err != nil {
+		t.Errorf("unlockFile() error = %v", err)
+	}
+	file.Close()
+}
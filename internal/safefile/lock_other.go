@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+// Package safefile
+// Description: This file stands in for file locks on the systems without flock, where sok does not serialize its processes.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package safefile
+
+import "os"
+
+// lockFile always succeeds, there is no lock to take
+func lockFile(file *os.File, wait bool) error {
+	return nil
+}
+
+// unlockFile always succeeds
+func unlockFile(file *os.File) error {
+	return nil
+}
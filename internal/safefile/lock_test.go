@@ -0,0 +1,88 @@
+// Package safefile
+// Description: Unit tests for how the lock is shared and contended by the goroutines of a process.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package safefile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// refs returns how many goroutines hold or wait for the lock of path
+func refs(path string) int {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	if h, ok := held[path]; ok {
+		return h.refs
+	}
+	return 0
+}
+
+func TestLockContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sok.lock")
+
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if got := refs(path); got != 1 {
+		t.Errorf("refs while held = %d, want 1", got)
+	}
+
+	// A failed TryLock leaves no reference behind
+	errs := make(chan error)
+	go func() {
+		_, err := TryLock(path)
+		errs <- err
+	}()
+	if err := <-errs; !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryLock() while held = %v, want ErrLocked", err)
+	}
+	if got := refs(path); got != 1 {
+		t.Errorf("refs after a failed TryLock = %d, want 1", got)
+	}
+
+	// A waiting goroutine counts until it gets the lock
+	acquired := make(chan *Lock)
+	go func() {
+		waiter, err := Acquire(path)
+		if err != nil {
+			t.Errorf("Acquire() while waiting error = %v", err)
+		}
+		acquired <- waiter
+	}()
+	deadline := time.Now().Add(time.Second)
+	for refs(path) != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := refs(path); got != 2 {
+		t.Fatalf("refs with a waiter = %d, want 2", got)
+	}
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() returned while the lock was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	waiter := <-acquired
+	if got := refs(path); got != 1 {
+		t.Errorf("refs once handed over = %d, want 1", got)
+	}
+
+	// Releasing twice does nothing, and the last release forgets the path
+	if err := lock.Release(); err != nil {
+		t.Errorf("second Release() error = %v", err)
+	}
+	if err := waiter.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if got := refs(path); got != 0 {
+		t.Errorf("refs after every release = %d, want 0", got)
+	}
+}
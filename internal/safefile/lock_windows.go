@@ -0,0 +1,55 @@
+//go:build windows
+
+// Package safefile
+// Description: This file locks files with LockFileEx on Windows.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package safefile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile takes an exclusive lock on the first byte of file, waiting for it
+// when wait is set
+func lockFile(file *os.File, wait bool) error {
+	flags := uintptr(lockfileExclusiveLock)
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(file.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return ErrLocked
+	}
+	return err
+}
+
+// unlockFile releases the lock on file
+func unlockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+// Package schema
+// Description: This file checks yaml nodes against a schema, so mistakes are reported at the line and column they were made.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is the kind of a violation
+type Problem int
+
+const (
+	// WrongType means the node is not of the expected type
+	WrongType Problem = iota
+	// NotAllowed means the value is not one of the enum values
+	NotAllowed
+	// Missing means a required key is not set
+	Missing
+)
+
+// Violation is a node of a document that does not match its schema
+type Violation struct {
+	Problem Problem
+	// Node is the offending node, or the mapping missing a key
+	Node *yaml.Node
+	// Path locates the node in the document, like links[0].mode
+	Path string
+	// Expected lists the expected types, the allowed values or the missing key
+	Expected []string
+	// Got is the type or the value found
+	Got string
+}
+
+// Check returns the violations of node against s. Keys that s does not
+// define are not reported, the caller decides how to handle them. Null values
+// match any type, they decode to the zero value.
+func Check(node *yaml.Node, s *Schema) []Violation {
+	var violations []Violation
+	check(node, s, "", &violations)
+	return violations
+}
+
+func check(node *yaml.Node, s *Schema, path string, violations *[]Violation) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if NodeType(node) == "null" {
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		var expected []string
+		for _, alternative := range s.OneOf {
+			if len(Check(node, alternative)) == 0 {
+				return
+			}
+			expected = append(expected, alternative.Type)
+		}
+		*violations = append(*violations, Violation{Problem: WrongType, Node: node, Path: path, Expected: expected, Got: NodeType(node)})
+		return
+	}
+
+	if s.Type != "" && !matchesType(node, s.Type) {
+		*violations = append(*violations, Violation{Problem: WrongType, Node: node, Path: path, Expected: []string{s.Type}, Got: NodeType(node)})
+		return
+	}
+
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, node.Value) {
+		*violations = append(*violations, Violation{Problem: NotAllowed, Node: node, Path: path, Expected: s.Enum, Got: node.Value})
+		return
+	}
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range node.Content {
+			check(item, s.Items, fmt.Sprintf("%s[%d]", path, i), violations)
+		}
+	case yaml.MappingNode:
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			present[key] = true
+
+			if property, ok := s.Properties[key]; ok {
+				check(value, property, join(path, key), violations)
+			} else if additional, ok := s.AdditionalProperties.(*Schema); ok {
+				check(value, additional, join(path, key), violations)
+			}
+		}
+		for _, key := range s.Required {
+			if !present[key] {
+				*violations = append(*violations, Violation{Problem: Missing, Node: node, Path: join(path, key), Expected: []string{key}})
+			}
+		}
+	}
+}
+
+// NodeType returns the JSON Schema type of a node
+func NodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.AliasNode:
+		return NodeType(node.Alias)
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// matchesType reports whether node can be decoded as typ. Any scalar can be
+// decoded into a string.
+func matchesType(node *yaml.Node, typ string) bool {
+	got := NodeType(node)
+	switch typ {
+	case "string":
+		return node.Kind == yaml.ScalarNode
+	case "number":
+		return got == "number" || got == "integer"
+	default:
+		return got == typ
+	}
+}
+
+// join appends key to a path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,79 @@
+// Package schema
+// Description: Unit tests for the type matching and paths behind Check.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package schema
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scalar returns the first node of the document src
+func scalar(t *testing.T, src string) *yaml.Node {
+	t.Helper()
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.Content[0]
+}
+
+func TestMatchesType(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  string
+		want bool
+	}{
+		{src: "42", typ: "integer", want: true},
+		{src: "42", typ: "number", want: true},
+		{src: "4.2", typ: "integer", want: false},
+		{src: "4.2", typ: "number", want: true},
+		{src: "true", typ: "string", want: true},
+		{src: "42", typ: "string", want: true},
+		{src: "[a]", typ: "string", want: false},
+		{src: "{a: 1}", typ: "object", want: true},
+		{src: "[a]", typ: "array", want: true},
+		{src: "yes", typ: "boolean", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src+" as "+tt.typ, func(t *testing.T) {
+			if got := matchesType(scalar(t, tt.src), tt.typ); got != tt.want {
+				t.Errorf("matchesType(%s, %s) = %v, want %v", tt.src, tt.typ, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPaths(t *testing.T) {
+	s := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"links": {Type: "array", Items: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"mode": {Type: "string", Enum: []string{"symlink", "copy"}}},
+				Required:   []string{"target"},
+			}},
+		},
+	}
+
+	violations := Check(scalar(t, "links:\n  - mode: move\n    target: a\n  - mode: copy\n"), s)
+	want := []struct {
+		problem Problem
+		path    string
+	}{
+		{problem: NotAllowed, path: "links[0].mode"},
+		{problem: Missing, path: "links[1].target"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("Check() = %d violations, want %d: %+v", len(violations), len(want), violations)
+	}
+	for i, w := range want {
+		if violations[i].Problem != w.problem || violations[i].Path != w.path {
+			t.Errorf("violation %d = %v at %s, want %v at %s", i, violations[i].Problem, violations[i].Path, w.problem, w.path)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package schema
+// Description: This file generates JSON Schema documents from the yaml tags of Go types, for editors to complete and validate the configuration files.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON Schema version of the generated documents, the one
+// yaml-language-server supports best
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema document or one of its subschemas
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// Schemer is implemented by types that describe their own schema, usually
+// because they decode from more than one YAML shape
+type Schemer interface {
+	JSONSchema() *Schema
+}
+
+var schemerType = reflect.TypeOf((*Schemer)(nil)).Elem()
+
+// Generate returns the schema document of t
+func Generate(t reflect.Type, title, description string) *Schema {
+	s := For(t)
+	s.Schema = Draft
+	s.Title = title
+	s.Description = description
+	return s
+}
+
+// For returns the schema of t. Struct fields are named after their yaml tags
+// and fields tagged "-" are skipped. The "schema" tag adds constraints to a
+// field: "required" and "enum=a|b|c", separated by commas. The "help" tag
+// becomes the description of the field.
+func For(t reflect.Type) *Schema {
+	if t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).JSONSchema()
+	}
+	if reflect.PointerTo(t).Implements(schemerType) {
+		return reflect.New(t).Interface().(Schemer).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return For(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: For(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: For(t.Elem())}
+	case reflect.Struct:
+		return forStruct(t)
+	default:
+		// Anything is accepted
+		return &Schema{}
+	}
+}
+
+// forStruct returns the schema of a struct type. Unknown keys are rejected,
+// like the loader reports them.
+func forStruct(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property := For(field.Type)
+		property.Description = field.Tag.Get("help")
+		for _, option := range strings.Split(field.Tag.Get("schema"), ",") {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "required":
+				s.Required = append(s.Required, name)
+			case "enum":
+				property.Enum = strings.Split(value, "|")
+			}
+		}
+		s.Properties[name] = property
+	}
+
+	return s
+}
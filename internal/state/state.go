@@ -0,0 +1,133 @@
+// Package state
+// Description: Persistent state for targets materialized by copy or hardlink
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/internal/safefile"
+)
+
+// FileState records what was written to a target
+type FileState struct {
+	Source    string    `json:"source"`
+	Mode      string    `json:"mode"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// State holds the materialized targets, keyed by target path
+type State struct {
+	Files map[string]FileState `json:"files"`
+	path  string
+}
+
+// Load reads the state from path. A missing file yields an empty state.
+func Load(path string) (*State, error) {
+	st := &State{
+		Files: make(map[string]FileState),
+		path:  path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if st.Files == nil {
+		st.Files = make(map[string]FileState)
+	}
+
+	return st, nil
+}
+
+// Save writes the state back to the file it was loaded from. The file is
+// replaced at once, so a crash never leaves it half written.
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := safefile.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the recorded state of target
+func (s *State) Get(target string) (FileState, bool) {
+	fs, ok := s.Files[target]
+	return fs, ok
+}
+
+// Set records the state of target
+func (s *State) Set(target string, fs FileState) {
+	if fs.UpdatedAt.IsZero() {
+		fs.UpdatedAt = time.Now()
+	}
+	s.Files[target] = fs
+}
+
+// Delete forgets target
+func (s *State) Delete(target string) {
+	delete(s.Files, target)
+}
+
+// HashFile returns the hex encoded SHA-256 of the file content at path
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HashBytes returns the hex encoded SHA-256 of data
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetDefaultStatePath returns the default state file path, in
+// $XDG_STATE_HOME/sokru
+func GetDefaultStatePath() (string, error) {
+	stateDir, err := paths.StateDir("")
+	if err != nil {
+		return "", err
+	}
+	return StatePath("", stateDir), nil
+}
+
+// StatePath returns the state file in stateDir, for the --home dir home
+func StatePath(home, stateDir string) string {
+	return paths.Locate(home, stateDir, "state.json")
+}
@@ -0,0 +1,58 @@
+// Package state
+// Description: Unit tests for loading state files written by older versions or by hand.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		files   int
+		wantErr bool
+	}{
+		{name: "empty object", content: "{}", files: 0},
+		{name: "null files", content: `{"files": null}`, files: 0},
+		{name: "one file", content: `{"files": {"/home/user/.vimrc": {"source": "/dotfiles/vimrc", "mode": "copy"}}}`, files: 1},
+		{name: "corrupt", content: `{"files": [`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			st, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(st.Files) != tt.files {
+				t.Errorf("Load() = %d files, want %d", len(st.Files), tt.files)
+			}
+
+			// The map is always usable, and saved back where it was read
+			st.Set("/home/user/.bashrc", FileState{Source: "/dotfiles/bashrc", Mode: "copy"})
+			if err := st.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fs, ok := reloaded.Get("/home/user/.bashrc"); !ok || fs.UpdatedAt.IsZero() {
+				t.Errorf("Get() after Save() = %+v, %v, want a dated entry", fs, ok)
+			}
+		})
+	}
+}
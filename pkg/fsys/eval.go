@@ -0,0 +1,64 @@
+// Package fsys
+// Description: This file resolves the symlinks of a path through an FS, like filepath.EvalSymlinks does on the disk.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package fsys
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// EvalSymlinks returns path once the symlinks it goes through are replaced by
+// their targets, like filepath.EvalSymlinks but reading them through fsys.
+// Every part of path must exist.
+func EvalSymlinks(fsys FS, path string) (string, error) {
+	resolved := "."
+	if filepath.IsAbs(path) {
+		resolved = string(filepath.Separator)
+	}
+	rest := strings.Split(filepath.ToSlash(path), "/")
+	hops := 0
+
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := fsys.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			if len(rest) > 0 && !info.IsDir() {
+				return "", &fs.PathError{Op: "lstat", Path: path, Err: syscall.ENOTDIR}
+			}
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxHops {
+			return "", &fs.PathError{Op: "lstat", Path: path, Err: syscall.ELOOP}
+		}
+		target, err := fsys.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		// Walk the target again from its directory, then what was left
+		if filepath.IsAbs(target) {
+			resolved = string(filepath.Separator)
+		}
+		rest = append(strings.Split(filepath.ToSlash(target), "/"), rest...)
+	}
+	return resolved, nil
+}
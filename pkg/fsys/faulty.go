@@ -0,0 +1,247 @@
+// Package fsys
+// Description: This file contains Faulty, a wrapper that makes chosen operations of another FS fail, to test how failures are handled.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Op names an operation of FS
+type Op string
+
+// Operations of FS
+const (
+	OpLstat     Op = "lstat"
+	OpStat      Op = "stat"
+	OpReadlink  Op = "readlink"
+	OpSymlink   Op = "symlink"
+	OpLink      Op = "link"
+	OpRemove    Op = "remove"
+	OpRemoveAll Op = "removeall"
+	OpMkdirAll  Op = "mkdirall"
+	OpReadDir   Op = "readdir"
+	OpReadFile  Op = "readfile"
+	OpWriteFile Op = "writefile"
+	OpChmod     Op = "chmod"
+)
+
+// Fault makes the calls of an operation fail
+type Fault struct {
+	// Op is the operation that fails, any operation when empty
+	Op Op
+	// Path is the path the operation fails on, any path when empty. For
+	// Symlink and Link it is the new name.
+	Path string
+	// After is the number of matching calls that succeed before the first
+	// failure
+	After int
+	// Times is the number of calls that fail, every call after the first
+	// failure when 0
+	Times int
+	// Err is the cause of the failure, like syscall.EPERM or syscall.EXDEV
+	Err error
+}
+
+// fault is an injected Fault and how many calls it has seen
+type fault struct {
+	Fault
+	calls  int
+	failed int
+}
+
+// Faulty is an FS that fails the calls matching its faults, and passes the
+// others to the FS it wraps. It is safe for concurrent use.
+type Faulty struct {
+	fsys FS
+
+	mu     sync.Mutex
+	faults []*fault
+	calls  map[Op]int
+}
+
+// NewFaulty returns fsys failing with faults
+func NewFaulty(fsys FS, faults ...Fault) *Faulty {
+	f := &Faulty{fsys: fsys, calls: make(map[Op]int)}
+	for _, flt := range faults {
+		f.Inject(flt)
+	}
+	return f
+}
+
+// Inject adds a fault
+func (f *Faulty) Inject(flt Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = append(f.faults, &fault{Fault: flt})
+}
+
+// Reset removes every fault, so calls succeed again
+func (f *Faulty) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// Calls returns how many times op was called, failed calls included
+func (f *Faulty) Calls(op Op) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// check counts a call of op on path and returns the error of the first
+// fault it triggers, if any
+func (f *Faulty) check(op Op, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[op]++
+	for _, flt := range f.faults {
+		if flt.Op != "" && flt.Op != op {
+			continue
+		}
+		if flt.Path != "" && filepath.Clean(flt.Path) != filepath.Clean(path) {
+			continue
+		}
+
+		flt.calls++
+		if flt.calls <= flt.After || (flt.Times > 0 && flt.failed >= flt.Times) {
+			continue
+		}
+		flt.failed++
+		return flt.Err
+	}
+	return nil
+}
+
+// pathError checks op on path, as a *fs.PathError
+func (f *Faulty) pathError(op Op, path string) error {
+	if err := f.check(op, path); err != nil {
+		return &fs.PathError{Op: string(op), Path: path, Err: err}
+	}
+	return nil
+}
+
+// linkError checks op from oldname to newname, as an *os.LinkError
+func (f *Faulty) linkError(op Op, oldname, newname string) error {
+	if err := f.check(op, newname); err != nil {
+		return &os.LinkError{Op: string(op), Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// Lstat calls Lstat of the wrapped FS unless a fault triggers
+func (f *Faulty) Lstat(name string) (fs.FileInfo, error) {
+	if err := f.pathError(OpLstat, name); err != nil {
+		return nil, err
+	}
+	return f.fsys.Lstat(name)
+}
+
+// Stat calls Stat of the wrapped FS unless a fault triggers
+func (f *Faulty) Stat(name string) (fs.FileInfo, error) {
+	if err := f.pathError(OpStat, name); err != nil {
+		return nil, err
+	}
+	return f.fsys.Stat(name)
+}
+
+// Readlink calls Readlink of the wrapped FS unless a fault triggers
+func (f *Faulty) Readlink(name string) (string, error) {
+	if err := f.pathError(OpReadlink, name); err != nil {
+		return "", err
+	}
+	return f.fsys.Readlink(name)
+}
+
+// Symlink calls Symlink of the wrapped FS unless a fault triggers
+func (f *Faulty) Symlink(oldname, newname string) error {
+	if err := f.linkError(OpSymlink, oldname, newname); err != nil {
+		return err
+	}
+	return f.fsys.Symlink(oldname, newname)
+}
+
+// Link calls Link of the wrapped FS unless a fault triggers
+func (f *Faulty) Link(oldname, newname string) error {
+	if err := f.linkError(OpLink, oldname, newname); err != nil {
+		return err
+	}
+	return f.fsys.Link(oldname, newname)
+}
+
+// Remove calls Remove of the wrapped FS unless a fault triggers
+func (f *Faulty) Remove(name string) error {
+	if err := f.pathError(OpRemove, name); err != nil {
+		return err
+	}
+	return f.fsys.Remove(name)
+}
+
+// RemoveAll calls RemoveAll of the wrapped FS unless a fault triggers
+func (f *Faulty) RemoveAll(path string) error {
+	if err := f.pathError(OpRemoveAll, path); err != nil {
+		return err
+	}
+	return f.fsys.RemoveAll(path)
+}
+
+// MkdirAll calls MkdirAll of the wrapped FS unless a fault triggers
+func (f *Faulty) MkdirAll(path string, perm fs.FileMode) error {
+	if err := f.pathError(OpMkdirAll, path); err != nil {
+		return err
+	}
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// ReadDir calls ReadDir of the wrapped FS unless a fault triggers
+func (f *Faulty) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.pathError(OpReadDir, name); err != nil {
+		return nil, err
+	}
+	return f.fsys.ReadDir(name)
+}
+
+// ReadFile calls ReadFile of the wrapped FS unless a fault triggers
+func (f *Faulty) ReadFile(name string) ([]byte, error) {
+	if err := f.pathError(OpReadFile, name); err != nil {
+		return nil, err
+	}
+	return f.fsys.ReadFile(name)
+}
+
+// WriteFile calls WriteFile of the wrapped FS unless a fault triggers
+func (f *Faulty) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := f.pathError(OpWriteFile, name); err != nil {
+		return err
+	}
+	return f.fsys.WriteFile(name, data, perm)
+}
+
+// WriteFileAtomic writes name at once through the wrapped FS unless a
+// fault on OpWriteFile triggers. It falls back to WriteFile when the wrapped
+// FS is not an AtomicWriter.
+func (f *Faulty) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	if err := f.pathError(OpWriteFile, name); err != nil {
+		return err
+	}
+	return WriteFileAtomic(f.fsys, name, data, perm)
+}
+
+// Chmod calls Chmod of the wrapped FS unless a fault triggers
+func (f *Faulty) Chmod(name string, mode fs.FileMode) error {
+	if err := f.pathError(OpChmod, name); err != nil {
+		return err
+	}
+	return f.fsys.Chmod(name, mode)
+}
+
+// SameFile calls SameFile of the wrapped FS
+func (f *Faulty) SameFile(fi1, fi2 fs.FileInfo) bool {
+	return f.fsys.SameFile(fi1, fi2)
+}
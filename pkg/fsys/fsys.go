@@ -0,0 +1,108 @@
+// Package fsys
+// Description: This file contains the FS interface the engine, backups and rollback change files through, and its implementation on the real filesystem.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+// Package fsys abstracts the filesystem operations sok makes on targets, so
+// they can run against the disk, in memory, or with failures injected.
+package fsys
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/alexlm78/sokru/internal/safefile"
+)
+
+// FS is the filesystem sok links, copies, backs up and rolls back through.
+// Its methods behave like the functions of package os of the same name, and
+// fail with *fs.PathError or *os.LinkError errors that os.IsNotExist and
+// os.IsExist understand.
+type FS interface {
+	Lstat(name string) (fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Link(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+	// SameFile reports whether fi1 and fi2, returned by this FS, describe
+	// the same file
+	SameFile(fi1, fi2 fs.FileInfo) bool
+}
+
+// AtomicWriter is implemented by filesystems that can replace a file at
+// once, so a crash never leaves it half written
+type AtomicWriter interface {
+	WriteFileAtomic(name string, data []byte, perm fs.FileMode) error
+}
+
+// WriteFileAtomic writes data to name at once when fsys is an AtomicWriter,
+// and with WriteFile otherwise
+func WriteFileAtomic(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	if writer, ok := fsys.(AtomicWriter); ok {
+		return writer.WriteFileAtomic(name, data, perm)
+	}
+	return fsys.WriteFile(name, data, perm)
+}
+
+// Or returns fsys, or the real filesystem when fsys is nil
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS{}
+	}
+	return fsys
+}
+
+// OS is the real filesystem
+type OS struct{}
+
+// Lstat calls os.Lstat
+func (OS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+// Stat calls os.Stat
+func (OS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+// Readlink calls os.Readlink
+func (OS) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+// Symlink calls os.Symlink
+func (OS) Symlink(oldname, newname string) error { return os.Symlink(oldname, newname) }
+
+// Link calls os.Link
+func (OS) Link(oldname, newname string) error { return os.Link(oldname, newname) }
+
+// Remove calls os.Remove
+func (OS) Remove(name string) error { return os.Remove(name) }
+
+// RemoveAll calls os.RemoveAll
+func (OS) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+// MkdirAll calls os.MkdirAll
+func (OS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+// ReadDir calls os.ReadDir
+func (OS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// ReadFile calls os.ReadFile
+func (OS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+// WriteFile calls os.WriteFile
+func (OS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// WriteFileAtomic writes name through a temporary file renamed over it
+func (OS) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	return safefile.WriteFile(name, data, perm)
+}
+
+// Chmod calls os.Chmod
+func (OS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+
+// SameFile calls os.SameFile
+func (OS) SameFile(fi1, fi2 fs.FileInfo) bool { return os.SameFile(fi1, fi2) }
@@ -0,0 +1,127 @@
+// Package fsys
+// Description: This file adapts an FS to io/fs, so fs.Glob and fs.WalkDir work on any of them.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package fsys
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+)
+
+// DirFS returns the files of fsys below dir as an fs.FS, like os.DirFS does
+// for the real filesystem. Its names are slash-separated and relative to dir.
+func DirFS(fsys FS, dir string) fs.FS {
+	return dirFS{fsys: fsys, dir: dir}
+}
+
+// dirFS is the fs.FS of DirFS. It also implements fs.StatFS, fs.ReadDirFS and
+// fs.ReadFileFS, so fs.Glob does not open every directory it reads.
+type dirFS struct {
+	fsys FS
+	dir  string
+}
+
+// path returns the path of name in the wrapped FS
+func (d dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+// Open opens name for reading
+func (d dirFS) Open(name string) (fs.File, error) {
+	path, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := d.fsys.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := d.fsys.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return &openFile{info: info, Reader: bytes.NewReader(nil), entries: entries}, nil
+	}
+	data, err := d.fsys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &openFile{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// Stat returns the file info of name, following symlinks
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := d.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.Stat(path)
+}
+
+// ReadDir returns the entries of the directory name, sorted by name
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := d.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.ReadDir(path)
+}
+
+// ReadFile returns the content of the file name
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	path, err := d.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.ReadFile(path)
+}
+
+// openFile is a file opened by dirFS, with its content, or its entries for a
+// directory, already read
+type openFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+	entries []fs.DirEntry
+}
+
+// Stat returns the file info of the file
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// Read reads the content of the file. Directories have none.
+func (f *openFile) Read(p []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.info.Name(), Err: syscall.EISDIR}
+	}
+	return f.Reader.Read(p)
+}
+
+// ReadDir returns the next n entries of the directory, or all that are left
+// when n <= 0, like fs.ReadDirFile
+func (f *openFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.info.Name(), Err: syscall.ENOTDIR}
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// Close does nothing, the content is in memory
+func (f *openFile) Close() error { return nil }
@@ -0,0 +1,101 @@
+// Package fsys
+// Description: Unit tests for the io/fs adapter, checked with testing/fstest and on directory reads in chunks.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package fsys
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+// newDirFS returns DirFS over a MemFS with three files below /dots
+func newDirFS(t *testing.T) fs.FS {
+	t.Helper()
+
+	mem := NewMemFS()
+	if err := mem.MkdirAll("/dots/nvim", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"/dots/vimrc":         "set number\n",
+		"/dots/bashrc":        "alias ll='ls -l'\n",
+		"/dots/nvim/init.lua": "vim.o.number = true\n",
+	} {
+		if err := mem.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return DirFS(mem, "/dots")
+}
+
+func TestDirFSConformance(t *testing.T) {
+	if err := fstest.TestFS(newDirFS(t), "vimrc", "bashrc", "nvim/init.lua"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenFileReadDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int
+		want  []int
+		last  error
+	}{
+		{name: "in chunks", sizes: []int{2, 2}, want: []int{2, 1}},
+		{name: "past the end", sizes: []int{3, 1}, want: []int{3, 0}, last: io.EOF},
+		{name: "all at once", sizes: []int{0, 0}, want: []int{3, 0}},
+		{name: "rest after a chunk", sizes: []int{1, -1}, want: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := newDirFS(t).Open(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dir.Close()
+
+			var lastErr error
+			for i, n := range tt.sizes {
+				entries, err := dir.(fs.ReadDirFile).ReadDir(n)
+				if len(entries) != tt.want[i] {
+					t.Errorf("ReadDir(%d) #%d = %d entries, want %d", n, i, len(entries), tt.want[i])
+				}
+				lastErr = err
+			}
+			if !errors.Is(lastErr, tt.last) {
+				t.Errorf("last ReadDir() error = %v, want %v", lastErr, tt.last)
+			}
+		})
+	}
+}
+
+func TestOpenFileKinds(t *testing.T) {
+	// Directories cannot be read and files cannot be listed
+	files := newDirFS(t)
+
+	dir, err := files.Open("nvim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Read(make([]byte, 1)); !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("Read() on a directory error = %v, want EISDIR", err)
+	}
+
+	file, err := files.Open("vimrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.(fs.ReadDirFile).ReadDir(-1); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("ReadDir() on a file error = %v, want ENOTDIR", err)
+	}
+
+	if _, err := files.Open("../etc/passwd"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Open() outside the directory error = %v, want fs.ErrInvalid", err)
+	}
+}
@@ -0,0 +1,388 @@
+// Package fsys
+// Description: This file contains MemFS, an in-memory filesystem with directories, symlinks and hard links for tests and sandboxes.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxHops is how many symlinks a path may go through before it fails with
+// ELOOP, like Linux
+const maxHops = 40
+
+// inode is a file, directory or symlink of a MemFS. Hard links share it.
+type inode struct {
+	mode    fs.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+}
+
+// MemFS is a filesystem held in memory. Paths are absolute, relative ones are
+// taken from the root. Symlinks are followed like on Linux, and hard links
+// share their content. It is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*inode
+}
+
+// NewMemFS returns an empty MemFS, with only its root directory
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*inode{
+		"/": {mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+// memInfo is the fs.FileInfo of a MemFS file
+type memInfo struct {
+	name string
+	node *inode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() any           { return i.node }
+
+// clean returns name as an absolute, clean path
+func clean(name string) string {
+	return filepath.Join("/", name)
+}
+
+// resolve returns the path of name once the symlinks of its directories,
+// and of name itself when follow is set, are replaced by their targets. The
+// file it returns may not exist, but its directory does.
+func (m *MemFS) resolve(name string, follow bool) (string, error) {
+	rest := strings.Split(strings.TrimPrefix(clean(name), "/"), "/")
+	current := "/"
+	hops := 0
+
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+		if part == "" {
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		node, ok := m.files[next]
+		last := len(rest) == 0
+		switch {
+		case !ok && !last:
+			return "", syscall.ENOENT
+		case !ok:
+			return next, nil
+		case node.mode&fs.ModeSymlink != 0 && (!last || follow):
+			hops++
+			if hops > maxHops {
+				return "", syscall.ELOOP
+			}
+			target := node.target
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(current, target)
+			}
+			// Walk the target again from the root, then what was left
+			rest = append(strings.Split(strings.TrimPrefix(clean(target), "/"), "/"), rest...)
+			current = "/"
+		case !last && !node.mode.IsDir():
+			return "", syscall.ENOTDIR
+		default:
+			current = next
+		}
+	}
+	return current, nil
+}
+
+// lookup returns the path and the file name refers to, or nil when it does
+// not exist
+func (m *MemFS) lookup(name string, follow bool) (string, *inode, error) {
+	path, err := m.resolve(name, follow)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, m.files[path], nil
+}
+
+// create adds node at name, which must not exist yet
+func (m *MemFS) create(name string, node *inode) error {
+	path, existing, err := m.lookup(name, false)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return syscall.EEXIST
+	}
+	if parent := m.files[filepath.Dir(path)]; parent == nil || !parent.mode.IsDir() {
+		return syscall.ENOENT
+	}
+	node.modTime = time.Now()
+	m.files[path] = node
+	return nil
+}
+
+// stat returns the file info of name
+func (m *MemFS) stat(op, name string, follow bool) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, node, err := m.lookup(name, follow)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return memInfo{name: filepath.Base(path), node: node}, nil
+}
+
+// Lstat returns the file info of name, without following a symlink
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+// Stat returns the file info of name, following symlinks
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+// Readlink returns the target of the symlink name
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup(name, false)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = syscall.ENOENT
+	case node.mode&fs.ModeSymlink == 0:
+		err = syscall.EINVAL
+	default:
+		return node.target, nil
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+}
+
+// Symlink creates newname as a symlink to oldname, which may not exist
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.create(newname, &inode{mode: fs.ModeSymlink | 0777, target: oldname}); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// Link creates newname as a hard link to oldname. Like on Linux, a symlink
+// at oldname is linked itself, not followed.
+func (m *MemFS) Link(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup(oldname, false)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = syscall.ENOENT
+	case node.mode.IsDir():
+		err = syscall.EPERM
+	default:
+		path, existing, lookupErr := m.lookup(newname, false)
+		switch {
+		case lookupErr != nil:
+			err = lookupErr
+		case existing != nil:
+			err = syscall.EEXIST
+		case m.files[filepath.Dir(path)] == nil:
+			err = syscall.ENOENT
+		default:
+			m.files[path] = node
+			return nil
+		}
+	}
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+}
+
+// Remove removes the file, symlink or empty directory name
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, node, err := m.lookup(name, false)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = syscall.ENOENT
+	case path == "/":
+		err = syscall.EBUSY
+	case node.mode.IsDir() && len(m.children(path)) > 0:
+		err = syscall.ENOTEMPTY
+	default:
+		delete(m.files, path)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: err}
+}
+
+// RemoveAll removes path and everything below it. A missing path is not an
+// error.
+func (m *MemFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, node, err := m.lookup(path, false)
+	if err != nil || node == nil {
+		return nil
+	}
+	if resolved == "/" {
+		return &fs.PathError{Op: "unlinkat", Path: path, Err: syscall.EBUSY}
+	}
+
+	for name := range m.files {
+		if name == resolved || strings.HasPrefix(name, resolved+"/") {
+			delete(m.files, name)
+		}
+	}
+	return nil
+}
+
+// MkdirAll creates the directory path and any missing parents
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir := "/"
+	for _, part := range strings.Split(strings.TrimPrefix(clean(path), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+
+		_, node, err := m.lookup(dir, true)
+		switch {
+		case err != nil:
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: err}
+		case node == nil:
+			if err := m.create(dir, &inode{mode: fs.ModeDir | perm.Perm()}); err != nil {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: err}
+			}
+		case !node.mode.IsDir():
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil
+}
+
+// children returns the names of the files in the directory path, sorted
+func (m *MemFS) children(path string) []string {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+
+	var names []string
+	for name := range m.files {
+		if name != "/" && strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], "/") {
+			names = append(names, name[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadDir returns the entries of the directory name, sorted by name
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, node, err := m.lookup(name, true)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = syscall.ENOENT
+	case !node.mode.IsDir():
+		err = syscall.ENOTDIR
+	default:
+		var entries []fs.DirEntry
+		for _, child := range m.children(path) {
+			info := memInfo{name: child, node: m.files[filepath.Join(path, child)]}
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+		return entries, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+// ReadFile returns the content of the file name
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup(name, true)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = syscall.ENOENT
+	case node.mode.IsDir():
+		err = syscall.EISDIR
+	default:
+		return append([]byte(nil), node.data...), nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+// WriteFile writes data to the file name, creating it with perm when it
+// does not exist. Like os.WriteFile, an existing file keeps its permissions
+// and every hard link to it sees the new content.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, node, err := m.lookup(name, true)
+	switch {
+	case err != nil:
+	case node == nil:
+		err = m.create(path, &inode{mode: perm.Perm(), data: append([]byte(nil), data...)})
+	case node.mode.IsDir():
+		err = syscall.EISDIR
+	default:
+		node.data = append([]byte(nil), data...)
+		node.modTime = time.Now()
+	}
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chmod changes the permissions of the file name, following symlinks
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup(name, true)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+// SameFile reports whether fi1 and fi2 are the same file or hard links to it
+func (m *MemFS) SameFile(fi1, fi2 fs.FileInfo) bool {
+	node1, ok1 := fi1.Sys().(*inode)
+	node2, ok2 := fi2.Sys().(*inode)
+	return ok1 && ok2 && node1 == node2
+}
@@ -0,0 +1,265 @@
+// Package sokru
+// Description: This file contains Plan and Apply, which compare the targets with the symlinks file and bring them in line with it.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"os"
+
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// Plan is what Apply changes to bring the targets in line with the symlinks
+// file
+type Plan struct {
+	// SymlinksFile is the symlinks file the links come from
+	SymlinksFile string
+	// Entries is the number of entries in the symlinks file and its includes
+	Entries int
+	// Changes hold one change per link: created, updated, unchanged or
+	// skipped, in the order they are applied
+	Changes []Change
+	// Issues are the warnings found validating the symlinks file
+	Issues []Issue
+}
+
+// Count returns how many changes are of kind
+func (p *Plan) Count(kind ChangeKind) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Pending reports whether applying the plan changes anything
+func (p *Plan) Pending() bool {
+	return p.Count(ChangeCreated) > 0 || p.Count(ChangeUpdated) > 0
+}
+
+// NewPlan compares the targets of the symlinks file of cfg with their
+// sources. Unlike Install, Apply never replaces regular files: targets in
+// the way, and copies edited locally, are skipped. The state is read under
+// the lock, pass it in opts to keep it until Apply.
+func NewPlan(cfg *Config, opts RunOptions) (p *Plan, err error) {
+	notify := newNotifier(cfg, opts.Observer)
+	defer func() { notify.fail(err) }()
+	files := fsys.Or(opts.FS)
+
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	symlinkFile, err := symlinksFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p = &Plan{SymlinksFile: symlinkFile}
+
+	if !opts.SkipValidation {
+		if p.Issues, err = validate(cfg, symlinkFile, files); err != nil {
+			return p, err
+		}
+	}
+
+	symlinkConfigs, links, err := loadPlan(cfg, symlinkFile, files)
+	if err != nil {
+		return p, err
+	}
+	p.Entries = len(symlinkConfigs)
+	notify.emit(Event{Type: EventLoaded, Path: symlinkFile, Count: p.Entries, Issues: p.Issues})
+	if err := checkOverlaps(cfg, files, links); err != nil {
+		return p, err
+	}
+	st, err := loadState(cfg)
+	if err != nil {
+		return p, err
+	}
+
+	templateData := newTemplateData(cfg)
+	for _, link := range links {
+		change := Change{Link: link}
+
+		// Rendered output of templates whose content changed
+		if link.Kind == KindTemplate {
+			content, err := renderLink(files, link, templateData)
+			if err != nil {
+				return p, linkError(OpRender, link, err)
+			}
+			if renderedChanged(files, link, content) {
+				change.content = content
+				change.Diff = templateDiff(files, link, content)
+			}
+		}
+
+		// Copied and hard linked targets are compared by content
+		if link.Mode != ModeSymlink {
+			status, err := checkMaterialized(files, link, st, change.content)
+			if err != nil {
+				return p, linkError(OpCheck, link, err)
+			}
+			change.Status = status
+
+			switch {
+			case status == StatusMissing:
+				change.Kind = ChangeCreated
+			case status == StatusOutdated || (status == StatusInstalled && change.content != nil):
+				change.Kind = ChangeUpdated
+			case status == StatusDrifted || status == StatusBlocked:
+				change.Kind = ChangeSkipped
+			default:
+				change.Kind = ChangeUnchanged
+			}
+			p.Changes = append(p.Changes, change)
+			continue
+		}
+
+		existingLink, err := files.Readlink(link.Target)
+		switch {
+		case os.IsNotExist(err):
+			change.Kind, change.Status = ChangeCreated, StatusMissing
+		case err != nil:
+			// A regular file, or a target that cannot be read, is left alone
+			change.Kind, change.Err = ChangeSkipped, linkError(OpReadLink, link, err)
+		case existingLink != link.Source:
+			change.Kind, change.Status, change.Previous = ChangeUpdated, StatusWrongTarget, existingLink
+		case change.content != nil:
+			// The symlink is correct but the template output changed
+			change.Kind, change.Status, change.Previous = ChangeUpdated, StatusInstalled, existingLink
+		default:
+			change.Kind, change.Status = ChangeUnchanged, StatusInstalled
+		}
+		p.Changes = append(p.Changes, change)
+	}
+
+	notify.emit(Event{Type: EventPlanned, Plan: p})
+	return p, nil
+}
+
+// Apply makes the changes of a plan made by NewPlan. Targets are checked
+// again under the lock, and copies edited since the plan are skipped. On a
+// failure every change made is rolled back. With cfg.DryRun nothing is changed and the
+// result holds the changes of the plan.
+func Apply(cfg *Config, p *Plan, opts RunOptions) (result *Result, err error) {
+	notify := newNotifier(cfg, opts.Observer)
+	defer func() { notify.fail(err) }()
+	files := fsys.Or(opts.FS)
+
+	result = &Result{SymlinksFile: p.SymlinksFile, Entries: p.Entries, DryRun: cfg.DryRun, Issues: p.Issues, notify: notify}
+	if cfg.DryRun {
+		for _, change := range p.Changes {
+			if change.Kind == ChangeCreated || change.Kind == ChangeUpdated {
+				result.add(change)
+			}
+		}
+		return result, nil
+	}
+
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	st, err := loadState(cfg)
+	if err != nil {
+		return result, err
+	}
+
+	tracker := rollback.NewTrackerFS(files)
+	stateChanged := false
+
+	failure := func() error {
+		for _, change := range p.Changes {
+			link := change.Link
+
+			// Write changed template output before linking to it
+			if change.content != nil {
+				if err := writeRendered(files, link, change.content, tracker); err != nil {
+					return linkError(OpRender, link, err)
+				}
+			}
+
+			// Materialize missing and outdated copies
+			if link.Mode != ModeSymlink {
+				if change.Status != StatusMissing && change.Status != StatusOutdated {
+					if change.content != nil && change.Status == StatusInstalled {
+						result.add(Change{Kind: ChangeUpdated, Link: link, Status: change.Status})
+					}
+					continue
+				}
+
+				// The target is checked again, it may have been edited since the plan
+				status, err := checkMaterialized(files, link, st, nil)
+				if err != nil {
+					return linkError(OpCheck, link, err)
+				}
+				switch status {
+				case StatusDrifted, StatusBlocked:
+					result.add(Change{Kind: ChangeSkipped, Link: link, Status: status})
+					continue
+				case StatusInstalled:
+					continue
+				}
+
+				hash, err := materialize(files, link, tracker)
+				if err != nil {
+					return linkError(OpCopy, link, err)
+				}
+				st.Set(link.Target, state.FileState{Source: link.Source, Mode: link.Mode, Hash: hash})
+				stateChanged = true
+
+				kind := ChangeUpdated
+				if status == StatusMissing {
+					kind = ChangeCreated
+				}
+				result.add(Change{Kind: kind, Link: link, Status: status})
+				continue
+			}
+
+			// The target is checked again, it may have changed since the plan
+			existingLink, err := files.Readlink(link.Target)
+			switch {
+			case os.IsNotExist(err):
+				if err := files.Symlink(link.Source, link.Target); err != nil {
+					return linkError(OpLink, link, err)
+				}
+				tracker.TrackCreated(link.Target, link.Source)
+				result.add(Change{Kind: ChangeCreated, Link: link, Status: StatusMissing})
+			case err == nil && existingLink != link.Source:
+				if err := files.Remove(link.Target); err != nil {
+					return linkError(OpRemove, link, err)
+				}
+				if err := files.Symlink(link.Source, link.Target); err != nil {
+					return linkError(OpLink, link, err)
+				}
+				tracker.TrackUpdated(link.Target, link.Source, existingLink)
+				result.add(Change{Kind: ChangeUpdated, Link: link, Status: StatusWrongTarget, Previous: existingLink})
+			case err == nil && change.content != nil:
+				// Only the template output changed
+				result.add(Change{Kind: ChangeUpdated, Link: link, Status: StatusInstalled, Previous: existingLink})
+			}
+		}
+		return nil
+	}()
+
+	if failure != nil && tracker.HasActions() {
+		rollbackChanges(result, tracker, failure)
+		return result, failure
+	}
+
+	// Save the state of copied and hard linked targets
+	if stateChanged {
+		if err := st.Save(); err != nil && failure == nil {
+			failure = &Error{Op: OpSaveState, Err: err}
+		}
+	}
+	return result, failure
+}
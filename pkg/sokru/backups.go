@@ -0,0 +1,99 @@
+// Package sokru
+// Description: This file contains the backups of replaced targets: listing, restoring and deleting them.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"sort"
+
+	"github.com/alexlm78/sokru/internal/backup"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// BackupOptions controls Backups, LoadBackup, Restore and DeleteBackup
+type BackupOptions struct {
+	// FS is the filesystem backups are read, restored and deleted on, the
+	// real one when nil
+	FS fsys.FS
+	// Lock is the lock of cfg when the caller holds it, see AcquireLock.
+	// Restore and DeleteBackup take it themselves when nil.
+	Lock *Lock
+}
+
+// Backups returns the backups made by Install with cfg, newest first
+func Backups(cfg *Config, opts BackupOptions) ([]BackupMetadata, error) {
+	manager, err := backupManager(cfg, fsys.Or(opts.FS))
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := manager.ListBackups()
+	if err != nil {
+		return nil, &Error{Op: OpBackup, Path: manager.GetBackupDir(), Err: err}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+	return backups, nil
+}
+
+// LoadBackup returns the backup of cfg with id
+func LoadBackup(cfg *Config, id string, opts BackupOptions) (*BackupMetadata, error) {
+	manager, err := backupManager(cfg, fsys.Or(opts.FS))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := manager.LoadMetadata(id)
+	if err != nil {
+		return nil, &Error{Op: OpBackup, Path: id, Err: err}
+	}
+	return metadata, nil
+}
+
+// Restore puts the files of the backup of cfg with id back where they were
+func Restore(cfg *Config, id string, opts BackupOptions) error {
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	manager, err := backupManager(cfg, fsys.Or(opts.FS))
+	if err != nil {
+		return err
+	}
+	if err := manager.RestoreBackup(id); err != nil {
+		return &Error{Op: OpBackup, Path: id, Err: err}
+	}
+	return nil
+}
+
+// DeleteBackup deletes the backup of cfg with id
+func DeleteBackup(cfg *Config, id string, opts BackupOptions) error {
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	manager, err := backupManager(cfg, fsys.Or(opts.FS))
+	if err != nil {
+		return err
+	}
+	if err := manager.DeleteBackup(id); err != nil {
+		return &Error{Op: OpBackup, Path: id, Err: err}
+	}
+	return nil
+}
+
+// backupManager returns the manager of the backup directory of cfg, backing
+// up and restoring files on files
+func backupManager(cfg *Config, files fsys.FS) (*backup.Manager, error) {
+	_, dataDir, err := cfg.Dirs()
+	if err != nil {
+		return nil, &Error{Op: OpBackupDir, Err: err}
+	}
+	return backup.NewManagerFS(files, backup.BackupDir(cfg.Home, dataDir)), nil
+}
@@ -0,0 +1,242 @@
+// Package sokru
+// Description: This file contains the events operations send to observers while they run, and an observer writing them as JSON lines.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType says what an event reports
+type EventType string
+
+// Types of events
+const (
+	// EventLoaded reports the symlinks file: Path, the number of entries in
+	// Count and the validation warnings in Issues
+	EventLoaded EventType = "loaded"
+	// EventPlanned reports the Plan computed by NewPlan
+	EventPlanned EventType = "plan_computed"
+	// EventChange reports a Change made to a target, or planned on a dry run
+	EventChange EventType = "change"
+	// EventBackup reports the Backup written of the targets Install replaced
+	EventBackup EventType = "backup_written"
+	// EventRollback reports that Count changes are being undone after a
+	// failure
+	EventRollback EventType = "rollback_started"
+	// EventRolledBack reports the end of a rollback, Err is set when it failed
+	EventRolledBack EventType = "rollback_finished"
+	// EventError reports the Err an operation fails with, or a failure it
+	// carries on after, like saving the state
+	EventError EventType = "error"
+)
+
+// Event is something that happened during an operation. Only the fields of
+// its type are set.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// DryRun events describe changes without them being made
+	DryRun bool
+
+	Path   string
+	Count  int
+	Issues []Issue
+	Plan   *Plan
+	Change *Change
+	Backup *BackupMetadata
+	Err    error
+}
+
+// Observer receives the events of the operations it is given to, in the
+// order they happen. OnEvent is called from the goroutine running the
+// operation and should return quickly.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc lets a function be used as an Observer
+type ObserverFunc func(event Event)
+
+// OnEvent calls f
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// Observers sends every event to each of its observers in turn
+type Observers []Observer
+
+// OnEvent sends event to every observer
+func (observers Observers) OnEvent(event Event) {
+	for _, observer := range observers {
+		if observer != nil {
+			observer.OnEvent(event)
+		}
+	}
+}
+
+// notifier sends the events of an operation to its observer, if any
+type notifier struct {
+	observer Observer
+	dryRun   bool
+	// failed is set once the failure of the operation was sent
+	failed bool
+}
+
+// newNotifier returns the notifier of an operation run with cfg
+func newNotifier(cfg *Config, observer Observer) *notifier {
+	return &notifier{observer: observer, dryRun: cfg.DryRun}
+}
+
+// emit sends event, stamped with the time and whether it is a dry run
+func (n *notifier) emit(event Event) {
+	if n == nil || n.observer == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.DryRun = n.dryRun
+	n.observer.OnEvent(event)
+}
+
+// fail sends the failure of the operation, once
+func (n *notifier) fail(err error) {
+	if n == nil || err == nil || n.failed {
+		return
+	}
+	n.failed = true
+	n.emit(Event{Type: EventError, Err: err})
+}
+
+// JSONObserver writes every event as a line of JSON, for programs that
+// follow sok from the outside
+type JSONObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONObserver returns an observer writing one JSON object per event to w
+func NewJSONObserver(w io.Writer) *JSONObserver {
+	return &JSONObserver{enc: json.NewEncoder(w)}
+}
+
+// OnEvent writes event. Events that cannot be written are dropped.
+func (o *JSONObserver) OnEvent(event Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.enc.Encode(event)
+}
+
+// jsonLink is a link as written in JSON events
+type jsonLink struct {
+	Target   string `json:"target"`
+	Source   string `json:"source"`
+	Mode     string `json:"mode,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// jsonChange is a change as written in JSON events
+type jsonChange struct {
+	jsonLink
+	Kind     ChangeKind `json:"kind"`
+	Status   Status     `json:"status,omitempty"`
+	Previous string     `json:"previous,omitempty"`
+	Diff     string     `json:"diff,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// jsonIssue is an issue as written in JSON events
+type jsonIssue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// jsonEvent is an event as written by JSONObserver
+type jsonEvent struct {
+	Type   EventType    `json:"type"`
+	Time   time.Time    `json:"time"`
+	DryRun bool         `json:"dry_run,omitempty"`
+	Path   string       `json:"path,omitempty"`
+	Count  int          `json:"count,omitempty"`
+	Issues []jsonIssue  `json:"issues,omitempty"`
+	Plan   []jsonChange `json:"plan,omitempty"`
+	Change *jsonChange  `json:"change,omitempty"`
+	Backup string       `json:"backup,omitempty"`
+	Op     Op           `json:"op,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// MarshalJSON writes the fields of the type of the event. Errors are written
+// as their message, with the failed step in "op" and issues in "issues".
+func (e Event) MarshalJSON() ([]byte, error) {
+	out := jsonEvent{Type: e.Type, Time: e.Time, DryRun: e.DryRun, Path: e.Path, Count: e.Count}
+	out.Issues = toJSONIssues(e.Issues)
+
+	if e.Plan != nil {
+		out.Plan = make([]jsonChange, 0, len(e.Plan.Changes))
+		for _, change := range e.Plan.Changes {
+			out.Plan = append(out.Plan, toJSONChange(change))
+		}
+	}
+	if e.Change != nil {
+		change := toJSONChange(*e.Change)
+		out.Change = &change
+	}
+	if e.Backup != nil {
+		out.Backup = e.Backup.ID
+		out.Count = len(e.Backup.Entries)
+	}
+
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+		var opErr *Error
+		if errors.As(e.Err, &opErr) {
+			out.Op, out.Path = opErr.Op, opErr.Path
+			out.Error = opErr.Err.Error()
+		}
+		var issues Issues
+		if errors.As(e.Err, &issues) {
+			out.Issues = toJSONIssues(issues)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// toJSONChange converts a change for a JSON event
+func toJSONChange(change Change) jsonChange {
+	out := jsonChange{
+		jsonLink: jsonLink{
+			Target:   change.Link.Target,
+			Source:   change.Link.Source,
+			Mode:     change.Link.Mode,
+			Template: change.Link.Template,
+		},
+		Kind:     change.Kind,
+		Status:   change.Status,
+		Previous: change.Previous,
+		Diff:     change.Diff,
+	}
+	if change.Err != nil {
+		out.Error = change.Err.Error()
+	}
+	return out
+}
+
+// toJSONIssues converts issues for a JSON event
+func toJSONIssues(issues []Issue) []jsonIssue {
+	if len(issues) == 0 {
+		return nil
+	}
+	out := make([]jsonIssue, len(issues))
+	for i, issue := range issues {
+		out[i] = jsonIssue{File: issue.File, Line: issue.Line, Column: issue.Column, Message: issue.Message, Warning: issue.Warning}
+	}
+	return out
+}
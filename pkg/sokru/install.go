@@ -0,0 +1,351 @@
+// Package sokru
+// Description: This file contains Install and Uninstall, which create and remove the links of the symlinks file, backing up and rolling back what they replace.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"os"
+	"time"
+
+	"github.com/alexlm78/sokru/internal/backup"
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// RunOptions controls Install, Uninstall, NewPlan and Apply
+type RunOptions struct {
+	// SkipValidation links without validating the symlinks file first. Only
+	// Install and NewPlan validate it.
+	SkipValidation bool
+	// Observer receives the events of the operation, nil for none
+	Observer Observer
+	// FS is the filesystem sources are read from and targets are changed
+	// and backed up on, the real one when nil. The configuration, symlinks
+	// file, state and lock are always read from disk.
+	FS fsys.FS
+	// Lock is the lock of cfg when the caller holds it, see AcquireLock.
+	// The operation takes it itself when nil.
+	Lock *Lock
+}
+
+// Install creates the links of the symlinks file of cfg. Existing targets
+// are backed up before they are replaced, and on a failure every change made
+// is rolled back. With cfg.DryRun nothing is changed and the result
+// describes what would be.
+func Install(cfg *Config, opts RunOptions) (result *Result, err error) {
+	notify := newNotifier(cfg, opts.Observer)
+	defer func() { notify.fail(err) }()
+	files := fsys.Or(opts.FS)
+
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	symlinkFile, err := symlinksFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result = &Result{SymlinksFile: symlinkFile, DryRun: cfg.DryRun, notify: notify}
+
+	if !opts.SkipValidation {
+		if result.Issues, err = validate(cfg, symlinkFile, files); err != nil {
+			return result, err
+		}
+	}
+
+	symlinkConfigs, plan, err := loadPlan(cfg, symlinkFile, files)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = len(symlinkConfigs)
+	notify.emit(Event{Type: EventLoaded, Path: symlinkFile, Count: result.Entries, Issues: result.Issues})
+	if err := checkOverlaps(cfg, files, plan); err != nil {
+		return result, err
+	}
+
+	backupMgr, err := backupManager(cfg, files)
+	if err != nil {
+		return result, err
+	}
+	st, err := loadState(cfg)
+	if err != nil {
+		return result, err
+	}
+
+	backupMetadata := &backup.BackupMetadata{
+		ID:        backup.GenerateBackupID(),
+		Timestamp: time.Now(),
+		Command:   "symlinks install",
+		Entries:   []backup.BackupEntry{},
+	}
+
+	// backupTarget backs up whatever exists at the target before it is
+	// replaced. A failed backup does not stop the installation.
+	backupTarget := func(link Link) {
+		entry, err := backupMgr.CreateBackup(link.Target, backupMetadata.ID)
+		if err == nil {
+			backupMetadata.Entries = append(backupMetadata.Entries, *entry)
+		}
+		result.add(Change{Kind: ChangeBackedUp, Link: link, Err: err})
+	}
+
+	tracker := rollback.NewTrackerFS(files)
+	stateChanged := false
+	templateData := newTemplateData(cfg)
+
+	failure := func() error {
+		for _, link := range plan {
+			// Render templates before linking to their output
+			if link.Kind == KindTemplate {
+				content, err := renderLink(files, link, templateData)
+				if err != nil {
+					return linkError(OpRender, link, err)
+				}
+
+				if cfg.DryRun {
+					result.add(Change{Kind: ChangeRendered, Link: link, Diff: templateDiff(files, link, content)})
+					continue
+				}
+
+				if renderedChanged(files, link, content) {
+					if err := writeRendered(files, link, content, tracker); err != nil {
+						return linkError(OpRender, link, err)
+					}
+					result.add(Change{Kind: ChangeRendered, Link: link})
+				}
+			}
+
+			// Copy and hardlink modes materialize the source at the target
+			if link.Mode != ModeSymlink {
+				status, err := checkMaterialized(files, link, st, nil)
+				if err != nil {
+					return linkError(OpCheck, link, err)
+				}
+
+				switch status {
+				case StatusInstalled:
+					result.add(Change{Kind: ChangeUnchanged, Link: link, Status: status})
+					// Record adopted or relinked targets
+					if hash, err := hashFile(files, link.Target); err == nil && !cfg.DryRun {
+						st.Set(link.Target, state.FileState{Source: link.Source, Mode: link.Mode, Hash: hash})
+						stateChanged = true
+					}
+					continue
+				case StatusDrifted, StatusBlocked:
+					// Like Apply, local changes and unmanaged files are left alone
+					result.add(Change{Kind: ChangeSkipped, Link: link, Status: status})
+					continue
+				}
+
+				kind := ChangeCreated
+				if status != StatusMissing {
+					kind = ChangeUpdated
+				}
+				if cfg.DryRun {
+					result.add(Change{Kind: kind, Link: link, Status: status})
+					continue
+				}
+
+				if status != StatusMissing {
+					backupTarget(link)
+				}
+
+				hash, err := materialize(files, link, tracker)
+				if err != nil {
+					return linkError(OpCopy, link, err)
+				}
+				st.Set(link.Target, state.FileState{Source: link.Source, Mode: link.Mode, Hash: hash})
+				stateChanged = true
+				result.add(Change{Kind: kind, Link: link, Status: status})
+				continue
+			}
+
+			if cfg.DryRun {
+				result.add(Change{Kind: ChangeCreated, Link: link})
+				continue
+			}
+
+			// Back up whatever exists at the target
+			if _, err := files.Lstat(link.Target); err == nil {
+				backupTarget(link)
+			}
+
+			kind, status := ChangeCreated, StatusMissing
+			existingLink, err := files.Readlink(link.Target)
+			if err == nil {
+				if existingLink == link.Source {
+					result.add(Change{Kind: ChangeUnchanged, Link: link, Status: StatusInstalled})
+					continue
+				}
+
+				// Remove the existing link when it points somewhere else
+				if err := files.Remove(link.Target); err != nil {
+					return linkError(OpRemove, link, err)
+				}
+				tracker.TrackUpdated(link.Target, link.Source, existingLink)
+				kind, status = ChangeUpdated, StatusWrongTarget
+			} else if !os.IsNotExist(err) {
+				return linkError(OpCheck, link, err)
+			}
+
+			if err := files.Symlink(link.Source, link.Target); err != nil {
+				return linkError(OpLink, link, err)
+			}
+			if kind == ChangeCreated {
+				tracker.TrackCreated(link.Target, link.Source)
+			}
+			result.add(Change{Kind: kind, Link: link, Status: status, Previous: existingLink})
+		}
+		return nil
+	}()
+
+	if failure != nil && tracker.HasActions() {
+		rollbackChanges(result, tracker, failure)
+		return result, failure
+	}
+	notify.fail(failure)
+
+	// Save the state of copied and hard linked targets
+	if stateChanged {
+		if err := st.Save(); err != nil && failure == nil {
+			failure = &Error{Op: OpSaveState, Err: err}
+			notify.fail(failure)
+		}
+	}
+
+	// Save backup metadata if we created any backups
+	if len(backupMetadata.Entries) > 0 {
+		if err := backupMgr.SaveMetadata(backupMetadata); err != nil {
+			result.BackupErr = &Error{Op: OpSaveBackup, Err: err}
+			notify.emit(Event{Type: EventError, Err: result.BackupErr})
+		} else {
+			result.Backup = backupMetadata
+			notify.emit(Event{Type: EventBackup, Backup: backupMetadata})
+		}
+
+		// Keep only the newest backups when backup.max_backups is set
+		var pruneErr error
+		result.Pruned, pruneErr = backupMgr.Prune(cfg.Backup.MaxBackups)
+		if pruneErr != nil {
+			result.PruneErr = &Error{Op: OpPrune, Err: pruneErr}
+			notify.emit(Event{Type: EventError, Err: result.PruneErr})
+		}
+	}
+
+	return result, failure
+}
+
+// Uninstall removes the links of the symlinks file of cfg. Only symlinks
+// pointing to their source and copies unchanged since sok wrote them are
+// removed, other targets are skipped. With cfg.DryRun nothing is changed.
+func Uninstall(cfg *Config, opts RunOptions) (result *Result, err error) {
+	notify := newNotifier(cfg, opts.Observer)
+	defer func() { notify.fail(err) }()
+	files := fsys.Or(opts.FS)
+
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	symlinkFile, err := symlinksFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result = &Result{SymlinksFile: symlinkFile, DryRun: cfg.DryRun, notify: notify}
+
+	symlinkConfigs, plan, err := loadPlan(cfg, symlinkFile, files)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = len(symlinkConfigs)
+	notify.emit(Event{Type: EventLoaded, Path: symlinkFile, Count: result.Entries})
+	st, err := loadState(cfg)
+	if err != nil {
+		return result, err
+	}
+	stateChanged := false
+
+	for _, link := range plan {
+		status, current, err := targetStatus(files, link, st)
+		if err != nil {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Err: err})
+			continue
+		}
+
+		// Copies are removed even when their source changed since
+		removable := status == StatusInstalled || (status == StatusOutdated && link.Mode != ModeSymlink)
+		if !removable {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Status: status, Previous: current})
+			continue
+		}
+
+		if cfg.DryRun {
+			result.add(Change{Kind: ChangeRemoved, Link: link, Status: status})
+			continue
+		}
+
+		if err := files.Remove(link.Target); err != nil {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Status: status, Err: linkError(OpRemove, link, err)})
+			continue
+		}
+		if link.Mode != ModeSymlink {
+			// Forget removed copies
+			st.Delete(link.Target)
+			stateChanged = true
+		}
+		result.add(Change{Kind: ChangeRemoved, Link: link, Status: status})
+	}
+
+	if stateChanged {
+		if err := st.Save(); err != nil {
+			return result, &Error{Op: OpSaveState, Err: err}
+		}
+	}
+	return result, nil
+}
+
+// rollbackChanges undoes the changes tracked before failure, telling the
+// observer before and after
+func rollbackChanges(result *Result, tracker *rollback.Tracker, failure error) {
+	result.notify.fail(failure)
+
+	result.RolledBack = tracker.Count()
+	result.notify.emit(Event{Type: EventRollback, Count: result.RolledBack})
+	result.RollbackErr = tracker.Rollback()
+	result.notify.emit(Event{Type: EventRolledBack, Err: result.RollbackErr})
+}
+
+// validate checks the symlinks file, with the sources on files, before links
+// are changed. It fails with every issue found when there are errors, and
+// otherwise returns the warnings.
+func validate(cfg *Config, symlinkFile string, files fsys.FS) ([]Issue, error) {
+	opts := ValidateOptions{Resolve: NewResolveOptions(cfg, symlinkFile), DotfilesDir: cfg.DotfilesDir}
+	opts.Resolve.FS = files
+	issues, err := ValidateSymlinks(symlinkFile, opts)
+	if err != nil {
+		return nil, &Error{Op: OpLoad, Path: symlinkFile, Err: err}
+	}
+
+	for _, issue := range issues {
+		if !issue.Warning {
+			return nil, &Error{Op: OpValidate, Path: symlinkFile, Err: Issues(issues)}
+		}
+	}
+	return issues, nil
+}
+
+// checkOverlaps refuses plans with targets that would be written through
+// another link into the dotfiles repository
+func checkOverlaps(cfg *Config, files fsys.FS, plan []Link) error {
+	issues := CheckOverlaps(files, plan, expandPath(cfg.DotfilesDir))
+	if len(issues) > 0 {
+		return &Error{Op: OpOverlap, Err: Issues(issues)}
+	}
+	return nil
+}
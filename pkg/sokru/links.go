@@ -0,0 +1,329 @@
+// Package sokru
+// Description: This file contains the link resolution for symlinks.yaml entries, including glob expansion and templates.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/expr"
+	"github.com/alexlm78/sokru/internal/platform"
+	"github.com/alexlm78/sokru/internal/render"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// Link kinds
+const (
+	KindLink     = "link"     // Target is a symlink to the source
+	KindTemplate = "template" // Source is rendered and the target links to the output
+)
+
+// Link modes
+const (
+	ModeSymlink  = "symlink"  // Target is a symbolic link
+	ModeCopy     = "copy"     // Target is a copy of the source
+	ModeHardlink = "hardlink" // Target is a hard link to the source
+)
+
+// LinkSpec is a structured link definition. When Source is a glob pattern,
+// Target is treated as a directory and one link is created per match.
+type LinkSpec struct {
+	Target  string            `yaml:"target" schema:"required"`
+	Source  string            `yaml:"source" schema:"required"`
+	Exclude []string          `yaml:"exclude,omitempty"`
+	Kind    string            `yaml:"kind,omitempty" schema:"enum=link|template"`
+	Mode    string            `yaml:"mode,omitempty" schema:"enum=symlink|copy|hardlink"`
+	Vars    map[string]string `yaml:"vars,omitempty"`
+	When    string            `yaml:"when,omitempty"`
+
+	// File, Line and Column locate the link in the symlinks files
+	File   string `yaml:"-"`
+	Line   int    `yaml:"-"`
+	Column int    `yaml:"-"`
+}
+
+// Link is a resolved link with expanded paths
+type Link struct {
+	Target string
+	// Source is what the target links to. For templates this is the
+	// rendered output, not the template itself.
+	Source   string
+	Kind     string
+	Mode     string
+	Template string
+	Vars     map[string]string
+	// File, Line and Column locate the definition of the link
+	File   string
+	Line   int
+	Column int
+}
+
+// ResolveOptions controls how the links of an entry are resolved
+type ResolveOptions struct {
+	// Platform is the machine the links are resolved for
+	Platform platform.Info
+	// BaseDir is the directory relative sources are resolved against
+	BaseDir string
+	// RenderDir is the directory where rendered templates are written
+	RenderDir string
+	// FS is the filesystem sources are looked up and globbed on, the real
+	// one when nil
+	FS fsys.FS
+}
+
+// NewResolveOptions builds the resolve options from the configuration.
+// Relative sources are resolved against DotfilesDir, falling back to the
+// directory containing the symlinks file.
+func NewResolveOptions(cfg *Config, symlinkFile string) ResolveOptions {
+	baseDir := expandPath(cfg.DotfilesDir)
+	if baseDir == "" {
+		baseDir = filepath.Dir(symlinkFile)
+	}
+
+	// A missing render directory only matters once a template is resolved
+	var renderDir string
+	if _, dataDir, err := cfg.Dirs(); err == nil {
+		renderDir = render.RenderDir(cfg.Home, dataDir)
+	}
+
+	return ResolveOptions{
+		Platform:  platform.Detect(cfg.OS),
+		BaseDir:   baseDir,
+		RenderDir: renderDir,
+	}
+}
+
+// ResolveLinks returns the links for the given options with paths expanded,
+// relative sources anchored to BaseDir and glob sources replaced by one link
+// per matching file
+func (sc *SymlinkConfig) ResolveLinks(opts ResolveOptions) (map[string]Link, error) {
+	links := make(map[string]Link)
+
+	for pattern := range sc.Hosts {
+		if _, err := platform.MatchHost(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+		}
+	}
+
+	if ok, err := evalWhen(sc.When, opts); err != nil || !ok {
+		return links, err
+	}
+
+	for target, link := range sc.sectionLinks(opts.Platform) {
+		spec := LinkSpec{Target: target, Source: link.source, File: sc.File, Line: sc.Line, Column: sc.Column}
+		if node, ok := sc.targetNodes[sectionTarget{link.section, target}]; ok {
+			spec.Line, spec.Column = node.Line, node.Column
+		}
+		if err := addResolvedLinks(links, spec, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Structured links follow the same selectors as the rest of the entry
+	if !sc.Matches(opts.Platform) {
+		return links, nil
+	}
+
+	for _, spec := range sc.Links {
+		if spec.Target == "" || spec.Source == "" {
+			return nil, fmt.Errorf("link requires both target and source (target: %q, source: %q)", spec.Target, spec.Source)
+		}
+		if ok, err := evalWhen(spec.When, opts); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		spec.File = sc.File
+		if spec.Line == 0 {
+			spec.Line, spec.Column = sc.Line, sc.Column
+		}
+		if err := addResolvedLinks(links, spec, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return links, nil
+}
+
+// evalWhen evaluates a when expression against the machine. An empty
+// expression is always true.
+func evalWhen(when string, opts ResolveOptions) (bool, error) {
+	if strings.TrimSpace(when) == "" {
+		return true, nil
+	}
+
+	ctx := expr.Context{
+		Vars: map[string]any{
+			"os":       opts.Platform.OS,
+			"arch":     opts.Platform.Arch,
+			"hostname": opts.Platform.Hostname,
+			"distro":   opts.Platform.Distro,
+			"wsl":      opts.Platform.WSL,
+			"desktop":  expr.Set(opts.Platform.Desktops),
+		},
+		Env: os.Getenv,
+		Funcs: map[string]func(args ...string) (any, error){
+			"exists": func(args ...string) (any, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("expects 1 argument, got %d", len(args))
+				}
+				path, err := resolveSource(args[0], opts.BaseDir)
+				if err != nil {
+					return nil, err
+				}
+				_, err = fsys.Or(opts.FS).Stat(path)
+				return err == nil, nil
+			},
+		},
+	}
+
+	ok, err := expr.Eval(when, ctx)
+	if err != nil {
+		return false, fmt.Errorf("invalid when expression %q: %w", when, err)
+	}
+	return ok, nil
+}
+
+// addResolvedLinks expands a single link spec into links
+func addResolvedLinks(links map[string]Link, spec LinkSpec, opts ResolveOptions) error {
+	kind := spec.Kind
+	if kind == "" {
+		kind = KindLink
+	}
+	if kind != KindLink && kind != KindTemplate {
+		return fmt.Errorf("unknown kind %q for target %q", spec.Kind, spec.Target)
+	}
+
+	mode := spec.Mode
+	if mode == "" {
+		mode = ModeSymlink
+	}
+	if mode != ModeSymlink && mode != ModeCopy && mode != ModeHardlink {
+		return fmt.Errorf("unknown mode %q for target %q", spec.Mode, spec.Target)
+	}
+
+	targetPath, err := expandPathStrict(spec.Target)
+	if err != nil {
+		return fmt.Errorf("invalid target %q: %w", spec.Target, err)
+	}
+	sourcePath, err := resolveSource(spec.Source, opts.BaseDir)
+	if err != nil {
+		return fmt.Errorf("invalid source %q: %w", spec.Source, err)
+	}
+
+	sources := []string{sourcePath}
+	targets := []string{targetPath}
+	if hasGlobMeta(sourcePath) {
+		sources, err = expandGlob(fsys.Or(opts.FS), sourcePath, spec.Exclude)
+		if err != nil {
+			return fmt.Errorf("invalid glob %q: %w", spec.Source, err)
+		}
+		targets = make([]string, len(sources))
+		for i, match := range sources {
+			targets[i] = filepath.Join(targetPath, filepath.Base(match))
+		}
+	}
+
+	for i, source := range sources {
+		link := Link{
+			Target: targets[i],
+			Source: source,
+			Kind:   kind,
+			Mode:   mode,
+			Vars:   spec.Vars,
+			File:   spec.File,
+			Line:   spec.Line,
+			Column: spec.Column,
+		}
+		if kind == KindTemplate {
+			if opts.RenderDir == "" {
+				return fmt.Errorf("no directory available for rendered template %q", source)
+			}
+			link.Template = source
+			link.Source = render.RenderedPath(opts.RenderDir, link.Target)
+		}
+		links[link.Target] = link
+	}
+
+	return nil
+}
+
+// resolveSource expands source and anchors it to baseDir when it is relative
+func resolveSource(source, baseDir string) (string, error) {
+	sourcePath, err := expandPathStrict(source)
+	if err != nil {
+		return "", err
+	}
+	if baseDir != "" && !filepath.IsAbs(sourcePath) {
+		return filepath.Join(baseDir, sourcePath), nil
+	}
+	return sourcePath, nil
+}
+
+// expandGlob returns the sorted files matching pattern, minus excluded ones;
+// directories and symlinks to them are skipped. Exclude patterns are matched
+// against both the base name and the full path.
+func expandGlob(files fsys.FS, pattern string, exclude []string) ([]string, error) {
+	// Glob below the directory before the first metacharacter
+	dir := filepath.Dir(pattern[:strings.IndexAny(pattern, "*?[")])
+	rel, err := filepath.Rel(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := fs.Glob(fsys.DirFS(files, dir), filepath.ToSlash(rel))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, name := range matches {
+		match := filepath.Join(dir, filepath.FromSlash(name))
+		if info, err := files.Stat(match); err == nil && info.IsDir() {
+			continue
+		}
+		excluded, err := isExcluded(match, exclude)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			result = append(result, match)
+		}
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// isExcluded reports whether path matches any of the exclude patterns
+func isExcluded(path string, exclude []string) (bool, error) {
+	for _, raw := range exclude {
+		pattern, err := expandPathStrict(raw)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude pattern %q: %w", raw, err)
+		}
+
+		matched, err := filepath.Match(pattern, filepath.Base(path))
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude pattern %q: %w", raw, err)
+		}
+		if matched {
+			return true, nil
+		}
+
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasGlobMeta reports whether path contains any glob metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
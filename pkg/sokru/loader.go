@@ -0,0 +1,517 @@
+// Package sokru
+// Description: This file contains the loader shared by all commands to read symlinks.yaml and the files it includes.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/format"
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/internal/schema"
+	"github.com/alexlm78/sokru/pkg/fsys"
+	"gopkg.in/yaml.v3"
+)
+
+// StringList is a list of strings that can also be written as a single string
+type StringList []string
+
+// UnmarshalYAML accepts both a scalar and a sequence of scalars
+func (sl *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*sl = StringList{node.Value}
+		return nil
+	}
+
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*sl = list
+	return nil
+}
+
+// JSONSchema describes both forms StringList accepts
+func (StringList) JSONSchema() *schema.Schema {
+	return &schema.Schema{OneOf: []*schema.Schema{
+		{Type: "string"},
+		{Type: "array", Items: &schema.Schema{Type: "string"}},
+	}}
+}
+
+// Issue is a problem found in the symlinks files
+type Issue struct {
+	File   string
+	Line   int
+	Column int
+	// Message is already localized
+	Message string
+	// Warning issues do not prevent links from being installed
+	Warning bool
+}
+
+// String formats the issue as file:line:column: severity: message
+func (i Issue) String() string {
+	severity := i18n.T(i18n.MsgIssueError)
+	if i.Warning {
+		severity = i18n.T(i18n.MsgIssueWarning)
+	}
+	if position := formatPosition(i.File, i.Line, i.Column); position != "" {
+		return fmt.Sprintf("%s: %s: %s", position, severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", severity, i.Message)
+}
+
+// Error allows an issue to be returned as an error
+func (i Issue) Error() string {
+	return i.String()
+}
+
+// Issues are the problems that prevent the symlinks files from being loaded
+type Issues []Issue
+
+// Error formats one issue per line
+func (is Issues) Error() string {
+	lines := make([]string, len(is))
+	for i, issue := range is {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPosition formats file:line:column, leaving out the parts that are
+// not known. TOML files, for one, have no line numbers.
+func formatPosition(file string, line, column int) string {
+	switch {
+	case file == "":
+		return ""
+	case line == 0:
+		return file
+	case column == 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
+	}
+}
+
+// issueAt returns an error located at a node of file
+func issueAt(file string, node *yaml.Node, key i18n.MessageKey, args ...any) Issue {
+	return Issue{File: file, Line: node.Line, Column: node.Column, Message: i18n.T(key, args...)}
+}
+
+// site is the include a file is loaded from
+type site struct {
+	file string
+	node *yaml.Node
+}
+
+// String formats the position of the include
+func (s site) String() string {
+	return formatPosition(s.file, s.node.Line, s.node.Column)
+}
+
+// Keys accepted in the symlinks files
+var (
+	entryKeys   = yamlKeys(reflect.TypeOf(SymlinkConfig{}))
+	linkKeys    = yamlKeys(reflect.TypeOf(LinkSpec{}))
+	profileKeys = yamlKeys(reflect.TypeOf(ProfileSpec{}))
+)
+
+// entrySchema describes the values of an entry
+var entrySchema = schema.For(reflect.TypeOf(SymlinkConfig{}))
+
+// symlinksLoader loads a symlinks file and, recursively, the files it includes
+type symlinksLoader struct {
+	// stack holds the files being loaded, to detect include cycles
+	stack []string
+	// included maps every loaded file to where it was included from
+	included map[string]string
+	// issues are the unknown keys found while loading
+	issues []Issue
+	// errors are the problems that prevent the files from being loaded
+	errors Issues
+}
+
+// LoadSymlinkConfigs reads the entries of a symlinks file. Items with an
+// "include" key are replaced by the entries of the files they name, which
+// may be glob patterns relative to the including file.
+func LoadSymlinkConfigs(path string) ([]SymlinkConfig, error) {
+	symlinkConfigs, _, err := loadSymlinkFiles(path)
+	return symlinkConfigs, err
+}
+
+// loadSymlinkFiles loads the entries of a symlinks file together with the
+// unknown keys found in it
+func loadSymlinkFiles(path string) ([]SymlinkConfig, []Issue, error) {
+	l := &symlinksLoader{included: make(map[string]string)}
+	symlinkConfigs, err := l.load(path, nil)
+	if err == nil && len(l.errors) > 0 {
+		err = l.errors
+	}
+	return symlinkConfigs, l.issues, err
+}
+
+// LoadSymlinks loads the symlinks file and selects the entries of the
+// profile of cfg
+func LoadSymlinks(cfg *Config, symlinkFile string) ([]SymlinkConfig, error) {
+	symlinkConfigs, err := LoadSymlinkConfigs(symlinkFile)
+	if err != nil {
+		return nil, err
+	}
+	return SelectProfile(symlinkConfigs, cfg.Profile)
+}
+
+// load reads the file at path. from is the include that refers to it, nil
+// for the top level file.
+func (l *symlinksLoader) load(path string, from *site) ([]SymlinkConfig, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, loading := range l.stack {
+		if loading == absPath {
+			cycle := append(append([]string{}, l.stack[i:]...), absPath)
+			return nil, l.fail(issueAt(from.file, from.node, i18n.MsgIncludeCycle, strings.Join(cycle, " -> ")))
+		}
+	}
+	if previous, ok := l.included[absPath]; ok {
+		if previous == "" {
+			return nil, l.fail(issueAt(from.file, from.node, i18n.MsgIncludeTopLevel, path))
+		}
+		return nil, l.fail(issueAt(from.file, from.node, i18n.MsgIncludeDuplicate, path, previous))
+	}
+	l.included[absPath] = ""
+	if from != nil {
+		l.included[absPath] = from.String()
+	}
+
+	l.stack = append(l.stack, absPath)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if from != nil {
+			return nil, l.fail(issueAt(from.file, from.node, i18n.MsgIncludeUnreadable, err))
+		}
+		return nil, err
+	}
+
+	root, err := format.Parse(path, data)
+	if err != nil {
+		var syntaxErr *format.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, l.fail(Issue{
+				File:    path,
+				Line:    syntaxErr.Line,
+				Column:  syntaxErr.Column,
+				Message: i18n.T(i18n.MsgSyntaxError, syntaxErr.Message),
+			})
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// An empty file has no entries
+	if root == nil {
+		return nil, nil
+	}
+	root = entriesNode(root)
+	if root.Kind != yaml.SequenceNode {
+		return nil, l.fail(issueAt(path, root, i18n.MsgExpectedEntries))
+	}
+
+	var symlinkConfigs []SymlinkConfig
+	for _, item := range root.Content {
+		if !l.check(item, path) {
+			continue
+		}
+
+		var entry SymlinkConfig
+		if err := item.Decode(&entry); err != nil {
+			l.errors = append(l.errors, issueAt(path, item, i18n.MsgInvalidEntry, err))
+			continue
+		}
+		entry.File = path
+		entry.Line = item.Line
+		entry.Column = item.Column
+		l.inspect(&entry, item, path)
+
+		if len(entry.Include) == 0 {
+			symlinkConfigs = append(symlinkConfigs, entry)
+			continue
+		}
+
+		if len(item.Content) != 2 {
+			l.errors = append(l.errors, issueAt(path, item, i18n.MsgIncludeWithOtherKeys))
+			continue
+		}
+
+		// Each pattern is reported at its own position
+		value := item.Content[1]
+		for i, pattern := range entry.Include {
+			node := value
+			if value.Kind == yaml.SequenceNode {
+				node = value.Content[i]
+			}
+			included, err := l.include(pattern, site{file: path, node: node})
+			if err != nil {
+				return nil, err
+			}
+			symlinkConfigs = append(symlinkConfigs, included...)
+		}
+	}
+
+	return symlinkConfigs, nil
+}
+
+// fail returns the errors found so far, ending with issue
+func (l *symlinksLoader) fail(issue Issue) error {
+	return append(l.errors, issue)
+}
+
+// check reports the values of an entry that do not match the format and
+// whether the entry can be decoded
+func (l *symlinksLoader) check(item *yaml.Node, file string) bool {
+	if item.Kind != yaml.MappingNode {
+		l.errors = append(l.errors, issueAt(file, item, i18n.MsgExpectedEntry, typeName(schema.NodeType(item))))
+		return false
+	}
+
+	violations := schema.Check(item, entrySchema)
+	for _, violation := range violations {
+		l.errors = append(l.errors, violationIssue(file, violation))
+	}
+	return len(violations) == 0
+}
+
+// violationIssue describes a schema violation
+func violationIssue(file string, v schema.Violation) Issue {
+	switch v.Problem {
+	case schema.NotAllowed:
+		return issueAt(file, v.Node, i18n.MsgValueNotAllowed, v.Path, strings.Join(v.Expected, ", "), v.Got)
+	case schema.Missing:
+		return issueAt(file, v.Node, i18n.MsgMissingKey, v.Path)
+	default:
+		expected := make([]string, len(v.Expected))
+		for i, typ := range v.Expected {
+			expected[i] = typeName(typ)
+		}
+		return issueAt(file, v.Node, i18n.MsgWrongType, v.Path, strings.Join(expected, " "+i18n.T(i18n.MsgOr)+" "), typeName(v.Got))
+	}
+}
+
+// typeName returns the localized name of a schema type
+func typeName(typ string) string {
+	switch typ {
+	case "boolean":
+		return i18n.T(i18n.MsgTypeBoolean)
+	case "integer":
+		return i18n.T(i18n.MsgTypeInteger)
+	case "number":
+		return i18n.T(i18n.MsgTypeNumber)
+	case "object":
+		return i18n.T(i18n.MsgTypeObject)
+	case "array":
+		return i18n.T(i18n.MsgTypeArray)
+	case "null":
+		return i18n.T(i18n.MsgTypeNull)
+	default:
+		return i18n.T(i18n.MsgTypeString)
+	}
+}
+
+// entriesNode returns the list of entries of a document. The list is either
+// the document itself or, since a TOML document cannot be a list, the value
+// of its only key, "entries".
+func entriesNode(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.MappingNode && len(root.Content) == 2 && root.Content[0].Value == "entries" {
+		return root.Content[1]
+	}
+	return root
+}
+
+// include loads the files matching pattern, included from site
+func (l *symlinksLoader) include(pattern string, from site) ([]SymlinkConfig, error) {
+	expanded, err := expandPathStrict(pattern)
+	if err != nil {
+		return nil, l.fail(issueAt(from.file, from.node, i18n.MsgInvalidInclude, pattern, err))
+	}
+	if !filepath.IsAbs(expanded) {
+		expanded = filepath.Join(filepath.Dir(from.file), expanded)
+	}
+
+	matches := []string{expanded}
+	if hasGlobMeta(expanded) {
+		// A pattern matching nothing includes nothing
+		matches, err = expandGlob(fsys.OS{}, expanded, nil)
+		if err != nil {
+			return nil, l.fail(issueAt(from.file, from.node, i18n.MsgInvalidInclude, pattern, err))
+		}
+	} else if _, err := os.Stat(expanded); errors.Is(err, os.ErrNotExist) {
+		return nil, l.fail(issueAt(from.file, from.node, i18n.MsgIncludeNotFound, expanded))
+	}
+
+	var symlinkConfigs []SymlinkConfig
+	for _, match := range matches {
+		entries, err := l.load(match, &from)
+		if err != nil {
+			return nil, err
+		}
+		symlinkConfigs = append(symlinkConfigs, entries...)
+	}
+
+	return symlinkConfigs, nil
+}
+
+// inspect records the positions of the targets of an entry and reports
+// empty sources and the keys that are not part of the format
+func (l *symlinksLoader) inspect(entry *SymlinkConfig, item *yaml.Node, file string) {
+	entry.targetNodes = make(map[sectionTarget]*yaml.Node)
+
+	recordTargets := func(name string, section *yaml.Node) {
+		if section.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(section.Content); i += 2 {
+			target, source := section.Content[i], section.Content[i+1]
+			entry.targetNodes[sectionTarget{name, target.Value}] = target
+			if source.Value == "" {
+				l.errors = append(l.errors, issueAt(file, target, i18n.MsgEmptySource, target.Value))
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(item.Content); i += 2 {
+		key, value := item.Content[i], item.Content[i+1]
+		l.checkKey(key, entryKeys, file)
+
+		switch key.Value {
+		case "link", "common", "linux", "darwin", "windows":
+			recordTargets(key.Value, value)
+		case "hosts":
+			for j := 1; j < len(value.Content); j += 2 {
+				recordTargets("hosts."+value.Content[j-1].Value, value.Content[j])
+			}
+		case "links":
+			for j, linkNode := range value.Content {
+				if j < len(entry.Links) {
+					entry.Links[j].Line = linkNode.Line
+					entry.Links[j].Column = linkNode.Column
+				}
+				l.checkKeys(linkNode, linkKeys, file)
+				l.checkLinkSource(linkNode, file)
+			}
+		case "profiles":
+			for j := 1; j < len(value.Content); j += 2 {
+				l.checkKeys(value.Content[j], profileKeys, file)
+			}
+		}
+	}
+}
+
+// checkLinkSource reports a structured link whose source is set but empty.
+// A missing source is reported by the schema.
+func (l *symlinksLoader) checkLinkSource(linkNode *yaml.Node, file string) {
+	var target string
+	var source *yaml.Node
+	for i := 0; i+1 < len(linkNode.Content); i += 2 {
+		switch linkNode.Content[i].Value {
+		case "target":
+			target = linkNode.Content[i+1].Value
+		case "source":
+			source = linkNode.Content[i+1]
+		}
+	}
+
+	if source != nil && source.Value == "" {
+		l.errors = append(l.errors, issueAt(file, source, i18n.MsgEmptySource, target))
+	}
+}
+
+// checkKeys reports the keys of a mapping node that are not in known
+func (l *symlinksLoader) checkKeys(node *yaml.Node, known map[string]bool, file string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		l.checkKey(node.Content[i], known, file)
+	}
+}
+
+// checkKey reports key when it is not in known, suggesting the closest matches
+func (l *symlinksLoader) checkKey(key *yaml.Node, known map[string]bool, file string) {
+	if known[key.Value] {
+		return
+	}
+
+	issue := issueAt(file, key, i18n.MsgUnknownKey, key.Value)
+	if suggestions := closestKeys(key.Value, known); len(suggestions) > 0 {
+		issue = issueAt(file, key, i18n.MsgUnknownKeySuggestion, key.Value, strings.Join(suggestions, " "+i18n.T(i18n.MsgOr)+" "))
+	}
+	l.issues = append(l.issues, issue)
+}
+
+// yamlKeys returns the yaml keys of the fields of a struct type
+func yamlKeys(t reflect.Type) map[string]bool {
+	keys := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// closestKeys returns the quoted known keys within two edits of key that
+// are closest to it
+func closestKeys(key string, known map[string]bool) []string {
+	var best []string
+	bestDistance := 3
+
+	for candidate := range known {
+		distance := editDistance(key, candidate)
+		switch {
+		case distance < bestDistance:
+			best, bestDistance = []string{candidate}, distance
+		case distance == bestDistance:
+			best = append(best, candidate)
+		}
+	}
+
+	sort.Strings(best)
+	for i, candidate := range best {
+		best[i] = fmt.Sprintf("%q", candidate)
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
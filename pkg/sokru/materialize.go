@@ -0,0 +1,162 @@
+// Package sokru
+// Description: This file contains the copy and hardlink modes, which materialize sources at their targets instead of symlinking them.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// checkMaterialized compares the target of a copy or hardlink link with its
+// source and the hash recorded when it was last written. pending, when not
+// nil, is source content that is about to be written and replaces the source
+// on disk in the comparison.
+func checkMaterialized(files fsys.FS, link Link, st *state.State, pending []byte) (Status, error) {
+	info, err := files.Lstat(link.Target)
+	if os.IsNotExist(err) {
+		return StatusMissing, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// A symlink to the source, like one from before the entry used this mode,
+	// is replaced
+	if info.Mode()&os.ModeSymlink != 0 && linksTo(files, link.Target, link.Source) {
+		return StatusOutdated, nil
+	}
+	if !info.Mode().IsRegular() {
+		return StatusBlocked, nil
+	}
+
+	// An intact hard link always matches its source
+	if link.Mode == ModeHardlink {
+		if sourceInfo, err := files.Stat(link.Source); err == nil && files.SameFile(info, sourceInfo) {
+			return StatusInstalled, nil
+		}
+	}
+
+	targetHash, err := hashFile(files, link.Target)
+	if err != nil {
+		return "", err
+	}
+	sourceHash := state.HashBytes(pending)
+	if pending == nil {
+		sourceHash, err = hashFile(files, link.Source)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	recorded, ok := st.Get(link.Target)
+	if !ok {
+		// A matching copy can be adopted, anything else is left alone
+		if link.Mode == ModeCopy && targetHash == sourceHash {
+			return StatusInstalled, nil
+		}
+		return StatusBlocked, nil
+	}
+
+	if targetHash != recorded.Hash {
+		return StatusDrifted, nil
+	}
+	// A hard link that no longer shares the source's inode needs relinking
+	if targetHash != sourceHash || link.Mode == ModeHardlink {
+		return StatusOutdated, nil
+	}
+
+	return StatusInstalled, nil
+}
+
+// linksTo reports whether the symlink at target points to source
+func linksTo(files fsys.FS, target, source string) bool {
+	dest, err := files.Readlink(target)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(target), dest)
+	}
+	return filepath.Clean(dest) == filepath.Clean(source)
+}
+
+// materialize replaces the target of link with a copy of, or a hard link to,
+// its source. Every change is tracked for rollback. It returns the hash to
+// record in the state.
+func materialize(files fsys.FS, link Link, tracker *rollback.Tracker) (string, error) {
+	info, err := files.Lstat(link.Target)
+	existed := err == nil
+
+	switch {
+	case os.IsNotExist(err):
+		// Nothing to replace
+	case err != nil:
+		return "", err
+	case info.Mode()&os.ModeSymlink != 0:
+		previous, err := files.Readlink(link.Target)
+		if err != nil {
+			return "", err
+		}
+		if err := files.Remove(link.Target); err != nil {
+			return "", err
+		}
+		tracker.TrackUpdated(link.Target, link.Source, previous)
+	case info.Mode().IsRegular():
+		previous, err := files.ReadFile(link.Target)
+		if err != nil {
+			return "", err
+		}
+		// Remove instead of overwriting so a hard linked file is never written through
+		if err := files.Remove(link.Target); err != nil {
+			return "", err
+		}
+		tracker.TrackWritten(link.Target, previous, info.Mode(), true)
+	default:
+		return "", fmt.Errorf("%s is not a regular file", link.Target)
+	}
+
+	if link.Mode == ModeHardlink {
+		err = files.Link(link.Source, link.Target)
+	} else {
+		err = copyFile(files, link.Source, link.Target)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !existed {
+		tracker.TrackWritten(link.Target, nil, 0, false)
+	}
+
+	return hashFile(files, link.Target)
+}
+
+// copyFile copies src to dst, keeping the permissions of src
+func copyFile(files fsys.FS, src, dst string) error {
+	sourceInfo, err := files.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := files.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return files.WriteFile(dst, data, sourceInfo.Mode().Perm())
+}
+
+// hashFile returns the hash of the content of path, as recorded in the state
+func hashFile(files fsys.FS, path string) (string, error) {
+	data, err := files.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return state.HashBytes(data), nil
+}
@@ -0,0 +1,237 @@
+// Package sokru
+// Description: Unit tests for the states of copied and hard linked targets, and how materialize replaces them.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// newMaterializeFS returns a MemFS with the source /dotfiles/vimrc and an
+// empty state
+func newMaterializeFS(t *testing.T) (*fsys.MemFS, *state.State) {
+	t.Helper()
+
+	mem := fsys.NewMemFS()
+	for _, dir := range []string{"/dotfiles", "/home/user"} {
+		if err := mem.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mem.WriteFile("/dotfiles/vimrc", []byte("set number\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := state.Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mem, st
+}
+
+func TestCheckMaterialized(t *testing.T) {
+	const target, source = "/home/user/.vimrc", "/dotfiles/vimrc"
+
+	tests := []struct {
+		name  string
+		mode  string
+		setup func(t *testing.T, mem *fsys.MemFS, st *state.State)
+		want  Status
+	}{
+		{
+			name: "missing",
+			mode: ModeCopy,
+			want: StatusMissing,
+		},
+		{
+			name: "symlink to the source",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.Symlink(source, target))
+			},
+			want: StatusOutdated,
+		},
+		{
+			name: "relative symlink to the source",
+			mode: ModeHardlink,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.Symlink("../../dotfiles/vimrc", target))
+			},
+			want: StatusOutdated,
+		},
+		{
+			name: "symlink elsewhere",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.Symlink("/elsewhere", target))
+			},
+			want: StatusBlocked,
+		},
+		{
+			name: "directory",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.MkdirAll(target, 0755))
+			},
+			want: StatusBlocked,
+		},
+		{
+			name: "unrecorded matching copy is adopted",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set number\n"), 0644))
+			},
+			want: StatusInstalled,
+		},
+		{
+			name: "unrecorded file",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set nonumber\n"), 0644))
+			},
+			want: StatusBlocked,
+		},
+		{
+			name: "recorded copy edited",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set nonumber\n"), 0644))
+				st.Set(target, state.FileState{Source: source, Mode: ModeCopy, Hash: state.HashBytes([]byte("set number\n"))})
+			},
+			want: StatusDrifted,
+		},
+		{
+			name: "recorded copy of an old source",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set ruler\n"), 0644))
+				st.Set(target, state.FileState{Source: source, Mode: ModeCopy, Hash: state.HashBytes([]byte("set ruler\n"))})
+			},
+			want: StatusOutdated,
+		},
+		{
+			name: "recorded copy",
+			mode: ModeCopy,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set number\n"), 0644))
+				st.Set(target, state.FileState{Source: source, Mode: ModeCopy, Hash: state.HashBytes([]byte("set number\n"))})
+			},
+			want: StatusInstalled,
+		},
+		{
+			name: "hard link",
+			mode: ModeHardlink,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.Link(source, target))
+			},
+			want: StatusInstalled,
+		},
+		{
+			name: "recorded hard link broken by an editor",
+			mode: ModeHardlink,
+			setup: func(t *testing.T, mem *fsys.MemFS, st *state.State) {
+				must(t, mem.WriteFile(target, []byte("set number\n"), 0644))
+				st.Set(target, state.FileState{Source: source, Mode: ModeHardlink, Hash: state.HashBytes([]byte("set number\n"))})
+			},
+			want: StatusOutdated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem, st := newMaterializeFS(t)
+			if tt.setup != nil {
+				tt.setup(t, mem, st)
+			}
+
+			got, err := checkMaterialized(mem, Link{Target: target, Source: source, Mode: tt.mode}, st, nil)
+			if err != nil {
+				t.Fatalf("checkMaterialized() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkMaterialized() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMaterializedPending(t *testing.T) {
+	// Pending source content replaces the source in the comparison
+	mem, st := newMaterializeFS(t)
+	link := Link{Target: "/home/user/.vimrc", Source: "/dotfiles/vimrc", Mode: ModeCopy}
+	must(t, mem.WriteFile(link.Target, []byte("set number\n"), 0644))
+	st.Set(link.Target, state.FileState{Source: link.Source, Mode: ModeCopy, Hash: state.HashBytes([]byte("set number\n"))})
+
+	got, err := checkMaterialized(mem, link, st, []byte("set ruler\n"))
+	if err != nil || got != StatusOutdated {
+		t.Errorf("checkMaterialized() = %s, %v, want %s", got, err, StatusOutdated)
+	}
+}
+
+func TestMaterializeReplacesSymlinkToSource(t *testing.T) {
+	for _, mode := range []string{ModeCopy, ModeHardlink} {
+		t.Run(mode, func(t *testing.T) {
+			mem, _ := newMaterializeFS(t)
+			link := Link{Target: "/home/user/.vimrc", Source: "/dotfiles/vimrc", Mode: mode}
+			must(t, mem.Symlink(link.Source, link.Target))
+
+			tracker := rollback.NewTrackerFS(mem)
+			hash, err := materialize(mem, link, tracker)
+			if err != nil {
+				t.Fatalf("materialize() error = %v", err)
+			}
+			if hash != state.HashBytes([]byte("set number\n")) {
+				t.Errorf("materialize() hash = %s, want the hash of the source", hash)
+			}
+			info, err := mem.Lstat(link.Target)
+			if err != nil || !info.Mode().IsRegular() {
+				t.Fatalf("target = %v, %v, want a regular file", info, err)
+			}
+
+			// Rolling back brings the symlink back
+			if err := tracker.Rollback(); err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+			if dest, err := mem.Readlink(link.Target); err != nil || dest != link.Source {
+				t.Errorf("target after Rollback() = %q, %v, want a symlink to %s", dest, err, link.Source)
+			}
+		})
+	}
+}
+
+func TestMaterializeRollbackKeepsMode(t *testing.T) {
+	// A private file replaced by a copy gets its permissions back
+	mem, _ := newMaterializeFS(t)
+	link := Link{Target: "/home/user/.vimrc", Source: "/dotfiles/vimrc", Mode: ModeCopy}
+	must(t, mem.WriteFile(link.Target, []byte("secret\n"), 0600))
+
+	tracker := rollback.NewTrackerFS(mem)
+	if _, err := materialize(mem, link, tracker); err != nil {
+		t.Fatalf("materialize() error = %v", err)
+	}
+	if err := tracker.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	info, err := mem.Stat(link.Target)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("target after Rollback() = %v, %v, want mode 0600", info, err)
+	}
+	if content, _ := mem.ReadFile(link.Target); string(content) != "secret\n" {
+		t.Errorf("target after Rollback() = %q, want its old content", content)
+	}
+}
+
+// must fails the test on err
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
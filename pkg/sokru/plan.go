@@ -0,0 +1,244 @@
+// Package sokru
+// Description: This file contains the planner, which resolves the links of all entries into a deterministic processing order.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// PlanLinks resolves the links of the entries in the order they are
+// processed. Entries are grouped in stages by their "after" dependencies,
+// and the links of each stage are sorted by target with parent directories
+// before their children. Validation rejects targets defined by several
+// entries, so Install and NewPlan only plan them with SkipValidation, and
+// then the one processed last wins.
+func PlanLinks(symlinkConfigs []SymlinkConfig, opts ResolveOptions) ([]Link, error) {
+	stages, err := orderEntries(symlinkConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage each target was last defined in
+	stageOf := make(map[string]int)
+	links := make(map[string]Link)
+	for stage, entries := range stages {
+		for _, entry := range entries {
+			entryLinks, err := entry.ResolveLinks(opts)
+			if err != nil {
+				return nil, entryIssue(entry, err.Error())
+			}
+			// Map order is random, but targets within an entry are unique
+			for target, link := range entryLinks {
+				links[target] = link
+				stageOf[target] = stage
+			}
+		}
+	}
+
+	plan := make([]Link, 0, len(links))
+	for _, link := range links {
+		plan = append(plan, link)
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		si, sj := stageOf[plan[i].Target], stageOf[plan[j].Target]
+		if si != sj {
+			return si < sj
+		}
+		return compareTargets(plan[i].Target, plan[j].Target) < 0
+	})
+
+	return plan, nil
+}
+
+// CheckOverlaps reports the links of a plan whose target would be written
+// through another link: targets nested under another managed target, and
+// targets under a directory that resolves into dotfilesDir. Either way the
+// change would end up in the dotfiles repository instead of the home
+// directory. Symlinks are read through files, the real filesystem when nil.
+func CheckOverlaps(files fsys.FS, plan []Link, dotfilesDir string) []Issue {
+	files = fsys.Or(files)
+
+	var issues []Issue
+
+	// Sorted by target, parents come right before their children, so the
+	// managed ancestors of a target are on the stack when it is reached
+	sorted := append([]Link(nil), plan...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return compareTargets(sorted[i].Target, sorted[j].Target) < 0
+	})
+
+	var managed []Link
+	for _, link := range sorted {
+		for len(managed) > 0 && !isWithin(link.Target, managed[len(managed)-1].Target) {
+			managed = managed[:len(managed)-1]
+		}
+
+		if len(managed) > 0 {
+			parent := managed[len(managed)-1]
+			issues = append(issues, linkIssue(link, false, i18n.MsgNestedTarget, link.Target, parent.Target, formatPosition(parent.File, parent.Line, parent.Column)))
+		} else if resolved := resolvesInto(files, filepath.Dir(link.Target), dotfilesDir); resolved != "" {
+			issues = append(issues, linkIssue(link, false, i18n.MsgResolvesIntoDotfiles, link.Target, filepath.Join(resolved, filepath.Base(link.Target))))
+		}
+
+		managed = append(managed, link)
+	}
+
+	return issues
+}
+
+// resolvesInto returns where dir resolves to when symbolic links take it into
+// dotfilesDir, or "" otherwise. Paths that are inside dotfilesDir as written
+// are not reported, the validation already does.
+func resolvesInto(files fsys.FS, dir, dotfilesDir string) string {
+	if dotfilesDir == "" || isWithin(dir, dotfilesDir) {
+		return ""
+	}
+
+	resolvedDotfiles, err := fsys.EvalSymlinks(files, dotfilesDir)
+	if err != nil {
+		return ""
+	}
+
+	// Missing directories will be created, so resolve the closest existing one
+	existing, rest := dir, ""
+	for {
+		resolved, err := fsys.EvalSymlinks(files, existing)
+		if err == nil {
+			if !isWithin(resolved, resolvedDotfiles) {
+				return ""
+			}
+			return filepath.Join(resolved, rest)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return ""
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// orderEntries groups the entries in stages so every entry comes after the
+// entries named in its "after" list. Names that no entry defines are ignored,
+// since their entry may belong to another profile.
+func orderEntries(symlinkConfigs []SymlinkConfig) ([][]SymlinkConfig, error) {
+	named := make(map[string]int)
+	for i, entry := range symlinkConfigs {
+		if entry.Name == "" {
+			continue
+		}
+		if previous, exists := named[entry.Name]; exists {
+			return nil, entryIssue(entry, i18n.T(i18n.MsgDuplicateEntryName, entry.Name, entryPosition(symlinkConfigs[previous])))
+		}
+		named[entry.Name] = i
+	}
+
+	done := make([]bool, len(symlinkConfigs))
+	remaining := len(symlinkConfigs)
+	var stages [][]SymlinkConfig
+
+	for remaining > 0 {
+		var ready []int
+		for i, entry := range symlinkConfigs {
+			if done[i] {
+				continue
+			}
+			blocked := false
+			for _, name := range entry.After {
+				if dep, exists := named[name]; exists && !done[dep] {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, i)
+			}
+		}
+
+		if len(ready) == 0 {
+			return nil, dependencyCycle(symlinkConfigs, named, done)
+		}
+
+		stage := make([]SymlinkConfig, 0, len(ready))
+		for _, i := range ready {
+			done[i] = true
+			stage = append(stage, symlinkConfigs[i])
+		}
+		stages = append(stages, stage)
+		remaining -= len(ready)
+	}
+
+	return stages, nil
+}
+
+// dependencyCycle describes a cycle among the entries that could not be ordered
+func dependencyCycle(symlinkConfigs []SymlinkConfig, named map[string]int, done []bool) error {
+	// Every blocked entry waits on another blocked entry, so following the
+	// first blocking dependency must eventually revisit an entry
+	start := 0
+	for done[start] {
+		start++
+	}
+
+	var path []string
+	seen := make(map[int]int)
+	for current := start; ; {
+		if at, ok := seen[current]; ok {
+			cycle := append(path[at:], path[at])
+			return entryIssue(symlinkConfigs[current], i18n.T(i18n.MsgDependencyCycle, strings.Join(cycle, " -> ")))
+		}
+		seen[current] = len(path)
+		path = append(path, entryName(symlinkConfigs[current]))
+
+		for _, name := range symlinkConfigs[current].After {
+			if dep, exists := named[name]; exists && !done[dep] {
+				current = dep
+				break
+			}
+		}
+	}
+}
+
+// entryName returns the name of an entry, or its position when it has none
+func entryName(entry SymlinkConfig) string {
+	if entry.Name != "" {
+		return entry.Name
+	}
+	return entryPosition(entry)
+}
+
+// entryPosition returns file:line:column of an entry
+func entryPosition(entry SymlinkConfig) string {
+	if entry.File == "" {
+		return fmt.Sprintf("line %d", entry.Line)
+	}
+	return formatPosition(entry.File, entry.Line, entry.Column)
+}
+
+// entryIssue returns an error located at the entry
+func entryIssue(entry SymlinkConfig, message string) Issue {
+	return Issue{File: entry.File, Line: entry.Line, Column: entry.Column, Message: message}
+}
+
+// compareTargets orders paths component by component, so a directory sorts
+// right before its contents
+func compareTargets(a, b string) int {
+	partsA := strings.Split(filepath.Clean(a), string(filepath.Separator))
+	partsB := strings.Split(filepath.Clean(b), string(filepath.Separator))
+
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := strings.Compare(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+	return len(partsA) - len(partsB)
+}
@@ -0,0 +1,61 @@
+// Package sokru
+// Description: Unit tests for how the planner finds targets that resolve into the dotfiles directory.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+func TestResolvesInto(t *testing.T) {
+	mem := fsys.NewMemFS()
+	must(t, mem.MkdirAll("/dotfiles/nvim", 0755))
+	must(t, mem.MkdirAll("/home/user/.config", 0755))
+	must(t, mem.Symlink("/dotfiles/nvim", "/home/user/.config/nvim"))
+	must(t, mem.Symlink("../../dotfiles", "/home/user/dots"))
+	must(t, mem.Symlink("/srv", "/home/user/srv"))
+	// The dotfiles directory itself may be reached through a symlink
+	must(t, mem.Symlink("/dotfiles", "/home/user/dotfiles"))
+
+	tests := []struct {
+		name        string
+		dir         string
+		dotfilesDir string
+		want        string
+	}{
+		{name: "plain directory", dir: "/home/user/.config", dotfilesDir: "/dotfiles"},
+		{name: "linked directory", dir: "/home/user/.config/nvim", dotfilesDir: "/dotfiles", want: "/dotfiles/nvim"},
+		{name: "missing directory below a link", dir: "/home/user/.config/nvim/lua/plugins", dotfilesDir: "/dotfiles", want: "/dotfiles/nvim/lua/plugins"},
+		{name: "relative link", dir: "/home/user/dots/fish", dotfilesDir: "/dotfiles", want: "/dotfiles/fish"},
+		{name: "link elsewhere", dir: "/home/user/srv/app", dotfilesDir: "/dotfiles"},
+		{name: "dotfiles through a link", dir: "/home/user/.config/nvim", dotfilesDir: "/home/user/dotfiles", want: "/dotfiles/nvim"},
+		{name: "inside the dotfiles as written", dir: "/dotfiles/nvim", dotfilesDir: "/dotfiles"},
+		{name: "missing dotfiles", dir: "/home/user/.config/nvim", dotfilesDir: "/missing"},
+		{name: "no dotfiles", dir: "/home/user/.config/nvim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolvesInto(mem, tt.dir, tt.dotfilesDir); got != tt.want {
+				t.Errorf("resolvesInto(%s, %s) = %q, want %q", tt.dir, tt.dotfilesDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvesIntoUnreadableLink(t *testing.T) {
+	// A link that cannot be read resolves nowhere
+	mem := fsys.NewMemFS()
+	must(t, mem.MkdirAll("/dotfiles/nvim", 0755))
+	must(t, mem.MkdirAll("/home/user", 0755))
+	must(t, mem.Symlink("/dotfiles/nvim", "/home/user/nvim"))
+	faulty := fsys.NewFaulty(mem, fsys.Fault{Op: fsys.OpReadlink, Path: "/home/user/nvim", Err: syscall.EACCES})
+
+	if got := resolvesInto(faulty, "/home/user/nvim", "/dotfiles"); got != "" {
+		t.Errorf("resolvesInto() = %q, want nothing", got)
+	}
+}
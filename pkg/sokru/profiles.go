@@ -0,0 +1,116 @@
+// Package sokru
+// Description: This file contains the profiles of symlinks.yaml and the selection of entries by profile.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProfileSpec defines a profile in the "profiles" section of symlinks.yaml
+type ProfileSpec struct {
+	Description string   `yaml:"description,omitempty"`
+	Extends     []string `yaml:"extends,omitempty"`
+}
+
+// CollectProfiles returns the profiles defined in the "profiles" sections of
+// the entries. Profiles that entries are tagged with but that are not defined
+// are included without parents.
+func CollectProfiles(configs []SymlinkConfig) (map[string]ProfileSpec, error) {
+	profiles := make(map[string]ProfileSpec)
+
+	for _, entry := range configs {
+		for name, spec := range entry.Profiles {
+			if _, exists := profiles[name]; exists {
+				return nil, fmt.Errorf("profile %q is defined more than once", name)
+			}
+			profiles[name] = spec
+		}
+	}
+
+	for _, entry := range configs {
+		if entry.Profile == "" {
+			continue
+		}
+		if _, exists := profiles[entry.Profile]; !exists {
+			profiles[entry.Profile] = ProfileSpec{}
+		}
+	}
+
+	return profiles, nil
+}
+
+// ProfileChain returns name followed by every profile it extends, directly or
+// indirectly, in the order they are inherited
+func ProfileChain(profiles map[string]ProfileSpec, name string) ([]string, error) {
+	var chain []string
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if visiting[name] {
+			return fmt.Errorf("profile inheritance cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		if visited[name] {
+			return nil
+		}
+
+		spec, exists := profiles[name]
+		if !exists {
+			if len(path) == 0 {
+				return fmt.Errorf("unknown profile %q", name)
+			}
+			return fmt.Errorf("profile %q extends unknown profile %q", path[len(path)-1], name)
+		}
+
+		visiting[name] = true
+		chain = append(chain, name)
+		for _, parent := range spec.Extends {
+			if err := visit(parent, append(path, name)); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+
+		return nil
+	}
+
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// SelectProfile returns the entries that apply to the profile: untagged
+// entries and entries tagged with the profile or one it extends. Without a
+// profile only untagged entries apply.
+func SelectProfile(configs []SymlinkConfig, profile string) ([]SymlinkConfig, error) {
+	included := make(map[string]bool)
+
+	if profile != "" {
+		profiles, err := CollectProfiles(configs)
+		if err != nil {
+			return nil, err
+		}
+		chain, err := ProfileChain(profiles, profile)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range chain {
+			included[name] = true
+		}
+	}
+
+	var selected []SymlinkConfig
+	for _, entry := range configs {
+		if entry.Profile == "" || included[entry.Profile] {
+			selected = append(selected, entry)
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,133 @@
+// Package sokru
+// Description: This file contains Pull, which copies local edits of copied targets back into the dotfiles.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"errors"
+
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// ErrRenderedTarget is the Err of skipped changes whose target is the output
+// of a template, which would be overwritten on the next render
+var ErrRenderedTarget = errors.New("target is rendered from a template")
+
+// PullOptions controls Pull
+type PullOptions struct {
+	// Targets limits the pull to these targets, every drifted target is
+	// pulled when empty
+	Targets []string
+	// Confirm is asked before each target is pulled, nil pulls them all
+	Confirm func(link Link) bool
+	// Observer receives the events of the pull, nil for none
+	Observer Observer
+	// FS is the filesystem targets and sources are copied on, the real one
+	// when nil
+	FS fsys.FS
+	// Lock is the lock of cfg when the caller holds it, see AcquireLock
+	Lock *Lock
+}
+
+// Pull copies targets in copy or hardlink mode that were edited locally back
+// into their sources. Targets that cannot be pulled are skipped with their
+// Err set, declined ones are skipped without. With cfg.DryRun nothing is
+// changed.
+func Pull(cfg *Config, opts PullOptions) (result *Result, err error) {
+	notify := newNotifier(cfg, opts.Observer)
+	defer func() { notify.fail(err) }()
+	files := fsys.Or(opts.FS)
+
+	release, err := lockFiles(cfg, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	symlinkFile, err := symlinksFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result = &Result{SymlinksFile: symlinkFile, DryRun: cfg.DryRun, notify: notify}
+
+	symlinkConfigs, plan, err := loadPlan(cfg, symlinkFile, files)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = len(symlinkConfigs)
+	notify.emit(Event{Type: EventLoaded, Path: symlinkFile, Count: result.Entries})
+	st, err := loadState(cfg)
+	if err != nil {
+		return result, err
+	}
+
+	// Only the requested targets are pulled when any are given
+	wanted := make(map[string]bool)
+	for _, target := range opts.Targets {
+		wanted[expandPath(target)] = true
+	}
+
+	for _, link := range plan {
+		if link.Mode == ModeSymlink || (len(wanted) > 0 && !wanted[link.Target]) {
+			continue
+		}
+
+		status, err := checkMaterialized(files, link, st, nil)
+		if err != nil {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Err: linkError(OpCheck, link, err)})
+			continue
+		}
+		if status != StatusDrifted {
+			continue
+		}
+
+		// Rendered output would be overwritten on the next render
+		if link.Kind == KindTemplate {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Status: status, Err: ErrRenderedTarget})
+			continue
+		}
+
+		if cfg.DryRun {
+			result.add(Change{Kind: ChangePulled, Link: link, Status: status})
+			continue
+		}
+
+		if opts.Confirm != nil && !opts.Confirm(link) {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Status: status})
+			continue
+		}
+
+		hash, err := pullTarget(files, link)
+		if err != nil {
+			result.add(Change{Kind: ChangeSkipped, Link: link, Status: status, Err: linkError(OpPull, link, err)})
+			continue
+		}
+		st.Set(link.Target, state.FileState{Source: link.Source, Mode: link.Mode, Hash: hash})
+		result.add(Change{Kind: ChangePulled, Link: link, Status: status})
+	}
+
+	if !cfg.DryRun && result.Count(ChangePulled) > 0 {
+		if err := st.Save(); err != nil {
+			return result, &Error{Op: OpSaveState, Err: err}
+		}
+	}
+	return result, nil
+}
+
+// pullTarget copies the target of link back into its source. A hard link is
+// restored afterwards so both share the same file again.
+func pullTarget(files fsys.FS, link Link) (string, error) {
+	if err := copyFile(files, link.Target, link.Source); err != nil {
+		return "", err
+	}
+
+	if link.Mode == ModeHardlink {
+		// The target now matches the source, so there is nothing to roll back
+		return materialize(files, link, rollback.NewTrackerFS(files))
+	}
+
+	return hashFile(files, link.Target)
+}
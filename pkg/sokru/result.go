@@ -0,0 +1,144 @@
+// Package sokru
+// Description: This file contains the typed results of the operations: the status of each target and the changes made to it.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+// Status describes a target compared to its link
+type Status string
+
+// Statuses of a target
+const (
+	// StatusMissing targets do not exist
+	StatusMissing Status = "missing"
+	// StatusInstalled targets link to, or match, their source
+	StatusInstalled Status = "installed"
+	// StatusWrongTarget symlinks point somewhere else
+	StatusWrongTarget Status = "wrong_target"
+	// StatusOutdated copies were written from an older version of the source
+	StatusOutdated Status = "outdated"
+	// StatusDrifted copies were edited locally since sok wrote them
+	StatusDrifted Status = "drifted"
+	// StatusBlocked targets are files sok does not manage: a regular file
+	// where a symlink goes, or a copy sok did not write
+	StatusBlocked Status = "blocked"
+)
+
+// LinkStatus is the status of the target of a link
+type LinkStatus struct {
+	Link   Link
+	Status Status
+	// Current is where a symlink with the wrong target points
+	Current string
+	// Err is set when the target could not be checked, Status is then empty
+	Err error
+}
+
+// StatusReport is the status of every link of the symlinks file
+type StatusReport struct {
+	// SymlinksFile is the symlinks file the links come from
+	SymlinksFile string
+	// Links are in the order they are processed
+	Links []LinkStatus
+	// Issues are warnings about targets written through another link
+	Issues []Issue
+}
+
+// Count returns how many links have status
+func (r *StatusReport) Count(status Status) int {
+	count := 0
+	for _, link := range r.Links {
+		if link.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// ChangeKind says what happened to a target
+type ChangeKind string
+
+// Kinds of changes
+const (
+	// ChangeCreated targets did not exist and were linked or copied
+	ChangeCreated ChangeKind = "created"
+	// ChangeUpdated targets were relinked, copied again or re-rendered
+	ChangeUpdated ChangeKind = "updated"
+	// ChangeUnchanged targets were already installed
+	ChangeUnchanged ChangeKind = "unchanged"
+	// ChangeRendered templates had their output written
+	ChangeRendered ChangeKind = "rendered"
+	// ChangeBackedUp targets were saved in the backup before being replaced
+	ChangeBackedUp ChangeKind = "backed_up"
+	// ChangeRemoved targets were uninstalled
+	ChangeRemoved ChangeKind = "removed"
+	// ChangePulled targets were copied back into their source
+	ChangePulled ChangeKind = "pulled"
+	// ChangeSkipped targets were left alone, see Status and Err for why
+	ChangeSkipped ChangeKind = "skipped"
+)
+
+// Change is what happened to one target, or what would happen on a dry run
+type Change struct {
+	Kind ChangeKind
+	Link Link
+	// Status is the status of the target before the change
+	Status Status
+	// Previous is where an updated or wrong symlink pointed
+	Previous string
+	// Diff is the change rendering a template makes to its target
+	Diff string
+	// Err is why a target was skipped, or why its backup failed
+	Err error
+
+	// content is the rendered output of a template, written by Apply
+	content []byte
+}
+
+// Result is the outcome of an operation
+type Result struct {
+	// SymlinksFile is the symlinks file the links come from
+	SymlinksFile string
+	// Entries is the number of entries in the symlinks file and its includes
+	Entries int
+	// DryRun results describe the changes without having made them
+	DryRun bool
+	// Changes are in the order they were made
+	Changes []Change
+	// Issues are the warnings found validating the symlinks file
+	Issues []Issue
+
+	// Backup is the backup of the replaced targets, nil when none was made
+	Backup *BackupMetadata
+	// BackupErr is set when the backup could not be saved
+	BackupErr error
+	// Pruned are the old backups deleted to keep backup.max_backups
+	Pruned []string
+	// PruneErr is set when old backups could not be deleted
+	PruneErr error
+
+	// RolledBack is the number of changes undone after a failure
+	RolledBack int
+	// RollbackErr is set when undoing the changes failed
+	RollbackErr error
+
+	// notify sends every change added to the observer of the operation
+	notify *notifier
+}
+
+// Count returns how many changes are of kind
+func (r *Result) Count(kind ChangeKind) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// add records a change and sends it to the observer
+func (r *Result) add(change Change) {
+	r.Changes = append(r.Changes, change)
+	r.notify.emit(Event{Type: EventChange, Change: &change})
+}
@@ -0,0 +1,38 @@
+// Package sokru
+// Description: This file contains the JSON Schema of symlinks.yaml and config.yaml.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"reflect"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/schema"
+)
+
+// SymlinksSchema returns the JSON Schema of the symlinks files: a list of
+// entries, or a mapping holding them in an entries key, which TOML documents
+// need since they cannot be lists
+func SymlinksSchema() *Schema {
+	entries := schema.For(reflect.TypeOf([]SymlinkConfig{}))
+	return &Schema{
+		Schema:      schema.Draft,
+		Title:       "sokru symlinks file",
+		Description: "Entries describing the symlinks managed by sok",
+		OneOf: []*Schema{
+			entries,
+			{
+				Type:                 "object",
+				Properties:           map[string]*Schema{"entries": entries},
+				Required:             []string{"entries"},
+				AdditionalProperties: false,
+			},
+		},
+	}
+}
+
+// ConfigSchema returns the JSON Schema of config.yaml
+func ConfigSchema() *Schema {
+	return schema.Generate(reflect.TypeOf(config.Config{}), "sokru configuration", "Configuration of sok")
+}
@@ -0,0 +1,215 @@
+// Package sokru
+// Description: This file is the entry point of the public API of sok: loading the configuration, the typed errors and the helpers the operations share.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+// Package sokru manages dotfiles like the sok command does, for programs that
+// embed it. Load a configuration with LoadConfig, then plan, install, apply,
+// check the status of and uninstall the links of its symlinks file, and list,
+// restore and delete the backups made along the way.
+//
+// Operations report what they did to each target in a Result and fail with
+// an *Error. They take the lock sok processes share, so they are safe to run
+// next to the sok command.
+package sokru
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alexlm78/sokru/internal/backup"
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/paths"
+	"github.com/alexlm78/sokru/internal/safefile"
+	"github.com/alexlm78/sokru/internal/schema"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// Config is the configuration of sok, see LoadConfig
+type Config = config.Config
+
+// ConfigOptions are the layers of the configuration given by the caller: a
+// config file and values by key, like the --config file and flags of sok
+type ConfigOptions = config.Options
+
+// Origins maps each configuration key to where its value came from
+type Origins = config.Origins
+
+// BackupMetadata describes a backup and the files in it
+type BackupMetadata = backup.BackupMetadata
+
+// BackupEntry is a file or symlink saved in a backup
+type BackupEntry = backup.BackupEntry
+
+// Schema is a JSON Schema, see SymlinksSchema and ConfigSchema
+type Schema = schema.Schema
+
+// LoadConfig resolves the configuration from the defaults, the system and
+// user config files, the SOKRU_* environment variables and opts, and returns
+// where each value came from
+func LoadConfig(opts ConfigOptions) (*Config, Origins, error) {
+	return config.Resolve(opts)
+}
+
+// DefaultConfig returns the configuration used when nothing sets a key
+func DefaultConfig() *Config {
+	return config.GetDefaultConfig()
+}
+
+// ErrSymlinksFileNotFound is returned, inside an *Error, when the configured
+// symlinks file does not exist
+var ErrSymlinksFileNotFound = errors.New("symlinks file not found")
+
+// Op names the step of an operation that failed
+type Op string
+
+// Steps of the operations
+const (
+	OpLoad       Op = "load"        // Reading the symlinks file
+	OpValidate   Op = "validate"    // Validating the symlinks file, Err holds the Issues
+	OpResolve    Op = "resolve"     // Resolving the links of the entries
+	OpOverlap    Op = "overlap"     // Targets written through another link, Err holds the Issues
+	OpState      Op = "state"       // Loading the state of copied targets
+	OpSaveState  Op = "save-state"  // Saving the state of copied targets
+	OpBackupDir  Op = "backup-dir"  // Locating the backups
+	OpBackup     Op = "backup"      // Loading, restoring or deleting a backup
+	OpLock       Op = "lock"        // Taking the lock of sok's files
+	OpRender     Op = "render"      // Rendering a template
+	OpCheck      Op = "check"       // Checking a target
+	OpReadLink   Op = "readlink"    // Reading a symlink at a target
+	OpCopy       Op = "copy"        // Copying or hard linking a source to a target
+	OpRemove     Op = "remove"      // Removing a target
+	OpLink       Op = "link"        // Creating a symlink
+	OpPull       Op = "pull"        // Copying a target back into its source
+	OpSaveBackup Op = "save-backup" // Saving the metadata of a backup
+	OpPrune      Op = "prune"       // Deleting old backups
+)
+
+// Error is the failure of a step of an operation
+type Error struct {
+	Op Op
+	// Path is the file the step failed on, when there is one
+	Path string
+	// Link is the link the step failed on, when there is one
+	Link *Link
+	Err  error
+}
+
+// Error formats the step, the path and the cause
+func (e *Error) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// linkError returns the failure of a step on link
+func linkError(op Op, link Link, err error) *Error {
+	return &Error{Op: op, Path: link.Target, Link: &link, Err: err}
+}
+
+// symlinksFile returns the expanded path of the configured symlinks file,
+// which must exist
+func symlinksFile(cfg *Config) (string, error) {
+	symlinkFile := expandPath(cfg.SymlinksFile)
+	if _, err := os.Stat(symlinkFile); os.IsNotExist(err) {
+		return "", &Error{Op: OpLoad, Path: symlinkFile, Err: ErrSymlinksFileNotFound}
+	}
+	return symlinkFile, nil
+}
+
+// loadPlan loads the configured symlinks file, for the active profile, and
+// resolves its links in the order they are processed, with the sources on
+// files
+func loadPlan(cfg *Config, symlinkFile string, files fsys.FS) ([]SymlinkConfig, []Link, error) {
+	symlinkConfigs, err := LoadSymlinks(cfg, symlinkFile)
+	if err != nil {
+		return nil, nil, &Error{Op: OpLoad, Path: symlinkFile, Err: err}
+	}
+
+	opts := NewResolveOptions(cfg, symlinkFile)
+	opts.FS = files
+	plan, err := PlanLinks(symlinkConfigs, opts)
+	if err != nil {
+		return nil, nil, &Error{Op: OpResolve, Path: symlinkFile, Err: err}
+	}
+	return symlinkConfigs, plan, nil
+}
+
+// Lock is the lock sok processes share while they change the links, state
+// or backups of a configuration, see AcquireLock
+type Lock = safefile.Lock
+
+// AcquireLock takes the lock of cfg, waiting while another process holds it.
+// waiting, when not nil, is called with the path of the lock before it waits.
+// Operations given the lock in their options run under it, so several of
+// them, like NewPlan and Apply, see no other process in between; without
+// one they take the lock themselves.
+func AcquireLock(cfg *Config, waiting func(path string)) (*Lock, error) {
+	lockPath, err := cfg.LockFile()
+	if err != nil {
+		return nil, &Error{Op: OpLock, Err: err}
+	}
+
+	lock, err := safefile.TryLock(lockPath)
+	if errors.Is(err, safefile.ErrLocked) {
+		if waiting != nil {
+			waiting(lockPath)
+		}
+		lock, err = safefile.Acquire(lockPath)
+	}
+	if err != nil {
+		return nil, &Error{Op: OpLock, Path: lockPath, Err: err}
+	}
+	return lock, nil
+}
+
+// lockFiles takes the lock sok processes share while an operation changes
+// the links, state or backups of cfg, unless the caller already holds it.
+// release gives back only a lock taken here.
+func lockFiles(cfg *Config, held *Lock) (release func(), err error) {
+	if held != nil {
+		return func() {}, nil
+	}
+	lock, err := AcquireLock(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Release() }, nil
+}
+
+// loadState loads the state of the copied and hard linked targets of cfg
+func loadState(cfg *Config) (*state.State, error) {
+	stateDir, _, err := cfg.Dirs()
+	if err != nil {
+		return nil, &Error{Op: OpState, Err: err}
+	}
+	statePath := state.StatePath(cfg.Home, stateDir)
+
+	st, err := state.Load(statePath)
+	if err != nil {
+		return nil, &Error{Op: OpState, Path: statePath, Err: err}
+	}
+	return st, nil
+}
+
+// expandPath expands ~, environment variables and XDG base directories in
+// path. Paths that cannot be expanded are returned unchanged.
+func expandPath(path string) string {
+	expanded, err := paths.Expand(path)
+	if err != nil {
+		return path
+	}
+	return expanded
+}
+
+// expandPathStrict is like expandPath but reports undefined variables
+func expandPathStrict(path string) (string, error) {
+	return paths.Expand(path)
+}
@@ -0,0 +1,84 @@
+// Package sokru
+// Description: This file contains CheckStatus, which compares the target of every link of the symlinks file with its source.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"os"
+
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// StatusOptions controls CheckStatus
+type StatusOptions struct {
+	// FS is the filesystem targets are checked on, the real one when nil
+	FS fsys.FS
+}
+
+// CheckStatus returns the status of the target of every link of the symlinks file
+// of cfg. Targets that cannot be checked have their Err set.
+func CheckStatus(cfg *Config, opts StatusOptions) (*StatusReport, error) {
+	symlinkFile, err := symlinksFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	report := &StatusReport{SymlinksFile: symlinkFile}
+	files := fsys.Or(opts.FS)
+
+	_, plan, err := loadPlan(cfg, symlinkFile, files)
+	if err != nil {
+		return report, err
+	}
+	st, err := loadState(cfg)
+	if err != nil {
+		return report, err
+	}
+
+	// Nested targets are installed through another link
+	for _, issue := range CheckOverlaps(files, plan, expandPath(cfg.DotfilesDir)) {
+		issue.Warning = true
+		report.Issues = append(report.Issues, issue)
+	}
+
+	for _, link := range plan {
+		status, current, err := targetStatus(files, link, st)
+		report.Links = append(report.Links, LinkStatus{Link: link, Status: status, Current: current, Err: err})
+	}
+	return report, nil
+}
+
+// targetStatus compares the target of link with its source. Copied and hard
+// linked targets are compared by content, symlinks by where they point.
+func targetStatus(files fsys.FS, link Link, st *state.State) (Status, string, error) {
+	if link.Mode != ModeSymlink {
+		status, err := checkMaterialized(files, link, st, nil)
+		if err != nil {
+			return "", "", linkError(OpCheck, link, err)
+		}
+		return status, "", nil
+	}
+
+	info, err := files.Lstat(link.Target)
+	if os.IsNotExist(err) {
+		return StatusMissing, "", nil
+	}
+	if err != nil {
+		return "", "", linkError(OpCheck, link, err)
+	}
+
+	// A regular file is in the way
+	if info.Mode()&os.ModeSymlink == 0 {
+		return StatusBlocked, "", nil
+	}
+
+	current, err := files.Readlink(link.Target)
+	if err != nil {
+		return "", "", linkError(OpReadLink, link, err)
+	}
+	if current != link.Source {
+		return StatusWrongTarget, current, nil
+	}
+	return StatusInstalled, "", nil
+}
@@ -0,0 +1,196 @@
+// Package sokru
+// Description: This file contains the entries of symlinks.yaml and the selection of their links for a machine.
+// (c) 2023 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/platform"
+	"gopkg.in/yaml.v3"
+)
+
+type SymlinkConfig struct {
+	OS string `yaml:"os,omitempty" schema:"enum=linux|darwin|windows"`
+	// Arch, Distro, WSL and Desktop restrict the entry like OS does
+	Arch    string `yaml:"arch,omitempty"`
+	Distro  string `yaml:"distro,omitempty"`
+	WSL     *bool  `yaml:"wsl,omitempty"`
+	Desktop string `yaml:"desktop,omitempty"`
+	// When is an expression the entry is only applied for when true
+	When    string            `yaml:"when,omitempty"`
+	Link    map[string]string `yaml:"link"`
+	Common  map[string]string `yaml:"common,omitempty"`
+	Linux   map[string]string `yaml:"linux,omitempty"`
+	Darwin  map[string]string `yaml:"darwin,omitempty"`
+	Windows map[string]string `yaml:"windows,omitempty"`
+	// Profile restricts the entry to a profile and the profiles extending it
+	Profile string `yaml:"profile,omitempty"`
+	// Profiles defines the profiles and their inheritance
+	Profiles map[string]ProfileSpec `yaml:"profiles,omitempty"`
+	// Hosts holds sections keyed by hostname or hostname glob
+	Hosts map[string]map[string]string `yaml:"hosts,omitempty"`
+	Links []LinkSpec                   `yaml:"links,omitempty"`
+	// Include names files, or glob patterns, whose entries replace this item
+	Include StringList `yaml:"include,omitempty"`
+
+	// Name identifies the entry for the After lists of other entries
+	Name string `yaml:"name,omitempty"`
+	// After lists the entries whose links are processed before this one
+	After StringList `yaml:"after,omitempty"`
+
+	// File, Line and Column locate the entry in the symlinks files
+	File   string `yaml:"-"`
+	Line   int    `yaml:"-"`
+	Column int    `yaml:"-"`
+	// targetNodes holds the key node of each target of the map sections
+	targetNodes map[sectionTarget]*yaml.Node
+}
+
+// sectionTarget is a target of a map section: "common", "linux", "darwin",
+// "windows", "link", or "hosts." followed by the hostname pattern
+type sectionTarget struct {
+	section string
+	target  string
+}
+
+// linkSection is a map section of an entry and its links
+type linkSection struct {
+	name  string
+	links map[string]string
+}
+
+// GetLinksForOS is exported for testing purposes
+func (sc *SymlinkConfig) GetLinksForOS(currentOS string) map[string]string {
+	return sc.GetLinks(platform.Info{OS: currentOS})
+}
+
+// GetLinks returns the links of the entry for the given machine. Sections are
+// layered from lowest to highest priority: common, the OS section, hostname
+// globs (less specific first), the exact hostname and the legacy "link" field.
+func (sc *SymlinkConfig) GetLinks(p platform.Info) map[string]string {
+	links := make(map[string]string)
+	for target, link := range sc.sectionLinks(p) {
+		links[target] = link.source
+	}
+	return links
+}
+
+// sectionLink is the source of a target and the section it comes from
+type sectionLink struct {
+	source  string
+	section string
+}
+
+// sectionLinks returns the links of GetLinks with the section each target
+// comes from, so issues point at the line that set it
+func (sc *SymlinkConfig) sectionLinks(p platform.Info) map[string]sectionLink {
+	links := make(map[string]sectionLink)
+	for _, section := range sc.sections(p) {
+		for target, source := range section.links {
+			links[target] = sectionLink{source: source, section: section.name}
+		}
+	}
+	return links
+}
+
+// sections returns the map sections of the entry for the given machine, from
+// lowest to highest priority
+func (sc *SymlinkConfig) sections(p platform.Info) []linkSection {
+	// If a selector is specified and doesn't match, skip this entry
+	if !sc.Matches(p) {
+		return nil
+	}
+
+	// If this is a legacy format (only "link" field), return it
+	if len(sc.Link) > 0 && sc.OS == "" && len(sc.Hosts) == 0 {
+		return []linkSection{{name: "link", links: sc.Link}}
+	}
+
+	// Common links first (lowest priority)
+	sections := []linkSection{{name: "common", links: sc.Common}}
+
+	// OS-specific links (higher priority, can override common)
+	switch p.OS {
+	case "linux":
+		sections = append(sections, linkSection{name: "linux", links: sc.Linux})
+	case "darwin":
+		sections = append(sections, linkSection{name: "darwin", links: sc.Darwin})
+	case "windows":
+		sections = append(sections, linkSection{name: "windows", links: sc.Windows})
+	}
+
+	// Host-specific links (higher priority, can override OS)
+	for _, pattern := range sc.matchingHosts(p.Hostname) {
+		sections = append(sections, linkSection{name: "hosts." + pattern, links: sc.Hosts[pattern]})
+	}
+
+	// Legacy "link" field has highest priority
+	return append(sections, linkSection{name: "link", links: sc.Link})
+}
+
+// Matches reports whether the selectors of the entry (os, arch, distro, wsl
+// and desktop) all match the machine. An entry without selectors matches
+// every machine.
+func (sc *SymlinkConfig) Matches(p platform.Info) bool {
+	if sc.OS != "" && sc.OS != p.OS {
+		return false
+	}
+	if sc.Arch != "" && !p.MatchArch(sc.Arch) {
+		return false
+	}
+	if sc.Distro != "" && !p.MatchDistro(sc.Distro) {
+		return false
+	}
+	if sc.WSL != nil && *sc.WSL != p.WSL {
+		return false
+	}
+	if sc.Desktop != "" && !p.MatchDesktop(sc.Desktop) {
+		return false
+	}
+	return true
+}
+
+// matchingHosts returns the host sections matching hostname in the order
+// they are applied: globs from least to most specific, then the exact name
+func (sc *SymlinkConfig) matchingHosts(hostname string) []string {
+	if hostname == "" {
+		return nil
+	}
+
+	var globs []string
+	var exact string
+	for pattern := range sc.Hosts {
+		matched, _ := platform.MatchHost(pattern, hostname)
+		switch {
+		case !matched:
+			continue
+		case platform.IsHostPattern(pattern):
+			globs = append(globs, pattern)
+		case len(pattern) > len(exact):
+			// The fully qualified name is preferred over the short one
+			exact = pattern
+		}
+	}
+
+	// A pattern with more literal characters is more specific
+	sort.Slice(globs, func(i, j int) bool {
+		li, lj := literalLength(globs[i]), literalLength(globs[j])
+		if li != lj {
+			return li < lj
+		}
+		return globs[i] < globs[j]
+	})
+
+	if exact != "" {
+		globs = append(globs, exact)
+	}
+	return globs
+}
+
+// literalLength counts the characters of a glob that are not wildcards
+func literalLength(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
@@ -0,0 +1,77 @@
+// Package sokru
+// Description: This file contains helpers to render templated dotfiles before they are linked.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/alexlm78/sokru/internal/render"
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// newTemplateData returns the template data for the configured machine
+func newTemplateData(cfg *Config) render.Data {
+	return render.NewData(cfg.OS, cfg.Variables)
+}
+
+// renderLink renders the template of a template link, read from files
+func renderLink(files fsys.FS, link Link, data render.Data) ([]byte, error) {
+	content, err := render.RenderFS(files, link.Template, data.WithVars(link.Vars))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", link.Template, err)
+	}
+	return content, nil
+}
+
+// renderedChanged reports whether content differs from the rendered output on disk
+func renderedChanged(files fsys.FS, link Link, content []byte) bool {
+	current, err := files.ReadFile(link.Source)
+	if err != nil {
+		return true
+	}
+	return !bytes.Equal(current, content)
+}
+
+// writeRendered writes the rendered output of a template link, tracking the
+// write so it can be rolled back
+func writeRendered(files fsys.FS, link Link, content []byte, tracker *rollback.Tracker) error {
+	if err := files.MkdirAll(filepath.Dir(link.Source), 0755); err != nil {
+		return fmt.Errorf("failed to create render directory: %w", err)
+	}
+
+	var perm fs.FileMode
+	previous, err := files.ReadFile(link.Source)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read rendered file: %w", err)
+	}
+	if existed {
+		info, err := files.Stat(link.Source)
+		if err != nil {
+			return fmt.Errorf("failed to read rendered file: %w", err)
+		}
+		perm = info.Mode()
+	}
+
+	if err := files.WriteFile(link.Source, content, 0644); err != nil {
+		return fmt.Errorf("failed to write rendered file: %w", err)
+	}
+	tracker.TrackWritten(link.Source, previous, perm, existed)
+
+	return nil
+}
+
+// templateDiff returns the changes rendering content would make to the
+// content of the target
+func templateDiff(files fsys.FS, link Link, content []byte) string {
+	// The target may not exist yet, in which case everything is new
+	current, _ := files.ReadFile(link.Target)
+	return render.Diff(current, content)
+}
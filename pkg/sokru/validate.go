@@ -0,0 +1,275 @@
+// Package sokru
+// Description: This file contains the validation of symlinks.yaml and the files it includes.
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package sokru
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/fsys"
+)
+
+// validatedOSes are the operating systems every configuration is checked for
+var validatedOSes = []string{"linux", "darwin", "windows"}
+
+// ValidateOptions controls what ValidateSymlinks checks against
+type ValidateOptions struct {
+	// Resolve describes the current machine. Missing sources are errors for
+	// its OS and warnings for the others.
+	Resolve ResolveOptions
+	// DotfilesDir is the dotfiles repository targets must stay out of
+	DotfilesDir string
+}
+
+// ValidateSymlinks checks the symlinks file at path and the files it
+// includes. It reports unknown keys, duplicate targets, missing sources,
+// targets inside the dotfiles directory or nested under other targets and
+// link cycles for every OS and profile. The error is only set when the files cannot be loaded.
+func ValidateSymlinks(path string, opts ValidateOptions) ([]Issue, error) {
+	symlinkConfigs, issues, err := loadSymlinkFiles(path)
+	if err != nil {
+		// Entries that cannot be loaded are reported like any other issue
+		var loadIssues Issues
+		if !errors.As(err, &loadIssues) {
+			return nil, err
+		}
+		issues = append(issues, loadIssues...)
+		sortIssues(issues)
+		return issues, nil
+	}
+
+	v := &validator{
+		opts:   opts,
+		issues: issues,
+		seen:   make(map[string]bool),
+	}
+
+	for _, entry := range symlinkConfigs {
+		v.checkEntryLinks(entry)
+	}
+
+	profiles := v.profiles(path, symlinkConfigs)
+
+	// The current OS goes first so its issues win over the same issue elsewhere
+	oses := []string{opts.Resolve.Platform.OS}
+	for _, osName := range validatedOSes {
+		if osName != opts.Resolve.Platform.OS {
+			oses = append(oses, osName)
+		}
+	}
+
+	for _, osName := range oses {
+		for _, profile := range profiles {
+			selected, err := SelectProfile(symlinkConfigs, profile)
+			if err != nil {
+				continue
+			}
+			v.checkLinks(selected, osName, profile)
+		}
+	}
+
+	sortIssues(v.issues)
+	return v.issues, nil
+}
+
+// sortIssues orders issues by file and position
+func sortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+}
+
+// validator collects the issues of a validation run
+type validator struct {
+	opts   ValidateOptions
+	issues []Issue
+	// seen holds the issues already reported, ignoring their context
+	seen map[string]bool
+}
+
+// report adds an issue unless the same one was already reported. context
+// describes the OS or profile it was found for and is not part of the
+// comparison.
+func (v *validator) report(issue Issue, context string) {
+	key := fmt.Sprintf("%s:%s", formatPosition(issue.File, issue.Line, issue.Column), issue.Message)
+	if v.seen[key] {
+		return
+	}
+	v.seen[key] = true
+
+	if context != "" {
+		issue.Message += fmt.Sprintf(" (%s)", context)
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// linkIssue returns an issue located at the definition of link
+func linkIssue(link Link, warning bool, key i18n.MessageKey, args ...any) Issue {
+	return Issue{File: link.File, Line: link.Line, Column: link.Column, Message: i18n.T(key, args...), Warning: warning}
+}
+
+// profiles returns the profiles to check: none, then every defined one
+func (v *validator) profiles(path string, symlinkConfigs []SymlinkConfig) []string {
+	defined, err := CollectProfiles(symlinkConfigs)
+	if err != nil {
+		v.report(Issue{File: path, Message: err.Error()}, "")
+		return []string{""}
+	}
+
+	profiles := []string{""}
+	for name := range defined {
+		if _, err := ProfileChain(defined, name); err != nil {
+			v.report(Issue{File: path, Message: err.Error()}, "")
+			continue
+		}
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles[1:])
+
+	return profiles
+}
+
+// checkEntryLinks reports structured links of an entry sharing a target
+func (v *validator) checkEntryLinks(entry SymlinkConfig) {
+	lines := make(map[string]int)
+	for _, spec := range entry.Links {
+		target, err := expandPathStrict(spec.Target)
+		if err != nil || target == "" {
+			continue
+		}
+		if line, ok := lines[target]; ok {
+			v.report(Issue{
+				File:    entry.File,
+				Line:    spec.Line,
+				Column:  spec.Column,
+				Message: i18n.T(i18n.MsgDuplicateTargetInEntry, target, line),
+			}, "")
+			continue
+		}
+		lines[target] = spec.Line
+	}
+}
+
+// checkLinks resolves the entries for an OS and reports problems with the
+// resulting links
+func (v *validator) checkLinks(symlinkConfigs []SymlinkConfig, osName, profile string) {
+	opts := v.opts.Resolve
+	opts.Platform.OS = osName
+	current := osName == v.opts.Resolve.Platform.OS
+
+	var context []string
+	if !current {
+		context = append(context, i18n.T(i18n.MsgIssueOnOS, osName))
+	}
+	if profile != "" {
+		context = append(context, i18n.T(i18n.MsgIssueProfile, profile))
+	}
+	where := strings.Join(context, ", ")
+
+	dotfilesDir := expandPath(v.opts.DotfilesDir)
+
+	// Entry names and "after" dependencies must allow an order
+	if _, err := orderEntries(symlinkConfigs); err != nil {
+		var issue Issue
+		if errors.As(err, &issue) {
+			v.report(issue, where)
+		}
+	}
+
+	links := make(map[string]Link)
+	for _, entry := range symlinkConfigs {
+		entryLinks, err := entry.ResolveLinks(opts)
+		if err != nil {
+			v.report(Issue{File: entry.File, Line: entry.Line, Column: entry.Column, Message: err.Error()}, where)
+			continue
+		}
+
+		for _, target := range sortedTargets(entryLinks) {
+			link := entryLinks[target]
+
+			if previous, ok := links[target]; ok {
+				v.report(linkIssue(link, false, i18n.MsgDuplicateTarget, target, formatPosition(previous.File, previous.Line, previous.Column)), where)
+			} else {
+				links[target] = link
+			}
+
+			source := link.Source
+			if link.Kind == KindTemplate {
+				source = link.Template
+			}
+			if _, err := fsys.Or(v.opts.Resolve.FS).Stat(source); os.IsNotExist(err) {
+				v.report(linkIssue(link, !current, i18n.MsgSourceNotFound, source), where)
+			}
+
+			if dotfilesDir != "" && isWithin(target, dotfilesDir) {
+				v.report(linkIssue(link, false, i18n.MsgTargetInDotfiles, target, dotfilesDir), where)
+			}
+		}
+	}
+
+	v.checkCycles(links, where)
+
+	plan := make([]Link, 0, len(links))
+	for _, target := range sortedTargets(links) {
+		plan = append(plan, links[target])
+	}
+	for _, issue := range CheckOverlaps(v.opts.Resolve.FS, plan, dotfilesDir) {
+		v.report(issue, where)
+	}
+}
+
+// checkCycles reports chains of links whose sources lead back to their targets
+func (v *validator) checkCycles(links map[string]Link, where string) {
+	for _, start := range sortedTargets(links) {
+		chain := []string{start}
+		visited := map[string]bool{start: true}
+
+		for current := links[start]; current.Kind != KindTemplate; {
+			next := filepath.Clean(current.Source)
+			if next == start {
+				v.report(linkIssue(links[start], false, i18n.MsgLinkCycle, strings.Join(append(chain, start), " -> ")), where)
+				break
+			}
+
+			nextLink, ok := links[next]
+			if !ok || visited[next] {
+				break
+			}
+			chain = append(chain, next)
+			visited[next] = true
+			current = nextLink
+		}
+	}
+}
+
+// sortedTargets returns the targets of links in order
+func sortedTargets(links map[string]Link) []string {
+	targets := make([]string, 0, len(links))
+	for target := range links {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -231,6 +231,43 @@ func TestListBackups(t *testing.T) {
 	}
 }
 
+func TestPruneBackups(t *testing.T) {
+	tests := []struct {
+		name   string
+		keep   int
+		pruned []string
+	}{
+		{"keep all", 0, nil},
+		{"fewer than the limit", 5, nil},
+		{"oldest first", 2, []string{"20240101-000000.000", "20240102-000000.000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := backup.NewManager(t.TempDir())
+			ids := []string{"20240103-000000.000", "20240101-000000.000", "20240104-000000.000", "20240102-000000.000"}
+			for _, id := range ids {
+				if err := manager.SaveMetadata(&backup.BackupMetadata{ID: id, Timestamp: time.Now()}); err != nil {
+					t.Fatalf("SaveMetadata() error = %v", err)
+				}
+			}
+
+			pruned, err := manager.Prune(tt.keep)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if fmt.Sprint(pruned) != fmt.Sprint(tt.pruned) {
+				t.Errorf("Prune() = %v, want %v", pruned, tt.pruned)
+			}
+
+			backups, _ := manager.ListBackups()
+			if len(backups) != len(ids)-len(tt.pruned) {
+				t.Errorf("%d backups left, want %d", len(backups), len(ids)-len(tt.pruned))
+			}
+		})
+	}
+}
+
 func TestListBackupsEmpty(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "sokru-backup-test-*")
 	if err != nil {
@@ -440,7 +477,7 @@ func TestGetDefaultBackupDir(t *testing.T) {
 		t.Error("GetDefaultBackupDir should not return empty string")
 	}
 
-	// Should contain .config/sokru/backups
+	// Should be under $XDG_DATA_HOME/sokru/backups
 	if !filepath.IsAbs(backupDir) {
 		t.Error("GetDefaultBackupDir should return absolute path")
 	}
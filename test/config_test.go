@@ -5,8 +5,15 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/alexlm78/sokru/internal/config"
@@ -163,7 +170,7 @@ func TestUpdateConfig(t *testing.T) {
 	}
 
 	// Update config
-	err = config.UpdateConfig(func(c *config.Config) {
+	err = config.UpdateConfig("", func(c *config.Config) {
 		c.Verbose = true
 		c.Language = "es"
 	})
@@ -186,37 +193,70 @@ func TestUpdateConfig(t *testing.T) {
 	}
 }
 
-func TestGetAndSetConfig(t *testing.T) {
-	// Create test config
-	testConfig := &config.Config{
-		DotfilesDir:  "/test/dotfiles",
-		SymlinksFile: "/test/symlinks.yml",
-		OS:           "darwin",
-		Language:     "en",
-		Verbose:      false,
-		DryRun:       true,
-	}
-
-	// Set config
-	config.SetConfig(testConfig)
-
-	// Get config
-	cfg, err := config.GetConfig()
-	if err != nil {
-		t.Fatalf("GetConfig failed: %v", err)
-	}
-
-	if cfg != testConfig {
-		t.Error("GetConfig should return the same config instance set by SetConfig")
-	}
-
-	if cfg.OS != "darwin" {
-		t.Errorf("Expected OS 'darwin', got '%s'", cfg.OS)
+func TestConfigContext(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	darwin := &config.Config{OS: "darwin", DryRun: true}
+	linux := &config.Config{OS: "linux"}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantOS  string
+		same    *config.Config
+		wantErr bool
+	}{
+		{name: "carries its config", ctx: config.WithConfig(context.Background(), darwin), wantOS: "darwin", same: darwin},
+		{name: "contexts keep their own config", ctx: config.WithConfig(context.Background(), linux), wantOS: "linux", same: linux},
+		{name: "inner context overrides", ctx: config.WithConfig(config.WithConfig(context.Background(), darwin), linux), wantOS: "linux", same: linux},
+		{name: "fails without one", ctx: context.Background(), wantErr: true},
+		{name: "nil context fails", ctx: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.FromContext(tt.ctx)
+			if tt.wantErr {
+				if !errors.Is(err, config.ErrNoConfig) {
+					t.Errorf("FromContext() = %v, %v, want ErrNoConfig", cfg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromContext() error = %v", err)
+			}
+			if cfg != tt.same {
+				t.Error("FromContext() should return the config given to WithConfig")
+			}
+			if cfg.OS != tt.wantOS {
+				t.Errorf("OS = %s, want %s", cfg.OS, tt.wantOS)
+			}
+		})
 	}
+}
 
-	if !cfg.DryRun {
-		t.Error("DryRun should be true")
-	}
+func TestConfigContextConcurrent(t *testing.T) {
+	// Operations with different configs run side by side without sharing them
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		cfg := &config.Config{DotfilesDir: "/dotfiles/" + strconv.Itoa(i)}
+		ctx := config.WithConfig(context.Background(), cfg)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				got, err := config.FromContext(ctx)
+				if err != nil || got.DotfilesDir != cfg.DotfilesDir {
+					t.Errorf("FromContext() = %v, %v, want %s", got, err, cfg.DotfilesDir)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 func TestSaveConfigNil(t *testing.T) {
@@ -243,8 +283,9 @@ func TestConfigPathGeneration(t *testing.T) {
 	originalHome := os.Getenv("HOME")
 	os.Setenv("HOME", tempDir)
 	defer os.Setenv("HOME", originalHome)
+	t.Setenv("XDG_CONFIG_HOME", "")
 
-	configPath, err := config.GetConfigPath()
+	configPath, err := config.GetConfigPath("")
 	if err != nil {
 		t.Fatalf("GetConfigPath failed: %v", err)
 	}
@@ -254,3 +295,435 @@ func TestConfigPathGeneration(t *testing.T) {
 		t.Errorf("Expected config path '%s', got '%s'", expectedPath, configPath)
 	}
 }
+
+func TestResolveLayers(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	systemDir := filepath.Join(tempDir, "etc")
+	originalSystemDir := config.SystemConfigDir
+	config.SystemConfigDir = systemDir
+	defer func() { config.SystemConfigDir = originalSystemDir }()
+
+	systemFile := filepath.Join(systemDir, "config.yaml")
+	userFile := filepath.Join(tempDir, ".config", "sokru", "config.toml")
+	projectFile := filepath.Join(tempDir, "project.json")
+	writeFiles(t, tempDir, map[string]string{
+		filepath.Join("etc", "config.yaml"):              "dotfiles_dir: /system/dotfiles\nlanguage: es\nverbose: true\nvariables:\n  email: system@example.com\n  editor: vim\n",
+		filepath.Join(".config", "sokru", "config.toml"): "verbose = false\nprofile = \"home\"\n\n[variables]\nemail = \"me@example.com\"\n",
+		"project.json": `{"profile": "work", "symlinks_file": "/project/symlinks.yaml"}`,
+	})
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		opts    config.Options
+		want    map[string]string
+		origins config.Origins
+	}{
+		{
+			name: "files over defaults",
+			want: map[string]string{"dotfiles_dir": "/system/dotfiles", "language": "es", "verbose": "false", "profile": "home", "email": "me@example.com", "editor": "vim"},
+			origins: config.Origins{
+				"dotfiles_dir":     systemFile,
+				"language":         systemFile,
+				"verbose":          userFile,
+				"profile":          userFile,
+				"dry_run":          config.OriginDefault,
+				"variables.email":  userFile,
+				"variables.editor": systemFile,
+			},
+		},
+		{
+			name: "environment over files",
+			env:  map[string]string{"SOKRU_LANGUAGE": "en", "SOKRU_DRY_RUN": "1", "SOKRU_PROFILE": "laptop"},
+			want: map[string]string{"language": "en", "dry_run": "true", "profile": "laptop"},
+			origins: config.Origins{
+				"language": "SOKRU_LANGUAGE",
+				"dry_run":  "SOKRU_DRY_RUN",
+				"profile":  "SOKRU_PROFILE",
+			},
+		},
+		{
+			name: "config file over environment, flags over everything",
+			env:  map[string]string{"SOKRU_PROFILE": "laptop", "SOKRU_DRY_RUN": "true"},
+			opts: config.Options{File: projectFile, Flags: map[string]string{"dry_run": "false", "verbose": "true"}},
+			want: map[string]string{"profile": "work", "symlinks_file": "/project/symlinks.yaml", "dry_run": "false", "verbose": "true"},
+			origins: config.Origins{
+				"profile":       projectFile,
+				"symlinks_file": projectFile,
+				"dry_run":       "--dry-run",
+				"verbose":       "--verbose",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, origins, err := config.Resolve(tt.opts)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			got := map[string]string{
+				"dotfiles_dir":  cfg.DotfilesDir,
+				"symlinks_file": cfg.SymlinksFile,
+				"language":      cfg.Language,
+				"verbose":       strconv.FormatBool(cfg.Verbose),
+				"dry_run":       strconv.FormatBool(cfg.DryRun),
+				"profile":       cfg.Profile,
+				"email":         cfg.Variables["email"],
+				"editor":        cfg.Variables["editor"],
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+			for key, want := range tt.origins {
+				if origins[key] != want {
+					t.Errorf("origin of %s = %q, want %q", key, origins[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		opts config.Options
+		want string
+	}{
+		{
+			name: "invalid boolean in the environment",
+			env:  map[string]string{"SOKRU_VERBOSE": "maybe"},
+			want: `invalid SOKRU_VERBOSE: "maybe" is not a boolean`,
+		},
+		{
+			name: "invalid boolean flag",
+			opts: config.Options{Flags: map[string]string{"dry_run": "maybe"}},
+			want: `invalid --dry-run: "maybe" is not a boolean`,
+		},
+		{
+			name: "missing config file",
+			opts: config.Options{File: filepath.Join(tempDir, "missing.yaml")},
+			want: "failed to read config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, _, err := config.Resolve(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Resolve() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateConfigKeepsOtherLayersOut(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("SOKRU_OS", "windows")
+
+	if err := config.UpdateConfig("", func(c *config.Config) { c.Language = "es" }); err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, ".config", "sokru", "config.yaml"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), "language: es") || strings.Contains(string(data), "windows") {
+		t.Errorf("config file =\n%s\nwant the language and no value of the environment", data)
+	}
+}
+
+func TestConfigPathFollowsXDG(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "xdg"))
+
+	if err := config.SaveConfig(config.GetDefaultConfig()); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	want := filepath.Join(tempDir, "xdg", "sokru", "config.yaml")
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("SaveConfig() did not write %s: %v", want, err)
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	var names []string
+	for _, key := range config.Keys() {
+		names = append(names, key.Name+":"+key.Type)
+	}
+	want := "version:integer dotfiles_dir:string symlinks_file:string os:string language:string verbose:boolean dry_run:boolean profile:string variables:map backup.max_backups:integer"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Keys() = %s, want %s", got, want)
+	}
+}
+
+func TestConfigSetAndGet(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+
+	tests := []struct {
+		key     string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{key: "backup.max_backups", value: "10", want: "10"},
+		{key: "backup.max_backups", value: "ten", wantErr: `"ten" is not an integer`},
+		{key: "backup.max_backups", value: "-1", wantErr: "-1 is negative"},
+		{key: "verbose", value: "true", want: "true"},
+		{key: "verbose", value: "maybe", wantErr: `"maybe" is not a boolean`},
+		{key: "os", value: "Darwin", want: "darwin"},
+		{key: "os", value: "bsd", wantErr: `"bsd" is not one of linux, darwin, windows`},
+		{key: "dotfiles_dir", value: "~/dots", want: filepath.Join("/home/me", "dots")},
+		{key: "variables.email", value: "me@example.com", want: "me@example.com"},
+		{key: "variables", value: "x", wantErr: "variables is a map"},
+		{key: "backup", value: "x", wantErr: `unknown configuration key "backup"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			err := config.Set(cfg, tt.key, tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Set() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			if got, err := config.Get(cfg, tt.key); err != nil || got != tt.want {
+				t.Errorf("Get() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAndUnsetKey(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"config.yaml", "# my settings\nlanguage: es # spoken at home\n"},
+		{"config.toml", "language = \"es\"\n"},
+		{"config.json", `{"language": "es"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("HOME", tempDir)
+			t.Setenv("XDG_CONFIG_HOME", "")
+			configPath := filepath.Join(tempDir, ".config", "sokru", tt.file)
+			writeFiles(t, tempDir, map[string]string{filepath.Join(".config", "sokru", tt.file): tt.content})
+
+			if _, err := config.SetKey("", "backup.max_backups", "3"); err != nil {
+				t.Fatalf("SetKey() error = %v", err)
+			}
+			if _, err := config.SetKey("", "variables.email", "me@example.com"); err != nil {
+				t.Fatalf("SetKey() error = %v", err)
+			}
+
+			cfg, origins, err := config.Resolve(config.Options{})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if cfg.Backup.MaxBackups != 3 || cfg.Variables["email"] != "me@example.com" || cfg.Language != "es" {
+				t.Errorf("Resolve() after SetKey = %+v", cfg)
+			}
+			if origins["backup.max_backups"] != configPath {
+				t.Errorf("origin of backup.max_backups = %s, want %s", origins["backup.max_backups"], configPath)
+			}
+
+			if err := config.UnsetKey("", "backup.max_backups"); err != nil {
+				t.Fatalf("UnsetKey() error = %v", err)
+			}
+			data, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "backup") || strings.Contains(string(data), "max_backups") {
+				t.Errorf("config file after UnsetKey =\n%s", data)
+			}
+			if tt.file == "config.yaml" && !strings.Contains(string(data), "# spoken at home") {
+				t.Errorf("SetKey() lost the comments:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		dryRun   bool
+		applied  int
+		want     string
+		wantErr  string
+		noBackup bool
+	}{
+		{
+			name:    "unversioned yaml",
+			file:    "config.yaml",
+			content: "# mine\ndotfiles_dir: /d\n",
+			applied: 1,
+			want:    "# mine\nversion: 2\ndotfiles_dir: /d\n",
+		},
+		{
+			name:    "unversioned toml",
+			file:    "config.toml",
+			content: "dotfiles_dir = \"/d\"\n",
+			applied: 1,
+			want:    "dotfiles_dir = \"/d\"\nversion = 2\n",
+		},
+		{
+			name:     "dry run",
+			file:     "config.yaml",
+			content:  "dotfiles_dir: /d\n",
+			dryRun:   true,
+			applied:  1,
+			want:     "dotfiles_dir: /d\n",
+			noBackup: true,
+		},
+		{
+			name:     "current",
+			file:     "config.yaml",
+			content:  "version: 2\ndotfiles_dir: /d\n",
+			want:     "version: 2\ndotfiles_dir: /d\n",
+			noBackup: true,
+		},
+		{
+			name:    "newer",
+			file:    "config.yaml",
+			content: "version: 99\n",
+			wantErr: "has version 99, this sok only reads up to version 2",
+		},
+		{
+			name:    "invalid",
+			file:    "config.yaml",
+			content: "version: two\n",
+			wantErr: `invalid version "two"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, tt.file)
+			writeFiles(t, tempDir, map[string]string{tt.file: tt.content})
+
+			result, err := config.Migrate("", path, tt.dryRun)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Migrate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if len(result.Applied) != tt.applied {
+				t.Errorf("Migrate() applied %d migrations, want %d", len(result.Applied), tt.applied)
+			}
+
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("file after Migrate() =\n%s\nwant\n%s", data, tt.want)
+			}
+
+			backups, _ := filepath.Glob(path + ".v1-*.bak")
+			if tt.noBackup != (len(backups) == 0) {
+				t.Errorf("backups = %v, want a backup: %v", backups, !tt.noBackup)
+			}
+			if len(backups) == 1 {
+				if original, _ := os.ReadFile(backups[0]); string(original) != tt.content {
+					t.Errorf("backup = %q, want the original %q", original, tt.content)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateKeepsEveryBackup(t *testing.T) {
+	// Migrations within the same second keep a backup each
+	home := t.TempDir()
+	path := filepath.Join(home, "config.yaml")
+	for _, content := range []string{"language: es\n", "language: en\n", "verbose: true\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := config.Migrate(home, path, false); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".v1-*.bak")
+	var originals []string
+	for _, backup := range backups {
+		data, _ := os.ReadFile(backup)
+		originals = append(originals, string(data))
+	}
+	sort.Strings(originals)
+	if want := []string{"language: en\n", "language: es\n", "verbose: true\n"}; !reflect.DeepEqual(originals, want) {
+		t.Errorf("backups hold %q, want %q", originals, want)
+	}
+}
+
+func TestOldConfigFilesAreMigrated(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	configPath := filepath.Join(tempDir, ".config", "sokru", "config.yaml")
+	writeFiles(t, tempDir, map[string]string{filepath.Join(".config", "sokru", "config.yaml"): "language: es\n"})
+
+	// Reading does not touch the file
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Version != config.CurrentVersion || cfg.Language != "es" {
+		t.Errorf("LoadConfig() = %+v", cfg)
+	}
+	if backups, _ := filepath.Glob(configPath + ".*.bak"); len(backups) != 0 {
+		t.Errorf("LoadConfig() backed up the file: %v", backups)
+	}
+
+	// Changing it migrates it, keeping the original
+	if _, err := config.SetKey("", "verbose", "true"); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	data, _ := os.ReadFile(configPath)
+	if string(data) != "version: 2\nlanguage: es\nverbose: true\n" {
+		t.Errorf("file after SetKey() =\n%s", data)
+	}
+	if backups, _ := filepath.Glob(configPath + ".v1-*.bak"); len(backups) != 1 {
+		t.Errorf("SetKey() backups = %v, want one", backups)
+	}
+
+	if _, err := config.SetKey("", "version", "3"); err == nil {
+		t.Error("SetKey(version) expected an error")
+	}
+}
@@ -0,0 +1,113 @@
+// Package test
+// Description: Unit tests for the condition expression language
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/expr"
+)
+
+func exprContext() expr.Context {
+	env := map[string]string{"WORK": "1", "SHELL": "/bin/zsh"}
+	paths := map[string]bool{"~/.cargo": true}
+
+	return expr.Context{
+		Vars: map[string]any{
+			"os":      "linux",
+			"arch":    "amd64",
+			"wsl":     false,
+			"desktop": expr.Set{"ubuntu", "GNOME"},
+			"none":    expr.Set{},
+		},
+		Env: func(name string) string { return env[name] },
+		Funcs: map[string]func(args ...string) (any, error){
+			"exists": func(args ...string) (any, error) {
+				if len(args) != 1 {
+					return nil, errors.New("expects 1 argument")
+				}
+				return paths[args[0]], nil
+			},
+		},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`os == "linux"`, true},
+		{`os != "linux"`, false},
+		{`os == 'darwin' || arch == "amd64"`, true},
+		{`os == "linux" && env.WORK == "1" && exists("~/.cargo")`, true},
+		{`os == "linux" && exists("~/.rustup")`, false},
+		{`!wsl`, true},
+		{`wsl == false`, true},
+		{`!(os == "linux" || os == "darwin")`, false},
+		{`env.WORK`, true},
+		{`env.UNSET`, false},
+		{`env.UNSET == ""`, true},
+		{`true && !false`, true},
+		{`os == "linux" || unknown == "x"`, true},
+		{`"a \"quoted\" string" == 'a "quoted" string'`, true},
+		{`desktop == "GNOME"`, true},
+		{`desktop == "gnome"`, true},
+		{`"ubuntu" == desktop`, true},
+		{`desktop != "KDE"`, true},
+		{`desktop`, true},
+		{`none`, false},
+		{`none == ""`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			result, err := expr.Eval(tt.expression, exprContext())
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Eval() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		column     int
+	}{
+		{`os == `, 7},
+		{`os = "linux"`, 4},
+		{`(os == "linux"`, 15},
+		{`os == "linux`, 7},
+		{`os == "linux" extra`, 15},
+		{`unknown == "x"`, 1},
+		{`env. == "x"`, 1},
+		{`missing("x")`, 1},
+		{`os == true`, 4},
+		{`exists("a", "b")`, 1},
+		{`desktop == true`, 9},
+		{`desktop == none`, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := expr.Eval(tt.expression, exprContext())
+			if err == nil {
+				t.Fatal("Eval() expected error, got nil")
+			}
+			var exprErr *expr.Error
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("Expected *expr.Error, got %T", err)
+			}
+			if exprErr.Column != tt.column {
+				t.Errorf("Error column = %d, want %d (%v)", exprErr.Column, tt.column, err)
+			}
+		})
+	}
+}
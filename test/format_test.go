@@ -0,0 +1,197 @@
+// Package test
+// Description: Unit tests for reading configuration files in YAML, JSON and TOML
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/config"
+	"github.com/alexlm78/sokru/internal/format"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want format.Format
+	}{
+		{"symlinks.yaml", format.YAML},
+		{"symlinks.yml", format.YAML},
+		{"symlinks.json", format.JSON},
+		{"symlinks.TOML", format.TOML},
+		{"symlinks", format.YAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := format.FromPath(tt.path); got != tt.want {
+				t.Errorf("FromPath(%q) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSymlinkConfigs_Formats(t *testing.T) {
+	files := map[string]string{
+		"symlinks.yaml": `- name: shell
+  os: linux
+  common:
+    ~/.bashrc: bashrc
+  links:
+    - target: ~/.gitconfig
+      source: gitconfig
+      mode: copy
+- include: more.yaml
+`,
+		"symlinks.json": `[
+	{
+		"name": "shell",
+		"os": "linux",
+		"common": {"~/.bashrc": "bashrc"},
+		"links": [{"target": "~/.gitconfig", "source": "gitconfig", "mode": "copy"}]
+	},
+	{"include": "more.json"}
+]
+`,
+		"symlinks.toml": `[[entries]]
+name = "shell"
+os = "linux"
+
+[entries.common]
+"~/.bashrc" = "bashrc"
+
+[[entries.links]]
+target = "~/.gitconfig"
+source = "gitconfig"
+mode = "copy"
+
+[[entries]]
+include = "more.toml"
+`,
+		"more.yaml": "- after: shell\n  common:\n    ~/.vimrc: vimrc\n",
+		"more.json": `[{"after": "shell", "common": {"~/.vimrc": "vimrc"}}]`,
+		"more.toml": "[[entries]]\nafter = \"shell\"\ncommon = { \"~/.vimrc\" = \"vimrc\" }\n",
+	}
+
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, files)
+
+	for _, name := range []string{"symlinks.yaml", "symlinks.json", "symlinks.toml"} {
+		t.Run(name, func(t *testing.T) {
+			configs, err := sokru.LoadSymlinkConfigs(filepath.Join(tempDir, name))
+			if err != nil {
+				t.Fatalf("LoadSymlinkConfigs() error = %v", err)
+			}
+			if len(configs) != 2 {
+				t.Fatalf("Expected 2 entries, got %d", len(configs))
+			}
+
+			first, second := configs[0], configs[1]
+			if first.Name != "shell" || first.OS != "linux" || first.Common["~/.bashrc"] != "bashrc" {
+				t.Errorf("First entry = %+v", first)
+			}
+			if len(first.Links) != 1 || first.Links[0].Target != "~/.gitconfig" || first.Links[0].Mode != "copy" {
+				t.Errorf("First entry links = %+v", first.Links)
+			}
+			if !reflect.DeepEqual([]string(second.After), []string{"shell"}) || second.Common["~/.vimrc"] != "vimrc" {
+				t.Errorf("Second entry = %+v", second)
+			}
+		})
+	}
+}
+
+func TestLoadSymlinkConfigs_FormatErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"broken.json": `[{"common": }]`,
+		"broken.toml": "[[entries]\n",
+		"table.toml":  "[common]\n\"~/.bashrc\" = \"bashrc\"\n",
+	})
+
+	for _, name := range []string{"broken.json", "broken.toml", "table.toml"} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sokru.LoadSymlinkConfigs(filepath.Join(tempDir, name)); err == nil {
+				t.Errorf("LoadSymlinkConfigs(%s) expected an error", name)
+			}
+		})
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"config.toml", "dotfiles_dir = \"/test/dotfiles\"\nlanguage = \"es\"\nverbose = true\n\n[variables]\nemail = \"me@example.com\"\n"},
+		{"config.json", `{"dotfiles_dir": "/test/dotfiles", "language": "es", "verbose": true, "variables": {"email": "me@example.com"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("HOME", tempDir)
+			configPath := filepath.Join(tempDir, ".config", "sokru", tt.file)
+			writeFiles(t, tempDir, map[string]string{filepath.Join(".config", "sokru", tt.file): tt.content})
+
+			if path, _ := config.GetConfigPath(""); path != configPath {
+				t.Errorf("GetConfigPath() = %s, want %s", path, configPath)
+			}
+
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.DotfilesDir != "/test/dotfiles" || cfg.Language != "es" || !cfg.Verbose || cfg.Variables["email"] != "me@example.com" {
+				t.Fatalf("LoadConfig() = %+v", cfg)
+			}
+
+			// Saving keeps the format of the file
+			cfg.Profile = "work"
+			if err := config.SaveConfig(cfg); err != nil {
+				t.Fatalf("SaveConfig() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(tempDir, ".config", "sokru", "config.yaml")); !os.IsNotExist(err) {
+				t.Errorf("SaveConfig() created config.yaml next to %s", tt.file)
+			}
+
+			reloaded, err := config.LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() after save error = %v", err)
+			}
+			if !reflect.DeepEqual(reloaded, cfg) {
+				t.Errorf("Reloaded config = %+v, want %+v", reloaded, cfg)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{"symlinks.yaml", "a: b\n c: d\n", "2: mapping values are not allowed in this context"},
+		{"symlinks.json", "[\n  {\"a\": }\n]", "2:9: invalid character '}' looking for beginning of value"},
+		{"symlinks.toml", "[[entries]]\nos = linux\n", "2:6: expected value but found \"linux\" instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := format.Parse(tt.path, []byte(tt.data))
+			var syntaxErr *format.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want a *format.SyntaxError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
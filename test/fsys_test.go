@@ -0,0 +1,624 @@
+// Package test
+// Description: Unit tests for the in-memory and fault-injecting filesystems, and rollback under injected failures
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alexlm78/sokru/internal/backup"
+	"github.com/alexlm78/sokru/internal/rollback"
+	"github.com/alexlm78/sokru/internal/state"
+	"github.com/alexlm78/sokru/pkg/fsys"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// newMemFS returns a MemFS holding files, by path, and the directories
+// they are in
+func newMemFS(t *testing.T, files map[string]string) *fsys.MemFS {
+	t.Helper()
+
+	mem := fsys.NewMemFS()
+	for path, content := range files {
+		if err := mem.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mem
+}
+
+func TestMemFS(t *testing.T) {
+	mem := newMemFS(t, map[string]string{
+		"/home/user/dotfiles/bashrc": "export EDITOR=vim\n",
+		"/home/user/notes":           "notes\n",
+	})
+	for _, link := range []struct{ oldname, newname string }{
+		{"/home/user/dotfiles/bashrc", "/home/user/.bashrc"},
+		{"dotfiles", "/home/user/.dotfiles"},
+		{"/nowhere", "/home/user/dangling"},
+		{"/home/user/loop", "/home/user/loop"},
+	} {
+		if err := mem.Symlink(link.oldname, link.newname); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		op      func() error
+		wantErr error
+	}{
+		{"read through a symlink", func() error { _, err := mem.ReadFile("/home/user/.bashrc"); return err }, nil},
+		{"read through a relative symlinked directory", func() error { _, err := mem.ReadFile("/home/user/.dotfiles/bashrc"); return err }, nil},
+		{"lstat a dangling symlink", func() error { _, err := mem.Lstat("/home/user/dangling"); return err }, nil},
+		{"stat a dangling symlink", func() error { _, err := mem.Stat("/home/user/dangling"); return err }, os.ErrNotExist},
+		{"stat a symlink loop", func() error { _, err := mem.Stat("/home/user/loop"); return err }, syscall.ELOOP},
+		{"readlink a regular file", func() error { _, err := mem.Readlink("/home/user/notes"); return err }, syscall.EINVAL},
+		{"symlink over an existing file", func() error { return mem.Symlink("/x", "/home/user/notes") }, os.ErrExist},
+		{"write in a missing directory", func() error { return mem.WriteFile("/missing/file", nil, 0644) }, os.ErrNotExist},
+		{"write below a regular file", func() error { return mem.WriteFile("/home/user/notes/file", nil, 0644) }, syscall.ENOTDIR},
+		{"remove a directory with files", func() error { return mem.Remove("/home/user/dotfiles") }, syscall.ENOTEMPTY},
+		{"remove a missing file", func() error { return mem.Remove("/home/user/missing") }, os.ErrNotExist},
+		{"remove all of a missing directory", func() error { return mem.RemoveAll("/missing") }, nil},
+		{"hard link a directory", func() error { return mem.Link("/home/user/dotfiles", "/home/user/link") }, syscall.EPERM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.op()
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("error = %v, want none", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Removing a symlink leaves what it points to
+	if err := mem.Remove("/home/user/.dotfiles"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Stat("/home/user/dotfiles/bashrc"); err != nil {
+		t.Errorf("Stat() after removing a symlink to its directory error = %v", err)
+	}
+
+	entries, err := mem.ReadDir("/home/user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{".bashrc", "dangling", "dotfiles", "loop", "notes"}
+	if len(names) != len(want) {
+		t.Fatalf("ReadDir() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("ReadDir() = %v, want %v", names, want)
+			break
+		}
+	}
+}
+
+func TestMemFSHardLinks(t *testing.T) {
+	mem := newMemFS(t, map[string]string{"/dotfiles/vimrc": "set number\n"})
+	if err := mem.Link("/dotfiles/vimrc", "/vimrc"); err != nil {
+		t.Fatal(err)
+	}
+
+	source, _ := mem.Stat("/dotfiles/vimrc")
+	target, _ := mem.Stat("/vimrc")
+	if !mem.SameFile(source, target) {
+		t.Error("SameFile() = false for a hard link")
+	}
+
+	// Writes go through every link
+	if err := mem.WriteFile("/vimrc", []byte("set nonumber\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := mem.ReadFile("/dotfiles/vimrc"); string(content) != "set nonumber\n" {
+		t.Errorf("source = %q after writing its hard link", content)
+	}
+
+	// Removing one link keeps the other
+	if err := mem.Remove("/vimrc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Stat("/dotfiles/vimrc"); err != nil {
+		t.Errorf("Stat() of the source after removing its hard link error = %v", err)
+	}
+	if err := mem.WriteFile("/vimrc", []byte("set number\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target, _ = mem.Stat("/vimrc")
+	if mem.SameFile(source, target) {
+		t.Error("SameFile() = true for a new file at the same path")
+	}
+}
+
+func TestFaulty(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault fsys.Fault
+		want  []bool // whether each of the symlinks fails
+	}{
+		{"every call", fsys.Fault{Op: fsys.OpSymlink, Err: syscall.EPERM}, []bool{true, true, true}},
+		{"after a call", fsys.Fault{Op: fsys.OpSymlink, After: 1, Err: syscall.EPERM}, []bool{false, true, true}},
+		{"once", fsys.Fault{Op: fsys.OpSymlink, Times: 1, Err: syscall.EPERM}, []bool{true, false, false}},
+		{"one path", fsys.Fault{Path: "/link1", Err: syscall.EPERM}, []bool{false, true, false}},
+		{"another operation", fsys.Fault{Op: fsys.OpRemove, Err: syscall.EPERM}, []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faulty := fsys.NewFaulty(fsys.NewMemFS(), tt.fault)
+			for i, wantErr := range tt.want {
+				link := filepath.Join("/", "link"+string(rune('0'+i)))
+				err := faulty.Symlink("/source", link)
+				if wantErr != (err != nil) {
+					t.Errorf("Symlink() #%d error = %v, want failure %v", i, err, wantErr)
+				}
+				if err != nil && !errors.Is(err, syscall.EPERM) {
+					t.Errorf("Symlink() #%d error = %v, want EPERM", i, err)
+				}
+				// Failed calls do not reach the wrapped filesystem
+				if _, statErr := faulty.Lstat(link); (statErr == nil) == wantErr {
+					t.Errorf("Lstat() #%d error = %v after Symlink() error = %v", i, statErr, err)
+				}
+			}
+			if got := faulty.Calls(fsys.OpSymlink); got != len(tt.want) {
+				t.Errorf("Calls() = %d, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+// atomicMemFS is a MemFS counting its atomic writes
+type atomicMemFS struct {
+	*fsys.MemFS
+	atomic int
+}
+
+func (m *atomicMemFS) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	m.atomic++
+	return m.MemFS.WriteFile(name, data, perm)
+}
+
+func TestFaultyWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name       string
+		inner      fsys.FS
+		wantAtomic int
+	}{
+		{name: "forwards to an atomic writer", inner: &atomicMemFS{MemFS: fsys.NewMemFS()}, wantAtomic: 1},
+		{name: "falls back to WriteFile", inner: fsys.NewMemFS()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faulty := fsys.NewFaulty(tt.inner, fsys.Fault{Op: fsys.OpWriteFile, Times: 1, Err: syscall.ENOSPC})
+
+			if err := fsys.WriteFileAtomic(faulty, "/metadata.json", []byte("{}"), 0644); !errors.Is(err, syscall.ENOSPC) {
+				t.Fatalf("WriteFileAtomic() error = %v, want ENOSPC", err)
+			}
+			if _, err := tt.inner.Lstat("/metadata.json"); !os.IsNotExist(err) {
+				t.Errorf("failed WriteFileAtomic() reached the wrapped FS (%v)", err)
+			}
+
+			if err := fsys.WriteFileAtomic(faulty, "/metadata.json", []byte("{}"), 0644); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			if content, err := tt.inner.ReadFile("/metadata.json"); err != nil || string(content) != "{}" {
+				t.Errorf("content = %q, %v, want {}", content, err)
+			}
+			if inner, ok := tt.inner.(*atomicMemFS); ok && inner.atomic != tt.wantAtomic {
+				t.Errorf("atomic writes = %d, want %d", inner.atomic, tt.wantAtomic)
+			}
+			if got := faulty.Calls(fsys.OpWriteFile); got != 2 {
+				t.Errorf("Calls() = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestRollbackOnFaultyFS(t *testing.T) {
+	mem := newMemFS(t, map[string]string{"/home/user/.profile": "old\n"})
+	faulty := fsys.NewFaulty(mem)
+	tracker := rollback.NewTrackerFS(faulty)
+
+	if err := mem.Symlink("/dotfiles/bashrc", "/home/user/.bashrc"); err != nil {
+		t.Fatal(err)
+	}
+	tracker.TrackCreated("/home/user/.bashrc", "/dotfiles/bashrc")
+	if err := mem.WriteFile("/home/user/.profile", []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker.TrackWritten("/home/user/.profile", []byte("old\n"), 0644, true)
+
+	// The created symlink cannot be removed, the rest is still rolled back
+	faulty.Inject(fsys.Fault{Op: fsys.OpRemove, Path: "/home/user/.bashrc", Err: syscall.EPERM})
+	if err := tracker.Rollback(); err == nil {
+		t.Error("Rollback() error = nil, want the failed removal")
+	}
+
+	if content, _ := mem.ReadFile("/home/user/.profile"); string(content) != "old\n" {
+		t.Errorf(".profile = %q after Rollback(), want old content", content)
+	}
+	if _, err := mem.Lstat("/home/user/.bashrc"); err != nil {
+		t.Errorf(".bashrc was removed despite the injected failure: %v", err)
+	}
+}
+
+func TestBackupOnMemFS(t *testing.T) {
+	mem := newMemFS(t, map[string]string{"/home/user/.vimrc": "set number\n"})
+	if err := mem.Symlink("/elsewhere", "/home/user/.bashrc"); err != nil {
+		t.Fatal(err)
+	}
+	manager := backup.NewManagerFS(mem, "/backups")
+
+	metadata := &backup.BackupMetadata{ID: "test", Command: "test"}
+	for _, path := range []string{"/home/user/.vimrc", "/home/user/.bashrc"} {
+		entry, err := manager.CreateBackup(path, metadata.ID)
+		if err != nil {
+			t.Fatalf("CreateBackup(%s) error = %v", path, err)
+		}
+		metadata.Entries = append(metadata.Entries, *entry)
+	}
+	if err := manager.SaveMetadata(metadata); err != nil {
+		t.Fatalf("SaveMetadata() error = %v", err)
+	}
+
+	// Replace both files, then restore them
+	for _, path := range []string{"/home/user/.vimrc", "/home/user/.bashrc"} {
+		if err := mem.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Symlink("/dotfiles"+path, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.RestoreBackup(metadata.ID); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	if content, err := mem.ReadFile("/home/user/.vimrc"); err != nil || string(content) != "set number\n" {
+		t.Errorf(".vimrc = %q (%v) after RestoreBackup()", content, err)
+	}
+	if target, err := mem.Readlink("/home/user/.bashrc"); err != nil || target != "/elsewhere" {
+		t.Errorf(".bashrc points to %q (%v) after RestoreBackup()", target, err)
+	}
+
+	backups, err := manager.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Errorf("ListBackups() = %+v, %v, want the saved backup", backups, err)
+	}
+}
+
+const faultSymlinks = `- common:
+    ~/.bashrc: bashrc
+    ~/.gitconfig: gitconfig
+  links:
+    - target: ~/.vimrc
+      source: vimrc
+      mode: hardlink
+`
+
+func TestInstallRollsBackInjectedFailures(t *testing.T) {
+	sources := map[string]string{"bashrc": "export EDITOR=vim\n", "gitconfig": "[user]\n", "vimrc": "set number\n"}
+
+	tests := []struct {
+		name         string
+		faults       func(home string) []fsys.Fault
+		wantOp       sokru.Op
+		wantErr      error
+		wantRollback bool
+	}{
+		{
+			name: "symlink fails mid-run",
+			faults: func(home string) []fsys.Fault {
+				return []fsys.Fault{{Op: fsys.OpSymlink, After: 1, Times: 1, Err: syscall.EPERM}}
+			},
+			wantOp:       sokru.OpLink,
+			wantErr:      syscall.EPERM,
+			wantRollback: true,
+		},
+		{
+			name: "hard link across devices",
+			faults: func(home string) []fsys.Fault {
+				return []fsys.Fault{{Op: fsys.OpLink, Err: syscall.EXDEV}}
+			},
+			wantOp:       sokru.OpCopy,
+			wantErr:      syscall.EXDEV,
+			wantRollback: true,
+		},
+		{
+			name: "rollback fails too",
+			faults: func(home string) []fsys.Fault {
+				return []fsys.Fault{
+					{Op: fsys.OpLink, Err: syscall.EXDEV},
+					// Only the rollback removes the created symlink
+					{Op: fsys.OpRemove, Path: filepath.Join(home, ".gitconfig"), Err: syscall.EPERM},
+				}
+			},
+			wantOp:       sokru.OpCopy,
+			wantErr:      syscall.EXDEV,
+			wantRollback: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, home := setupDotfiles(t, faultSymlinks, nil)
+			bashrc := filepath.Join(home, ".bashrc")
+			gitconfig := filepath.Join(home, ".gitconfig")
+			vimrc := filepath.Join(home, ".vimrc")
+
+			// The sources and targets live in memory
+			files := map[string]string{vimrc: "local vimrc\n"}
+			for name, content := range sources {
+				files[filepath.Join(cfg.DotfilesDir, name)] = content
+			}
+			mem := newMemFS(t, files)
+			if err := mem.Symlink("/elsewhere", bashrc); err != nil {
+				t.Fatal(err)
+			}
+			// sok wrote the local .vimrc, so it is relinked rather than skipped
+			stateDir, _, err := cfg.Dirs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			st, err := state.Load(state.StatePath(home, stateDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			st.Set(vimrc, state.FileState{Source: filepath.Join(cfg.DotfilesDir, "vimrc"), Mode: "hardlink", Hash: state.HashBytes([]byte("local vimrc\n"))})
+			if err := st.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := sokru.Install(cfg, sokru.RunOptions{FS: fsys.NewFaulty(mem, tt.faults(home)...)})
+
+			var opErr *sokru.Error
+			if !errors.As(err, &opErr) || opErr.Op != tt.wantOp || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Install() error = %v, want a %s error caused by %v", err, tt.wantOp, tt.wantErr)
+			}
+			if result.RolledBack == 0 {
+				t.Fatal("Install() rolled back nothing")
+			}
+			if (result.RollbackErr == nil) != tt.wantRollback {
+				t.Errorf("Install() RollbackErr = %v, want a complete rollback %v", result.RollbackErr, tt.wantRollback)
+			}
+
+			// The replaced targets are back as they were
+			if target, err := mem.Readlink(bashrc); err != nil || target != "/elsewhere" {
+				t.Errorf("%s points to %q (%v) after the rollback, want /elsewhere", bashrc, target, err)
+			}
+			if content, err := mem.ReadFile(vimrc); tt.wantOp == sokru.OpCopy && (err != nil || string(content) != "local vimrc\n") {
+				t.Errorf("%s = %q (%v) after the rollback, want its local content", vimrc, content, err)
+			}
+			_, err = mem.Lstat(gitconfig)
+			if tt.wantRollback && !os.IsNotExist(err) {
+				t.Errorf("%s still exists after the rollback (%v)", gitconfig, err)
+			}
+
+			// Nothing reached the disk
+			if _, err := os.Lstat(bashrc); !os.IsNotExist(err) {
+				t.Errorf("Install() on a MemFS changed %s on disk (%v)", bashrc, err)
+			}
+		})
+	}
+}
+
+func TestStatusAndBackupsOnMemFS(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, nil)
+	bashrc := filepath.Join(home, ".bashrc")
+
+	files := make(map[string]string)
+	for name, content := range apiSources {
+		files[filepath.Join(cfg.DotfilesDir, name)] = content
+	}
+	mem := newMemFS(t, files)
+	if err := mem.Symlink("/elsewhere", bashrc); err != nil {
+		t.Fatal(err)
+	}
+	onMem := sokru.BackupOptions{FS: mem}
+
+	result, err := sokru.Install(cfg, sokru.RunOptions{FS: mem})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	report, err := sokru.CheckStatus(cfg, sokru.StatusOptions{FS: mem})
+	if err != nil {
+		t.Fatalf("CheckStatus() error = %v", err)
+	}
+	if got := report.Count(sokru.StatusInstalled); got != 2 {
+		t.Errorf("CheckStatus() on the MemFS found %d installed targets, want 2", got)
+	}
+	if report, err := sokru.CheckStatus(cfg, sokru.StatusOptions{}); err != nil || report.Count(sokru.StatusMissing) != 2 {
+		t.Errorf("CheckStatus() on disk = %+v, %v, want 2 missing targets", report, err)
+	}
+
+	backups, err := sokru.Backups(cfg, onMem)
+	if err != nil || len(backups) != 1 || backups[0].ID != result.Backup.ID {
+		t.Fatalf("Backups() on the MemFS = %+v, %v, want the backup of Install()", backups, err)
+	}
+	if backups, err := sokru.Backups(cfg, sokru.BackupOptions{}); err != nil || len(backups) != 0 {
+		t.Errorf("Backups() on disk = %+v, %v, want none", backups, err)
+	}
+	if _, err := sokru.LoadBackup(cfg, result.Backup.ID, onMem); err != nil {
+		t.Errorf("LoadBackup() on the MemFS error = %v", err)
+	}
+
+	if err := sokru.Restore(cfg, result.Backup.ID, onMem); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if target, err := mem.Readlink(bashrc); err != nil || target != "/elsewhere" {
+		t.Errorf("%s points to %q (%v) after Restore(), want /elsewhere", bashrc, target, err)
+	}
+
+	if err := sokru.DeleteBackup(cfg, result.Backup.ID, onMem); err != nil {
+		t.Fatalf("DeleteBackup() error = %v", err)
+	}
+	if backups, err := sokru.Backups(cfg, onMem); err != nil || len(backups) != 0 {
+		t.Errorf("Backups() after DeleteBackup() = %+v, %v, want none", backups, err)
+	}
+}
+
+func TestInstallReadsSourcesFromFS(t *testing.T) {
+	// Sources only exist in memory: globs, templates and exists() find them
+	// there
+	cfg, home := setupDotfiles(t, `- links:
+    - target: ~/.config/fish
+      source: fish/*.fish
+    - target: ~/.gitconfig
+      source: gitconfig.tmpl
+      kind: template
+    - target: ~/.vimrc
+      source: vimrc
+      when: exists("vimrc")
+    - target: ~/.nanorc
+      source: nanorc
+      when: exists("nanorc")
+`, nil)
+	mem := newMemFS(t, map[string]string{
+		filepath.Join(cfg.DotfilesDir, "fish", "aliases.fish"): "alias g git\n",
+		filepath.Join(cfg.DotfilesDir, "fish", "prompt.fish"):  "function fish_prompt\nend\n",
+		filepath.Join(cfg.DotfilesDir, "gitconfig.tmpl"):       "[core]\n\tos = {{ .OS }}\n",
+		filepath.Join(cfg.DotfilesDir, "vimrc"):                "set number\n",
+	})
+	if err := mem.MkdirAll(filepath.Join(home, ".config", "fish"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sokru.Install(cfg, sokru.RunOptions{FS: mem}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	for _, target := range []string{".config/fish/aliases.fish", ".config/fish/prompt.fish", ".gitconfig", ".vimrc"} {
+		if _, err := mem.Stat(filepath.Join(home, target)); err != nil {
+			t.Errorf("%s not installed on the MemFS: %v", target, err)
+		}
+	}
+	if content, err := mem.ReadFile(filepath.Join(home, ".gitconfig")); err != nil || !strings.Contains(string(content), "os = "+cfg.OS) {
+		t.Errorf(".gitconfig = %q, %v, want the rendered template", content, err)
+	}
+	if _, err := mem.Lstat(filepath.Join(home, ".nanorc")); !os.IsNotExist(err) {
+		t.Errorf(".nanorc installed without its source (%v)", err)
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	mem := newMemFS(t, map[string]string{
+		"/home/user/dotfiles/bashrc":          "export EDITOR=vim\n",
+		"/home/user/dotfiles/fish/a.fish":     "alias g git\n",
+		"/home/user/dotfiles/fish/b.fish":     "alias l ls\n",
+		"/home/user/dotfiles/fish/conf.d/x":   "set x\n",
+		"/home/user/elsewhere/not-in-the-dir": "\n",
+	})
+	dir := fsys.DirFS(mem, "/home/user/dotfiles")
+
+	if err := fstest.TestFS(dir, "bashrc", "fish/a.fish", "fish/b.fish", "fish/conf.d/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "fish/*.fish", want: []string{"fish/a.fish", "fish/b.fish"}},
+		{pattern: "*/conf.d/*", want: []string{"fish/conf.d/x"}},
+		{pattern: "missing/*", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			matches, err := fs.Glob(dir, tt.pattern)
+			if err != nil {
+				t.Fatalf("Glob() error = %v", err)
+			}
+			if !reflect.DeepEqual(matches, tt.want) {
+				t.Errorf("Glob() = %v, want %v", matches, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalSymlinks(t *testing.T) {
+	mem := newMemFS(t, map[string]string{
+		"/dotfiles/nvim/init.lua": "\n",
+		"/home/user/notes":        "\n",
+	})
+	for _, link := range []struct{ oldname, newname string }{
+		{"/dotfiles/nvim", "/home/user/nvim"},
+		{"../../../dotfiles", "/home/user/.config/dotfiles"},
+		{"dotfiles/nvim", "/home/user/.config/nvim"},
+		{"/home/user/loop", "/home/user/loop"},
+	} {
+		if err := mem.MkdirAll(filepath.Dir(link.newname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Symlink(link.oldname, link.newname); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{path: "/home/user/notes", want: "/home/user/notes"},
+		{path: "/home/user/nvim/init.lua", want: "/dotfiles/nvim/init.lua"},
+		{path: "/home/user/.config/nvim", want: "/dotfiles/nvim"},
+		{path: "/home/user/.config/dotfiles/../home", want: "/home"},
+		{path: "/home/user/missing", wantErr: fs.ErrNotExist},
+		{path: "/home/user/notes/x", wantErr: syscall.ENOTDIR},
+		{path: "/home/user/loop", wantErr: syscall.ELOOP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := fsys.EvalSymlinks(mem, tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("EvalSymlinks() = %q, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("EvalSymlinks() = %q, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOverlapsOnMemFS(t *testing.T) {
+	// The directory linked into the dotfiles only exists in memory
+	mem := newMemFS(t, map[string]string{"/dotfiles/nvim/init.lua": "\n"})
+	if err := mem.MkdirAll("/home/user", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Symlink("../../dotfiles/nvim", "/home/user/nvim"); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := []sokru.Link{{Target: "/home/user/nvim/lua/init.lua", Source: "/dotfiles/init.lua"}}
+	issues := sokru.CheckOverlaps(mem, plan, "/dotfiles")
+	if len(issues) != 1 || !strings.Contains(issues[0].String(), "/dotfiles/nvim/lua/init.lua") {
+		t.Errorf("CheckOverlaps() = %v, want the target resolving into /dotfiles", issues)
+	}
+}
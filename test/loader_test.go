@@ -0,0 +1,257 @@
+// Package test
+// Description: Unit tests for loading symlinks files and their includes
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/i18n"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// writeFiles creates files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadSymlinkConfigs_Include(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"symlinks.yaml": `- common:
+    ~/.bashrc: bashrc
+- include: modules/*.yaml
+- include:
+    - extra/git.yaml
+`,
+		"modules/nvim.yaml": `- common:
+    ~/.config/nvim: nvim
+`,
+		"modules/tmux.yaml": `- include: ../shared/tmux-plugins.yaml
+- common:
+    ~/.tmux.conf: tmux.conf
+`,
+		"shared/tmux-plugins.yaml": `- common:
+    ~/.tmux/plugins: tmux/plugins
+`,
+		"extra/git.yaml": `- common:
+    ~/.gitconfig: gitconfig
+`,
+		"modules/README.md": "not included",
+	})
+
+	configs, err := sokru.LoadSymlinkConfigs(filepath.Join(tempDir, "symlinks.yaml"))
+	if err != nil {
+		t.Fatalf("LoadSymlinkConfigs() error = %v", err)
+	}
+
+	expected := []struct {
+		target string
+		file   string
+		line   int
+	}{
+		{"~/.bashrc", "symlinks.yaml", 1},
+		{"~/.config/nvim", "modules/nvim.yaml", 1},
+		{"~/.tmux/plugins", "shared/tmux-plugins.yaml", 1},
+		{"~/.tmux.conf", "modules/tmux.yaml", 2},
+		{"~/.gitconfig", "extra/git.yaml", 1},
+	}
+
+	if len(configs) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(configs))
+	}
+	for i, want := range expected {
+		entry := configs[i]
+		if _, ok := entry.Common[want.target]; !ok {
+			t.Errorf("Entry %d: expected target %s, got %v", i, want.target, entry.Common)
+		}
+		if !strings.HasSuffix(filepath.ToSlash(entry.File), want.file) || entry.Line != want.line {
+			t.Errorf("Entry %d: position = %s:%d, want %s:%d", i, entry.File, entry.Line, want.file, want.line)
+		}
+	}
+}
+
+func TestLoadSymlinkConfigs_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		contains string
+	}{
+		{
+			name: "Cycle",
+			files: map[string]string{
+				"symlinks.yaml": "- include: a.yaml\n",
+				"a.yaml":        "- include: b.yaml\n",
+				"b.yaml":        "- common: {}\n- include: a.yaml\n",
+			},
+			contains: "b.yaml:2:12: error: include cycle",
+		},
+		{
+			name: "Including the top level file",
+			files: map[string]string{
+				"symlinks.yaml": "- include: symlinks.yaml\n",
+			},
+			contains: "symlinks.yaml:1:12: error: include cycle",
+		},
+		{
+			name: "Duplicate include",
+			files: map[string]string{
+				"symlinks.yaml": "- include: a.yaml\n- include: '*.yml'\n",
+				"a.yaml":        "- common: {}\n",
+				"a.yml":         "- include: a.yaml\n",
+			},
+			contains: "a.yaml is already included from",
+		},
+		{
+			name: "Missing include",
+			files: map[string]string{
+				"symlinks.yaml": "- common: {}\n- include: missing.yaml\n",
+			},
+			contains: "symlinks.yaml:2:12: error: included file",
+		},
+		{
+			name: "Include combined with other keys",
+			files: map[string]string{
+				"symlinks.yaml": "- include: a.yaml\n  common: {}\n",
+				"a.yaml":        "- common: {}\n",
+			},
+			contains: "symlinks.yaml:1:3: error: include cannot be combined",
+		},
+		{
+			name: "Parse error in included file",
+			files: map[string]string{
+				"symlinks.yaml": "- include: a.yaml\n",
+				"a.yaml":        "- common:\n    ~/.vimrc: [unclosed\n",
+			},
+			contains: "a.yaml:1: error: syntax error:",
+		},
+		{
+			name: "Invalid entry",
+			files: map[string]string{
+				"symlinks.yaml": "- common: {}\n- common: not-a-map\n",
+			},
+			contains: `symlinks.yaml:2:11: error: "common" must be a mapping, not a string`,
+		},
+		{
+			name: "Entry that is not a mapping",
+			files: map[string]string{
+				"symlinks.yaml": "- common: {}\n- ~/.vimrc\n",
+			},
+			contains: "symlinks.yaml:2:3: error: an entry must be a mapping, not a string",
+		},
+		{
+			name: "Bad OS name",
+			files: map[string]string{
+				"symlinks.yaml": "- os: macos\n  link:\n    ~/.vimrc: vimrc\n",
+			},
+			contains: `symlinks.yaml:1:7: error: "os" must be one of linux, darwin, windows, not "macos"`,
+		},
+		{
+			name: "Empty source",
+			files: map[string]string{
+				"symlinks.yaml": "- common:\n    ~/.vimrc: vimrc\n    ~/.bashrc:\n",
+			},
+			contains: "symlinks.yaml:3:5: error: empty source for target ~/.bashrc",
+		},
+		{
+			name: "Empty structured source",
+			files: map[string]string{
+				"symlinks.yaml": "- links:\n    - target: ~/.vimrc\n      source: ''\n",
+			},
+			contains: "symlinks.yaml:3:15: error: empty source for target ~/.vimrc",
+		},
+		{
+			name: "Missing structured source",
+			files: map[string]string{
+				"symlinks.yaml": "- links:\n    - target: ~/.vimrc\n",
+			},
+			contains: `symlinks.yaml:2:7: error: "links[0].source" is required`,
+		},
+		{
+			name: "Non-string value",
+			files: map[string]string{
+				"symlinks.yaml": "- links:\n    - target: ~/.vimrc\n      source: [vimrc]\n",
+			},
+			contains: `symlinks.yaml:3:15: error: "links[0].source" must be a string, not a list`,
+		},
+		{
+			name: "Wrong type in a list",
+			files: map[string]string{
+				"symlinks.yaml": "- wsl: maybe\n  after: {name: shell}\n",
+			},
+			contains: `symlinks.yaml:2:10: error: "after" must be a string or a list, not a mapping`,
+		},
+		{
+			name: "Not a list",
+			files: map[string]string{
+				"symlinks.yaml": "common: {}\n",
+			},
+			contains: "symlinks.yaml:1:1: error: expected a list of entries",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			writeFiles(t, tempDir, tt.files)
+
+			_, err := sokru.LoadSymlinkConfigs(filepath.Join(tempDir, "symlinks.yaml"))
+			if err == nil {
+				t.Fatal("LoadSymlinkConfigs() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Error %q does not contain %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestLoadSymlinkConfigs_ReportsEveryError(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"symlinks.yaml": "- wsl: maybe\n- os: macos\n- links:\n    - target: ~/.vimrc\n      source: vimrc\n      mode: link\n",
+	})
+	symlinkFile := filepath.Join(tempDir, "symlinks.yaml")
+
+	_, err := sokru.LoadSymlinkConfigs(symlinkFile)
+	var issues sokru.Issues
+	if !errors.As(err, &issues) {
+		t.Fatalf("LoadSymlinkConfigs() error = %v, want sokru.Issues", err)
+	}
+
+	expected := []string{
+		symlinkFile + `:1:8: error: "wsl" must be true or false, not a string`,
+		symlinkFile + `:2:7: error: "os" must be one of linux, darwin, windows, not "macos"`,
+		symlinkFile + `:6:13: error: "links[0].mode" must be one of symlink, copy, hardlink, not "link"`,
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got:\n%v", len(expected), err)
+	}
+	for i, want := range expected {
+		if got := issues[i].String(); got != want {
+			t.Errorf("Issue %d = %q, want %q", i, got, want)
+		}
+	}
+
+	// Issues are rendered in the current language
+	i18n.SetLanguage(i18n.Spanish)
+	defer i18n.SetLanguage(i18n.English)
+	_, err = sokru.LoadSymlinkConfigs(symlinkFile)
+	if want := `:2:7: error: "os" debe ser uno de linux, darwin, windows, no "macos"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("Error %q does not contain %q", err, want)
+	}
+}
@@ -0,0 +1,222 @@
+// Package test
+// Description: Unit tests for path expansion
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/paths"
+)
+
+// newTestExpander returns an expander with a fixed environment
+func newTestExpander(env map[string]string, windows bool) *paths.Expander {
+	return &paths.Expander{
+		LookupEnv: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		},
+		HomeDir: func() (string, error) {
+			return "/home/me", nil
+		},
+		UserHomeDir: func(name string) (string, error) {
+			if name == "other" {
+				return "/home/other", nil
+			}
+			return "", fmt.Errorf("unknown user %s", name)
+		},
+		Windows: windows,
+	}
+}
+
+func TestExpand(t *testing.T) {
+	env := map[string]string{
+		"WORK":            "/work",
+		"EMPTY":           "",
+		"XDG_DATA_HOME":   "/data",
+		"APPDATA":         `C:\Users\me\AppData\Roaming`,
+		"XDG_CONFIG_HOME": "",
+	}
+	expander := newTestExpander(env, false)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain path", "/usr/local/bin", "/usr/local/bin"},
+		{"Just tilde", "~", "/home/me"},
+		{"Tilde path", "~/dotfiles", "/home/me/dotfiles"},
+		{"Other user", "~other/.vimrc", "/home/other/.vimrc"},
+		{"Dollar variable", "$WORK/file", "/work/file"},
+		{"Braced variable", "${WORK}/file", "/work/file"},
+		{"HOME variable", "$HOME/.bashrc", "/home/me/.bashrc"},
+		{"Default used when unset", "${MISSING:-/fallback}/x", "/fallback/x"},
+		{"Default used when empty", "${EMPTY:-/fallback}", "/fallback"},
+		{"Default with tilde", "${MISSING:-~/.config}/nvim", "/home/me/.config/nvim"},
+		{"Default ignored when set", "${WORK:-/fallback}", "/work"},
+		{"XDG from environment", "$XDG_DATA_HOME/app", "/data/app"},
+		{"XDG built-in default", "${XDG_CONFIG_HOME}/git", filepath.Join("/home/me", ".config", "git")},
+		{"XDG state default", "$XDG_STATE_HOME", filepath.Join("/home/me", ".local", "state")},
+		{"Escaped dollar", "/price/$$5", "/price/$5"},
+		{"Lone dollar", "/a/$/b", "/a/$/b"},
+		{"Percent ignored on unix", "%APPDATA%/x", "%APPDATA%/x"},
+		{"Empty path", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := expander.Expand(tt.input)
+			if err != nil {
+				t.Fatalf("Expand(%q) error = %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expand(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandWindows(t *testing.T) {
+	expander := newTestExpander(map[string]string{"APPDATA": `C:\AppData`}, true)
+
+	result, err := expander.Expand(`%APPDATA%\Code`)
+	if err != nil {
+		t.Fatalf("Expand error = %v", err)
+	}
+	if result != `C:\AppData\Code` {
+		t.Errorf("Expand = %q, want %q", result, `C:\AppData\Code`)
+	}
+
+	// Unterminated percent is kept literally
+	result, err = expander.Expand(`100%`)
+	if err != nil {
+		t.Fatalf("Expand error = %v", err)
+	}
+	if result != `100%` {
+		t.Errorf("Expand = %q, want %q", result, `100%`)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	expander := newTestExpander(map[string]string{}, true)
+
+	tests := []struct {
+		name      string
+		input     string
+		undefined bool
+	}{
+		{"Undefined dollar variable", "$NOPE/file", true},
+		{"Undefined braced variable", "${NOPE}/file", true},
+		{"Undefined percent variable", "%NOPE%/file", true},
+		{"Unterminated brace", "${NOPE/file", false},
+		{"Invalid name", "${1ABC}", false},
+		{"Unknown user", "~nobody/file", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expander.Expand(tt.input)
+			if err == nil {
+				t.Fatalf("Expand(%q) expected error", tt.input)
+			}
+			if tt.undefined && !errors.Is(err, paths.ErrUndefinedVariable) {
+				t.Errorf("Expand(%q) error = %v, want ErrUndefinedVariable", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestAppDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	getters := map[string]func(string) (string, error){
+		"config": paths.ConfigDir,
+		"state":  paths.StateDir,
+		"data":   paths.DataDir,
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		homeDir string
+		want    map[string]string
+	}{
+		{
+			name: "spec defaults",
+			env:  map[string]string{"XDG_CONFIG_HOME": "", "XDG_STATE_HOME": "", "XDG_DATA_HOME": ""},
+			want: map[string]string{
+				"config": filepath.Join(home, ".config", "sokru"),
+				"state":  filepath.Join(home, ".local", "state", "sokru"),
+				"data":   filepath.Join(home, ".local", "share", "sokru"),
+			},
+		},
+		{
+			name: "variables",
+			env:  map[string]string{"XDG_CONFIG_HOME": "/xdg/config", "XDG_STATE_HOME": "/xdg/state", "XDG_DATA_HOME": "relative/data"},
+			want: map[string]string{
+				"config": filepath.Join("/xdg/config", "sokru"),
+				"state":  filepath.Join("/xdg/state", "sokru"),
+				"data":   filepath.Join(home, ".local", "share", "sokru"),
+			},
+		},
+		{
+			name:    "home overrides the variables",
+			env:     map[string]string{"XDG_CONFIG_HOME": "/xdg/config"},
+			homeDir: "/sandbox",
+			want:    map[string]string{"config": "/sandbox", "state": "/sandbox", "data": "/sandbox"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			for name, get := range getters {
+				got, err := get(tt.homeDir)
+				if err != nil {
+					t.Fatalf("%s dir error = %v", name, err)
+				}
+				if got != tt.want[name] {
+					t.Errorf("%s dir = %s, want %s", name, got, tt.want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dataDir := filepath.Join(home, ".local", "share", "sokru")
+	legacyDir := filepath.Join(home, ".config", "sokru")
+
+	if got := paths.Locate("", dataDir, "backups"); got != filepath.Join(dataDir, "backups") {
+		t.Errorf("Locate() without files = %s, want the new location", got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(legacyDir, "backups"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths.Locate("", dataDir, "backups"); got != filepath.Join(legacyDir, "backups") {
+		t.Errorf("Locate() with legacy files = %s, want the legacy location", got)
+	}
+
+	if got := paths.Locate(dataDir, dataDir, "backups"); got != filepath.Join(dataDir, "backups") {
+		t.Errorf("Locate() with a home = %s, want the home", got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dataDir, "backups"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths.Locate("", dataDir, "backups"); got != filepath.Join(dataDir, "backups") {
+		t.Errorf("Locate() with both = %s, want the new location", got)
+	}
+}
@@ -0,0 +1,195 @@
+// Package test
+// Description: Unit tests for the order links are processed in
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/platform"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// planTargets returns the targets of the planned links in order
+func planTargets(t *testing.T, configs []sokru.SymlinkConfig) []string {
+	t.Helper()
+
+	plan, err := sokru.PlanLinks(configs, sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}})
+	if err != nil {
+		t.Fatalf("PlanLinks() error = %v", err)
+	}
+
+	targets := make([]string, len(plan))
+	for i, link := range plan {
+		targets[i] = link.Target
+	}
+	return targets
+}
+
+func TestPlanLinks_Order(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []sokru.SymlinkConfig
+		want    []string
+	}{
+		{
+			name: "parents before children",
+			configs: []sokru.SymlinkConfig{
+				{Link: map[string]string{
+					"/tmp/plan/.config/nvim/init.lua": "/dots/init.lua",
+					"/tmp/plan/.config-old":           "/dots/old",
+					"/tmp/plan/.config":               "/dots/config",
+					"/tmp/plan/.config/nvim":          "/dots/nvim",
+				}},
+			},
+			want: []string{
+				"/tmp/plan/.config",
+				"/tmp/plan/.config/nvim",
+				"/tmp/plan/.config/nvim/init.lua",
+				"/tmp/plan/.config-old",
+			},
+		},
+		{
+			name: "entries after their dependencies",
+			configs: []sokru.SymlinkConfig{
+				{Name: "plugins", After: sokru.StringList{"editor"}, Link: map[string]string{"/tmp/plan/a": "/dots/a"}},
+				{Name: "editor", After: sokru.StringList{"base"}, Link: map[string]string{"/tmp/plan/b": "/dots/b"}},
+				{Name: "base", Link: map[string]string{"/tmp/plan/c": "/dots/c"}},
+			},
+			want: []string{"/tmp/plan/c", "/tmp/plan/b", "/tmp/plan/a"},
+		},
+		{
+			name: "unknown dependencies are ignored",
+			configs: []sokru.SymlinkConfig{
+				{Name: "shell", After: sokru.StringList{"work"}, Link: map[string]string{"/tmp/plan/b": "/dots/b"}},
+				{Link: map[string]string{"/tmp/plan/a": "/dots/a"}},
+			},
+			want: []string{"/tmp/plan/a", "/tmp/plan/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planTargets(t, tt.configs)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("PlanLinks() targets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanLinks_LaterEntryWins(t *testing.T) {
+	configs := []sokru.SymlinkConfig{
+		{Name: "override", After: sokru.StringList{"base"}, Link: map[string]string{"/tmp/plan/.bashrc": "/dots/bashrc-override"}},
+		{Name: "base", Link: map[string]string{"/tmp/plan/.bashrc": "/dots/bashrc"}},
+	}
+
+	plan, err := sokru.PlanLinks(configs, sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}})
+	if err != nil {
+		t.Fatalf("PlanLinks() error = %v", err)
+	}
+	if len(plan) != 1 || plan[0].Source != "/dots/bashrc-override" {
+		t.Errorf("PlanLinks() = %v, want the link of the entry processed last", plan)
+	}
+}
+
+func TestPlanLinks_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []sokru.SymlinkConfig
+		want    string
+	}{
+		{
+			name: "dependency cycle",
+			configs: []sokru.SymlinkConfig{
+				{Name: "a", After: sokru.StringList{"b"}},
+				{Name: "b", After: sokru.StringList{"c"}},
+				{Name: "c", After: sokru.StringList{"a"}},
+			},
+			want: "error: dependency cycle between entries: a -> b -> c -> a",
+		},
+		{
+			name: "duplicate name",
+			configs: []sokru.SymlinkConfig{
+				{Name: "shell", File: "symlinks.yaml", Line: 1},
+				{Name: "shell", File: "symlinks.yaml", Line: 4},
+			},
+			want: `symlinks.yaml:4: error: entry name "shell" is already used at symlinks.yaml:1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sokru.PlanLinks(tt.configs, sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}})
+			if err == nil {
+				t.Fatal("PlanLinks() expected an error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("PlanLinks() error = %q, want %q", err, tt.want)
+			}
+
+			var issue sokru.Issue
+			if !errors.As(err, &issue) {
+				t.Errorf("PlanLinks() error = %T, want sokru.Issue", err)
+			}
+		})
+	}
+}
+
+func TestCheckOverlaps(t *testing.T) {
+	dotfilesDir := t.TempDir()
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dotfilesDir, "nvim"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A directory that is already linked into the dotfiles
+	if err := os.Symlink(filepath.Join(dotfilesDir, "nvim"), filepath.Join(home, "nvim")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		plan []sokru.Link
+		want []string
+	}{
+		{
+			name: "separate targets",
+			plan: []sokru.Link{
+				{Target: "/tmp/overlap/.config", Source: "/dots/config"},
+				{Target: "/tmp/overlap/.config-old/init.lua", Source: "/dots/init.lua"},
+			},
+		},
+		{
+			name: "nested under another target",
+			plan: []sokru.Link{
+				{Target: "/tmp/overlap/.config/nvim/init.lua", Source: "/dots/init.lua", File: "b.yaml", Line: 3},
+				{Target: "/tmp/overlap/.config/nvim", Source: "/dots/nvim", File: "a.yaml", Line: 2},
+			},
+			want: []string{"b.yaml:3: error: target /tmp/overlap/.config/nvim/init.lua is nested under /tmp/overlap/.config/nvim, managed at a.yaml:2"},
+		},
+		{
+			name: "parent resolves into the dotfiles",
+			plan: []sokru.Link{
+				{Target: filepath.Join(home, "nvim", "lua", "init.lua"), Source: "/dots/init.lua", File: "a.yaml", Line: 1},
+			},
+			want: []string{"a.yaml:1: error: target " + filepath.Join(home, "nvim", "lua", "init.lua") + " resolves into the dotfiles directory as " + filepath.Join(dotfilesDir, "nvim", "lua", "init.lua")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range sokru.CheckOverlaps(nil, tt.plan, dotfilesDir) {
+				got = append(got, issue.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("CheckOverlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package test
+// Description: Unit tests for platform detection and host matching
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/platform"
+)
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		hostname string
+		expected bool
+	}{
+		{"Exact name", "laptop", "laptop", true},
+		{"Different name", "laptop", "desktop", false},
+		{"Glob", "build-*", "build-01", true},
+		{"Glob on fully qualified name", "build-??", "build-01.example.com", true},
+		{"Case insensitive", "Build-*", "BUILD-01", true},
+		{"Glob not matching", "build-*", "web-01", false},
+		{"Character class", "web-[12]", "web-2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := platform.MatchHost(tt.pattern, tt.hostname)
+			if err != nil {
+				t.Fatalf("MatchHost() error = %v", err)
+			}
+			if matched != tt.expected {
+				t.Errorf("MatchHost(%q, %q) = %v, want %v", tt.pattern, tt.hostname, matched, tt.expected)
+			}
+		})
+	}
+
+	if _, err := platform.MatchHost("build-[", "build-1"); err == nil {
+		t.Error("MatchHost() expected error for invalid pattern")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if info := platform.Detect(""); info.OS != runtime.GOOS {
+		t.Errorf("Detect() OS = %q, want %q", info.OS, runtime.GOOS)
+	}
+	if info := platform.Detect("windows"); info.OS != "windows" {
+		t.Errorf("Detect() should keep the configured OS, got %q", info.OS)
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	content := `# Ubuntu
+NAME="Ubuntu"
+ID=ubuntu
+ID_LIKE=debian
+VERSION_ID='24.04'
+
+INVALID LINE
+`
+
+	expected := map[string]string{
+		"NAME":       "Ubuntu",
+		"ID":         "ubuntu",
+		"ID_LIKE":    "debian",
+		"VERSION_ID": "24.04",
+	}
+
+	if result := platform.ParseOSRelease(strings.NewReader(content)); !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseOSRelease() = %v, want %v", result, expected)
+	}
+}
+
+func TestNormalizeArch(t *testing.T) {
+	tests := map[string]string{
+		"x86_64":  "amd64",
+		"AARCH64": "arm64",
+		"arm64":   "arm64",
+		"riscv64": "riscv64",
+	}
+
+	for arch, expected := range tests {
+		if result := platform.NormalizeArch(arch); result != expected {
+			t.Errorf("NormalizeArch(%q) = %q, want %q", arch, result, expected)
+		}
+	}
+}
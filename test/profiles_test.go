@@ -0,0 +1,140 @@
+// Package test
+// Description: Unit tests for profile selection
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+func profileConfigs() []sokru.SymlinkConfig {
+	return []sokru.SymlinkConfig{
+		{
+			Profiles: map[string]sokru.ProfileSpec{
+				"minimal":  {},
+				"work":     {Extends: []string{"minimal"}},
+				"personal": {Extends: []string{"minimal"}},
+				"laptop":   {Extends: []string{"work", "personal"}},
+			},
+		},
+		{Common: map[string]string{"/home/me/.bashrc": "/bashrc"}},
+		{Profile: "minimal", Common: map[string]string{"/home/me/.vimrc": "/vimrc"}},
+		{Profile: "work", Common: map[string]string{"/home/me/.ssh/config": "/ssh-work"}},
+		{Profile: "personal", Common: map[string]string{"/home/me/.gnupg/gpg.conf": "/gpg"}},
+		{Profile: "gaming", Common: map[string]string{"/home/me/.steam": "/steam"}},
+	}
+}
+
+func TestProfileChain(t *testing.T) {
+	profiles, err := sokru.CollectProfiles(profileConfigs())
+	if err != nil {
+		t.Fatalf("CollectProfiles() error = %v", err)
+	}
+
+	tests := []struct {
+		profile  string
+		expected []string
+	}{
+		{"minimal", []string{"minimal"}},
+		{"work", []string{"work", "minimal"}},
+		{"laptop", []string{"laptop", "work", "minimal", "personal"}},
+		{"gaming", []string{"gaming"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			chain, err := sokru.ProfileChain(profiles, tt.profile)
+			if err != nil {
+				t.Fatalf("ProfileChain() error = %v", err)
+			}
+			if !reflect.DeepEqual(chain, tt.expected) {
+				t.Errorf("ProfileChain() = %v, want %v", chain, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProfileChainErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]sokru.ProfileSpec
+		profile  string
+	}{
+		{
+			name:     "Unknown profile",
+			profiles: map[string]sokru.ProfileSpec{"work": {}},
+			profile:  "home",
+		},
+		{
+			name:     "Unknown parent",
+			profiles: map[string]sokru.ProfileSpec{"work": {Extends: []string{"base"}}},
+			profile:  "work",
+		},
+		{
+			name: "Cycle",
+			profiles: map[string]sokru.ProfileSpec{
+				"a": {Extends: []string{"b"}},
+				"b": {Extends: []string{"a"}},
+			},
+			profile: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sokru.ProfileChain(tt.profiles, tt.profile); err == nil {
+				t.Error("ProfileChain() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCollectProfilesDuplicate(t *testing.T) {
+	configs := []sokru.SymlinkConfig{
+		{Profiles: map[string]sokru.ProfileSpec{"work": {}}},
+		{Profiles: map[string]sokru.ProfileSpec{"work": {}}},
+	}
+
+	if _, err := sokru.CollectProfiles(configs); err == nil {
+		t.Error("CollectProfiles() expected error for duplicate profile")
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	tests := []struct {
+		profile  string
+		expected []string
+	}{
+		{"", []string{"/home/me/.bashrc"}},
+		{"minimal", []string{"/home/me/.bashrc", "/home/me/.vimrc"}},
+		{"work", []string{"/home/me/.bashrc", "/home/me/.vimrc", "/home/me/.ssh/config"}},
+		{"laptop", []string{"/home/me/.bashrc", "/home/me/.vimrc", "/home/me/.ssh/config", "/home/me/.gnupg/gpg.conf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			selected, err := sokru.SelectProfile(profileConfigs(), tt.profile)
+			if err != nil {
+				t.Fatalf("SelectProfile() error = %v", err)
+			}
+
+			var targets []string
+			for _, entry := range selected {
+				for target := range entry.GetLinksForOS("linux") {
+					targets = append(targets, target)
+				}
+			}
+			if !reflect.DeepEqual(targets, tt.expected) {
+				t.Errorf("SelectProfile() targets = %v, want %v", targets, tt.expected)
+			}
+		})
+	}
+
+	if _, err := sokru.SelectProfile(profileConfigs(), "unknown"); err == nil {
+		t.Error("SelectProfile() expected error for unknown profile")
+	}
+}
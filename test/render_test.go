@@ -0,0 +1,103 @@
+// Package test
+// Description: Unit tests for template rendering
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/render"
+)
+
+func TestRender(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-render-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatePath := filepath.Join(tempDir, "gitconfig.tmpl")
+	content := "[user]\n  email = {{ .Vars.email }}\n# {{ .OS }} on {{ .Hostname }}\n"
+	if err := os.WriteFile(templatePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	data := render.Data{Hostname: "box", OS: "linux"}.WithVars(map[string]string{"email": "me@example.com"})
+
+	result, err := render.Render(templatePath, data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := "[user]\n  email = me@example.com\n# linux on box\n"
+	if string(result) != expected {
+		t.Errorf("Render = %q, want %q", result, expected)
+	}
+}
+
+func TestRenderMissingVariable(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-render-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatePath := filepath.Join(tempDir, "broken.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{ .Vars.missing }}"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	if _, err := render.Render(templatePath, render.Data{}); err == nil {
+		t.Error("Render should fail for a missing variable")
+	}
+}
+
+func TestNewDataWithVars(t *testing.T) {
+	base := render.NewData("darwin", map[string]string{"a": "1", "b": "2"})
+	if base.OS != "darwin" {
+		t.Errorf("OS = %q, want darwin", base.OS)
+	}
+
+	layered := base.WithVars(map[string]string{"b": "override"})
+	if layered.Vars["a"] != "1" || layered.Vars["b"] != "override" {
+		t.Errorf("Unexpected layered vars: %v", layered.Vars)
+	}
+	if base.Vars["b"] != "2" {
+		t.Error("WithVars should not modify the original data")
+	}
+}
+
+func TestRenderedPath(t *testing.T) {
+	a := render.RenderedPath("/rendered", "/home/a/.gitconfig")
+	b := render.RenderedPath("/rendered", "/home/b/.gitconfig")
+
+	if a == b {
+		t.Error("Different targets should have different rendered paths")
+	}
+	if !strings.HasPrefix(filepath.Base(a), ".gitconfig-") {
+		t.Errorf("Rendered path should keep the target name, got %q", a)
+	}
+	if a != render.RenderedPath("/rendered", "/home/a/.gitconfig") {
+		t.Error("Rendered path should be stable")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if diff := render.Diff([]byte("same\n"), []byte("same\n")); diff != "" {
+		t.Errorf("Diff of equal content should be empty, got %q", diff)
+	}
+
+	diff := render.Diff([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd\n"))
+	for _, line := range []string{"- b", "+ B", "+ d"} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("Diff should contain %q, got:\n%s", line, diff)
+		}
+	}
+	if strings.Contains(diff, "- a") || strings.Contains(diff, "+ a") {
+		t.Errorf("Diff should not contain unchanged lines, got:\n%s", diff)
+	}
+}
@@ -258,6 +258,78 @@ func TestRollbackUpdatedSymlink(t *testing.T) {
 	}
 }
 
+func TestRollbackWrittenFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-rollback-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	existingPath := filepath.Join(tempDir, "existing")
+	newPath := filepath.Join(tempDir, "new")
+
+	// Overwrite an existing file and create a new one
+	if err := os.WriteFile(existingPath, []byte("after"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(newPath, []byte("created"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tracker := rollback.NewTracker()
+	tracker.TrackWritten(existingPath, []byte("before"), 0644, true)
+	tracker.TrackWritten(newPath, nil, 0, false)
+
+	if err := tracker.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	content, err := os.ReadFile(existingPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(content) != "before" {
+		t.Errorf("Expected previous content 'before', got '%s'", content)
+	}
+
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Error("New file should be removed after rollback")
+	}
+}
+
+func TestRollbackWrittenKeepsMode(t *testing.T) {
+	tests := []struct {
+		name string
+		perm os.FileMode
+	}{
+		{name: "executable", perm: 0755},
+		{name: "private", perm: 0600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte("after"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			tracker := rollback.NewTracker()
+			tracker.TrackWritten(path, []byte("before"), tt.perm, true)
+			if err := tracker.Rollback(); err != nil {
+				t.Fatalf("Rollback failed: %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.perm {
+				t.Errorf("mode after rollback = %v, want %v", info.Mode().Perm(), tt.perm)
+			}
+		})
+	}
+}
+
 func TestRollbackMultipleActions(t *testing.T) {
 	// Create temp directory
 	tempDir, err := os.MkdirTemp("", "sokru-rollback-test-*")
@@ -0,0 +1,270 @@
+// Package test
+// Description: Unit tests for atomic writes and the lock sok processes share
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexlm78/sokru/internal/safefile"
+)
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     string
+		existingPerm os.FileMode
+		data         string
+		perm         os.FileMode
+	}{
+		{name: "new file", data: "version: 2\n", perm: 0644},
+		{name: "replaces existing file", existing: "a much longer old content\n", data: "new\n", perm: 0644},
+		{name: "private file", data: "secret\n", perm: 0600},
+		{name: "keeps the mode of an existing file", existing: "secret\n", existingPerm: 0600, data: "new\n", perm: 0644},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.yaml")
+			wantPerm := tt.perm
+			if tt.existing != "" {
+				if tt.existingPerm != 0 {
+					wantPerm = tt.existingPerm
+				}
+				if err := os.WriteFile(path, []byte(tt.existing), wantPerm); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+
+			if err := safefile.WriteFile(path, []byte(tt.data), tt.perm); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if string(data) != tt.data {
+				t.Errorf("Content = %q, want %q", data, tt.data)
+			}
+
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("Failed to stat file: %v", err)
+				}
+				if info.Mode().Perm() != wantPerm {
+					t.Errorf("Mode = %v, want %v", info.Mode().Perm(), wantPerm)
+				}
+			}
+
+			// No temporary file is left behind
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("Failed to read dir: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("Expected only config.yaml in the directory, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteFileThroughSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	// The config is kept in the dotfiles repository and linked from its place
+	repo := filepath.Join(t.TempDir(), "dotfiles")
+	configDir := t.TempDir()
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(repo, "config.yaml")
+	if err := os.WriteFile(target, []byte("version: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(configDir, "config.yaml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := safefile.WriteFile(link, []byte("version: 2\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if got, err := os.Readlink(link); err != nil || got != target {
+		t.Errorf("Link points to %q (%v), want %q", got, err, target)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "version: 2\n" {
+		t.Errorf("Repository copy = %q (%v), want the new content", data, err)
+	}
+	if info, err := os.Stat(target); err != nil {
+		t.Errorf("Failed to stat the repository copy: %v", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Repository copy mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// The temporary file is created next to the repository copy
+	for _, dir := range []string{repo, configDir} {
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+			t.Errorf("%s has %d entries (%v), want only config.yaml", dir, len(entries), err)
+		}
+	}
+}
+
+func TestWriteFileMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "config.yaml")
+
+	if err := safefile.WriteFile(path, []byte("x"), 0644); err == nil {
+		t.Error("Expected an error writing into a missing directory")
+	}
+}
+
+func TestLockExcludesGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sok.lock")
+
+	first, err := safefile.Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := safefile.TryLock(path); !errors.Is(err, safefile.ErrLocked) {
+		t.Fatalf("TryLock() while held in the same process error = %v, want ErrLocked", err)
+	}
+
+	// Another goroutine waits until the lock is released
+	acquired := make(chan *safefile.Lock)
+	go func() {
+		lock, err := safefile.Acquire(path)
+		if err != nil {
+			t.Errorf("Acquire() in another goroutine error = %v", err)
+		}
+		acquired <- lock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() in another goroutine returned while the lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := first.Release(); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+	second := <-acquired
+	if second == nil {
+		t.FailNow()
+	}
+	if err := second.Release(); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+
+	// Releasing twice is harmless
+	if err := first.Release(); err != nil {
+		t.Errorf("Second Release() error = %v", err)
+	}
+	if lock, err := safefile.TryLock(path); err != nil {
+		t.Errorf("TryLock() after Release() error = %v", err)
+	} else {
+		lock.Release()
+	}
+}
+
+func TestLockSerializesGoroutines(t *testing.T) {
+	// Goroutines reading, changing and writing a file under the lock never
+	// lose an update
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sok.lock")
+	counter := filepath.Join(dir, "counter")
+	if err := os.WriteFile(counter, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				lock, err := safefile.Acquire(path)
+				if err != nil {
+					t.Errorf("Acquire() error = %v", err)
+					return
+				}
+				data, _ := os.ReadFile(counter)
+				n, _ := strconv.Atoi(string(data))
+				if err := safefile.WriteFile(counter, []byte(strconv.Itoa(n+1)), 0644); err != nil {
+					t.Errorf("WriteFile() error = %v", err)
+				}
+				lock.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if data, _ := os.ReadFile(counter); string(data) != "80" {
+		t.Errorf("counter = %s, want 80", data)
+	}
+}
+
+// TestLockHelperProcess tries to take the lock of SOKRU_TEST_LOCK from another
+// process, for TestLockExcludesOtherProcesses
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv("SOKRU_TEST_LOCK")
+	if path == "" {
+		t.Skip("only run by TestLockExcludesOtherProcesses")
+	}
+
+	lock, err := safefile.TryLock(path)
+	if errors.Is(err, safefile.ErrLocked) {
+		os.Exit(3)
+	}
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestLockExcludesOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sok.lock")
+
+	tryFromOtherProcess := func() int {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+		cmd.Env = append(os.Environ(), "SOKRU_TEST_LOCK="+path)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		if err != nil {
+			t.Fatalf("Failed to run helper process: %v", err)
+		}
+		return 0
+	}
+
+	lock, err := safefile.Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if code := tryFromOtherProcess(); code != 3 {
+		t.Errorf("Other process exited with %d while the lock was held, want 3 (locked)", code)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if code := tryFromOtherProcess(); code != 0 {
+		t.Errorf("Other process exited with %d after the lock was released, want 0", code)
+	}
+}
@@ -0,0 +1,183 @@
+// Package test
+// Description: Unit tests for the JSON Schema generation
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/schema"
+	"github.com/alexlm78/sokru/pkg/sokru"
+	"gopkg.in/yaml.v3"
+)
+
+type schemaSample struct {
+	Name    string            `yaml:"name" schema:"required"`
+	Mode    string            `yaml:"mode,omitempty" schema:"enum=a|b"`
+	Enabled *bool             `yaml:"enabled,omitempty"`
+	Count   int               `yaml:"count"`
+	Tags    []string          `yaml:"tags"`
+	Vars    map[string]string `yaml:"vars"`
+	Plain   string
+	Skipped string `yaml:"-"`
+	hidden  string
+}
+
+func TestSchemaFor(t *testing.T) {
+	got, err := json.Marshal(schema.For(reflect.TypeOf(schemaSample{})))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"type":"object","properties":{` +
+		`"count":{"type":"integer"},` +
+		`"enabled":{"type":"boolean"},` +
+		`"mode":{"type":"string","enum":["a","b"]},` +
+		`"name":{"type":"string"},` +
+		`"plain":{"type":"string"},` +
+		`"tags":{"type":"array","items":{"type":"string"}},` +
+		`"vars":{"type":"object","additionalProperties":{"type":"string"}}},` +
+		`"required":["name"],"additionalProperties":false}`
+	if string(got) != want {
+		t.Errorf("For() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSchemaProperties(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *schema.Schema
+		want   string
+	}{
+		{
+			name:   "symlinks entries",
+			schema: sokru.SymlinksSchema().OneOf[0].Items,
+			want:   "after,arch,common,darwin,desktop,distro,hosts,include,link,links,linux,name,os,profile,profiles,when,windows,wsl",
+		},
+		{
+			name:   "structured links",
+			schema: sokru.SymlinksSchema().OneOf[0].Items.Properties["links"].Items,
+			want:   "exclude,kind,mode,source,target,vars,when",
+		},
+		{
+			name:   "config",
+			schema: sokru.ConfigSchema(),
+			want:   "backup,dotfiles_dir,dry_run,language,os,profile,symlinks_file,variables,verbose,version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for name := range tt.schema.Properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("properties = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymlinksSchemaDocument(t *testing.T) {
+	s := sokru.SymlinksSchema()
+
+	if s.Schema != schema.Draft || len(s.OneOf) != 2 || s.OneOf[0].Type != "array" {
+		t.Fatalf("SymlinksSchema() = %s %+v, want a %s array or entries object", s.Schema, s.OneOf, schema.Draft)
+	}
+	if entries := s.OneOf[1].Properties["entries"]; entries != s.OneOf[0] {
+		t.Errorf("entries schema = %+v, want the array of entries", entries)
+	}
+	if include := s.OneOf[0].Items.Properties["include"]; len(include.OneOf) != 2 {
+		t.Errorf("include schema = %+v, want a string or a list of strings", include)
+	}
+	if links := s.OneOf[0].Items.Properties["links"].Items; strings.Join(links.Required, ",") != "target,source" {
+		t.Errorf("links required = %v, want [target source]", links.Required)
+	}
+}
+
+func TestSymlinksSchemaShapes(t *testing.T) {
+	// The loader accepts the entries as a list, or in an entries key
+	tests := []struct {
+		name     string
+		document string
+		valid    bool
+	}{
+		{name: "list", document: "- common: {~/.vimrc: vimrc}\n", valid: true},
+		{name: "entries key", document: "entries:\n  - common: {~/.vimrc: vimrc}\n", valid: true},
+		{name: "other key", document: "links:\n  - common: {~/.vimrc: vimrc}\n"},
+		{name: "invalid entry", document: "entries:\n  - common: [vimrc]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.document), &doc); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			violations := schema.Check(doc.Content[0], sokru.SymlinksSchema())
+			if (len(violations) == 0) != tt.valid {
+				t.Errorf("Check() = %+v, want valid %v", violations, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	s := schema.For(reflect.TypeOf(schemaSample{}))
+
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{
+		{
+			name:     "valid",
+			document: "name: a\nmode: b\nenabled: true\ncount: 3\ntags: [x, y]\nvars: {k: v}\nunknown: [ignored]\n",
+		},
+		{
+			name:     "null values match any type",
+			document: "name: a\nmode:\ntags:\n",
+		},
+		{
+			name:     "violations",
+			document: "mode: c\nenabled: yes\ncount: many\ntags: [x, [y]]\nvars: {k: {v: w}}\n",
+			want: []string{
+				"1:7 mode enum [a b] got c",
+				"2:10 enabled type [boolean] got string",
+				"3:8 count type [integer] got string",
+				"4:11 tags[1] type [string] got array",
+				"5:11 vars.k type [string] got object",
+				"1:1 name missing [name] got ",
+			},
+		},
+	}
+
+	problems := map[schema.Problem]string{schema.WrongType: "type", schema.NotAllowed: "enum", schema.Missing: "missing"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.document), &doc); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			var got []string
+			for _, v := range schema.Check(doc.Content[0], s) {
+				got = append(got, fmt.Sprintf("%d:%d %s %s %v got %s", v.Node.Line, v.Node.Column, v.Path, problems[v.Problem], v.Expected, v.Got))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Check() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,560 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// setupDotfiles creates a dotfiles repository in a temporary home with the
+// given symlinks file and sources, and returns a configuration using it
+func setupDotfiles(t *testing.T, symlinks string, sources map[string]string) (*sokru.Config, string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, "")
+	}
+
+	cfg := sokru.DefaultConfig()
+	if err := os.MkdirAll(cfg.DotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.SymlinksFile, []byte(symlinks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range sources {
+		if err := os.WriteFile(filepath.Join(cfg.DotfilesDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg, home
+}
+
+const apiSymlinks = `- common:
+    ~/.bashrc: bashrc
+  links:
+    - target: ~/.vimrc
+      source: vimrc
+      mode: copy
+`
+
+var apiSources = map[string]string{"bashrc": "export EDITOR=vim\n", "vimrc": "set number\n"}
+
+func TestInstallStatusUninstall(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	bashrc := filepath.Join(home, ".bashrc")
+	vimrc := filepath.Join(home, ".vimrc")
+
+	result, err := sokru.Install(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if got := result.Count(sokru.ChangeCreated); got != 2 {
+		t.Errorf("Install() created %d targets, want 2", got)
+	}
+	if result.Entries != 1 {
+		t.Errorf("Install() Entries = %d, want 1", result.Entries)
+	}
+
+	if target, err := os.Readlink(bashrc); err != nil || target != filepath.Join(cfg.DotfilesDir, "bashrc") {
+		t.Errorf("%s points to %q (%v)", bashrc, target, err)
+	}
+	if content, err := os.ReadFile(vimrc); err != nil || string(content) != apiSources["vimrc"] {
+		t.Errorf("%s = %q (%v), want a copy of its source", vimrc, content, err)
+	}
+
+	report, err := sokru.CheckStatus(cfg, sokru.StatusOptions{})
+	if err != nil {
+		t.Fatalf("CheckStatus() error = %v", err)
+	}
+	if got := report.Count(sokru.StatusInstalled); got != 2 {
+		t.Errorf("CheckStatus() found %d installed targets, want 2", got)
+	}
+
+	// A second install changes nothing
+	result, err = sokru.Install(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Install() again error = %v", err)
+	}
+	if got := result.Count(sokru.ChangeUnchanged); got != 2 {
+		t.Errorf("Install() again left %d targets unchanged, want 2", got)
+	}
+
+	result, err = sokru.Uninstall(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if got := result.Count(sokru.ChangeRemoved); got != 2 {
+		t.Errorf("Uninstall() removed %d targets, want 2", got)
+	}
+
+	report, err = sokru.CheckStatus(cfg, sokru.StatusOptions{})
+	if err != nil {
+		t.Fatalf("CheckStatus() error = %v", err)
+	}
+	if got := report.Count(sokru.StatusMissing); got != 2 {
+		t.Errorf("CheckStatus() after Uninstall() found %d missing targets, want 2", got)
+	}
+}
+
+func TestInstallDryRun(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	cfg.DryRun = true
+
+	result, err := sokru.Install(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if !result.DryRun || result.Count(sokru.ChangeCreated) != 2 {
+		t.Errorf("Install() = %+v, want 2 planned creations", result)
+	}
+
+	for _, name := range []string{".bashrc", ".vimrc"} {
+		if _, err := os.Lstat(filepath.Join(home, name)); !os.IsNotExist(err) {
+			t.Errorf("a dry run should not create %s", name)
+		}
+	}
+}
+
+func TestOperationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		symlinks string
+		remove   bool
+		wantOp   sokru.Op
+		wantErr  error
+		issues   bool
+	}{
+		{name: "missing symlinks file", remove: true, wantOp: sokru.OpLoad, wantErr: sokru.ErrSymlinksFileNotFound},
+		{name: "invalid symlinks file", symlinks: "- common:\n    ~/.bashrc: missing\n", wantOp: sokru.OpValidate, issues: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := setupDotfiles(t, tt.symlinks, nil)
+			if tt.remove {
+				os.Remove(cfg.SymlinksFile)
+			}
+
+			_, err := sokru.Install(cfg, sokru.RunOptions{})
+			var opErr *sokru.Error
+			if !errors.As(err, &opErr) {
+				t.Fatalf("Install() error = %v, want a *sokru.Error", err)
+			}
+			if opErr.Op != tt.wantOp {
+				t.Errorf("Op = %s, want %s", opErr.Op, tt.wantOp)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			var issues sokru.Issues
+			if tt.issues && (!errors.As(err, &issues) || len(issues) == 0) {
+				t.Errorf("error = %v, want the issues found", err)
+			}
+		})
+	}
+}
+
+func TestDuplicateTargets(t *testing.T) {
+	// Targets of several entries are rejected unless validation is skipped,
+	// then the entry processed last wins
+	cfg, home := setupDotfiles(t, `- common:
+    ~/.bashrc: bashrc
+- common:
+    ~/.bashrc: vimrc
+`, apiSources)
+
+	var issues sokru.Issues
+	if _, err := sokru.Install(cfg, sokru.RunOptions{}); !errors.As(err, &issues) {
+		t.Fatalf("Install() error = %v, want the duplicate target", err)
+	}
+
+	if _, err := sokru.Install(cfg, sokru.RunOptions{SkipValidation: true}); err != nil {
+		t.Fatalf("Install() without validation error = %v", err)
+	}
+	want := filepath.Join(cfg.DotfilesDir, "vimrc")
+	if target, err := os.Readlink(filepath.Join(home, ".bashrc")); err != nil || target != want {
+		t.Errorf(".bashrc points to %q (%v), want %s", target, err, want)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	bashrc := filepath.Join(home, ".bashrc")
+	if err := os.Symlink("/elsewhere", bashrc); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := sokru.NewPlan(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	if !plan.Pending() {
+		t.Fatal("NewPlan() should have pending changes")
+	}
+
+	changes := make(map[string]sokru.Change)
+	for _, change := range plan.Changes {
+		changes[change.Link.Target] = change
+	}
+	if change := changes[bashrc]; change.Kind != sokru.ChangeUpdated || change.Previous != "/elsewhere" {
+		t.Errorf("plan for %s = %+v, want an update from /elsewhere", bashrc, change)
+	}
+	if change := changes[filepath.Join(home, ".vimrc")]; change.Kind != sokru.ChangeCreated {
+		t.Errorf("plan for .vimrc = %+v, want a creation", change)
+	}
+
+	result, err := sokru.Apply(cfg, plan, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if result.Count(sokru.ChangeCreated) != 1 || result.Count(sokru.ChangeUpdated) != 1 {
+		t.Errorf("Apply() = %+v, want 1 creation and 1 update", result.Changes)
+	}
+
+	plan, err = sokru.NewPlan(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("NewPlan() after Apply() error = %v", err)
+	}
+	if plan.Pending() {
+		t.Errorf("NewPlan() after Apply() = %+v, want nothing pending", plan.Changes)
+	}
+}
+
+func TestUnmanagedCopyTarget(t *testing.T) {
+	// Install and Apply both leave a file sok did not write alone
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	vimrc := filepath.Join(home, ".vimrc")
+	if err := os.WriteFile(vimrc, []byte("set nonumber\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := sokru.NewPlan(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	if _, err := sokru.Apply(cfg, plan, sokru.RunOptions{}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	result, err := sokru.Install(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	for name, changes := range map[string][]sokru.Change{"NewPlan": plan.Changes, "Install": result.Changes} {
+		for _, change := range changes {
+			if change.Link.Target != vimrc {
+				continue
+			}
+			if change.Kind != sokru.ChangeSkipped || change.Status != sokru.StatusBlocked {
+				t.Errorf("%s() for %s = %+v, want skipped as blocked", name, vimrc, change)
+			}
+		}
+	}
+	if content, err := os.ReadFile(vimrc); err != nil || string(content) != "set nonumber\n" {
+		t.Errorf("%s = %q (%v), want it untouched", vimrc, content, err)
+	}
+}
+
+func TestApplyKeepsCopiesEditedSincePlan(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	vimrc := filepath.Join(home, ".vimrc")
+	if _, err := sokru.Install(cfg, sokru.RunOptions{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.DotfilesDir, "vimrc"), []byte("set relativenumber\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := sokru.NewPlan(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	// The copy is edited between the plan and Apply
+	if err := os.WriteFile(vimrc, []byte("set nonumber\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sokru.Apply(cfg, plan, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if content, err := os.ReadFile(vimrc); err != nil || string(content) != "set nonumber\n" {
+		t.Errorf("%s = %q (%v), want the local edit", vimrc, content, err)
+	}
+	for _, change := range result.Changes {
+		if change.Link.Target == vimrc && (change.Kind != sokru.ChangeSkipped || change.Status != sokru.StatusDrifted) {
+			t.Errorf("Apply() for %s = %+v, want skipped as drifted", vimrc, change)
+		}
+	}
+	if result.Count(sokru.ChangeSkipped) != 1 {
+		t.Errorf("Apply() = %+v, want the edited copy skipped", result.Changes)
+	}
+}
+
+func TestSwitchSymlinkToCopy(t *testing.T) {
+	// A symlink installed before the entry switched to copy mode is replaced
+	cfg, home := setupDotfiles(t, "- common:\n    ~/.vimrc: vimrc\n", apiSources)
+	vimrc := filepath.Join(home, ".vimrc")
+	if _, err := sokru.Install(cfg, sokru.RunOptions{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	copySymlinks := "- links:\n    - target: ~/.vimrc\n      source: vimrc\n      mode: copy\n"
+	if err := os.WriteFile(cfg.SymlinksFile, []byte(copySymlinks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sokru.Install(cfg, sokru.RunOptions{}); err != nil {
+		t.Fatalf("Install() in copy mode error = %v", err)
+	}
+
+	info, err := os.Lstat(vimrc)
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("%s = %v (%v), want a regular file", vimrc, info, err)
+	}
+	if content, err := os.ReadFile(vimrc); err != nil || string(content) != apiSources["vimrc"] {
+		t.Errorf("%s = %q (%v), want a copy of its source", vimrc, content, err)
+	}
+}
+
+func TestBackupsRestore(t *testing.T) {
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	bashrc := filepath.Join(home, ".bashrc")
+	if err := os.Symlink("/elsewhere", bashrc); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sokru.Install(cfg, sokru.RunOptions{})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if result.Backup == nil || len(result.Backup.Entries) != 1 {
+		t.Fatalf("Install() Backup = %+v, want the replaced symlink", result.Backup)
+	}
+
+	backups, err := sokru.Backups(cfg, sokru.BackupOptions{})
+	if err != nil || len(backups) != 1 || backups[0].ID != result.Backup.ID {
+		t.Fatalf("Backups() = %+v, %v, want the backup of Install()", backups, err)
+	}
+
+	if err := sokru.Restore(cfg, result.Backup.ID, sokru.BackupOptions{}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if target, err := os.Readlink(bashrc); err != nil || target != "/elsewhere" {
+		t.Errorf("%s points to %q (%v) after Restore(), want /elsewhere", bashrc, target, err)
+	}
+
+	if err := sokru.DeleteBackup(cfg, result.Backup.ID, sokru.BackupOptions{}); err != nil {
+		t.Fatalf("DeleteBackup() error = %v", err)
+	}
+	if backups, err := sokru.Backups(cfg, sokru.BackupOptions{}); err != nil || len(backups) != 0 {
+		t.Errorf("Backups() after DeleteBackup() = %+v, %v, want none", backups, err)
+	}
+
+	var opErr *sokru.Error
+	if _, err := sokru.LoadBackup(cfg, "missing", sokru.BackupOptions{}); !errors.As(err, &opErr) || opErr.Op != sokru.OpBackup {
+		t.Errorf("LoadBackup() of a missing backup error = %v, want a backup error", err)
+	}
+}
+
+func TestConfigsKeepTheirHome(t *testing.T) {
+	// Configurations with different homes share a process, not their files
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+	if err := os.Symlink("/elsewhere", filepath.Join(home, ".bashrc")); err != nil {
+		t.Fatal(err)
+	}
+
+	installing, other := *cfg, *cfg
+	installing.Home = filepath.Join(t.TempDir(), "installing")
+	other.Home = filepath.Join(t.TempDir(), "other")
+
+	if _, err := sokru.Install(&installing, sokru.RunOptions{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(installing.Home, "state.json")); err != nil {
+		t.Errorf("state of Install() not in its home: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".local", "state", "sokru", "state.json")); !os.IsNotExist(err) {
+		t.Errorf("Install() with a home wrote the XDG state (%v)", err)
+	}
+
+	if backups, err := sokru.Backups(&installing, sokru.BackupOptions{}); err != nil || len(backups) != 1 {
+		t.Errorf("Backups() of the installing home = %+v, %v, want one", backups, err)
+	}
+	if backups, err := sokru.Backups(&other, sokru.BackupOptions{}); err != nil || len(backups) != 0 {
+		t.Errorf("Backups() of another home = %+v, %v, want none", backups, err)
+	}
+}
+
+func TestConcurrentInstalls(t *testing.T) {
+	// Goroutines installing the same config take turns on the state
+	cfg, home := setupDotfiles(t, apiSymlinks, apiSources)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sokru.Install(cfg, sokru.RunOptions{}); err != nil {
+				t.Errorf("Install() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report, err := sokru.CheckStatus(cfg, sokru.StatusOptions{})
+	if err != nil {
+		t.Fatalf("CheckStatus() error = %v", err)
+	}
+	if got := report.Count(sokru.StatusInstalled); got != 2 {
+		t.Errorf("CheckStatus() after concurrent installs found %d installed targets, want 2", got)
+	}
+	if content, err := os.ReadFile(filepath.Join(home, ".vimrc")); err != nil || string(content) != apiSources["vimrc"] {
+		t.Errorf(".vimrc = %q, %v, want the source", content, err)
+	}
+}
+
+func TestHeldLock(t *testing.T) {
+	cfg, _ := setupDotfiles(t, apiSymlinks, apiSources)
+
+	waited := false
+	lock, err := sokru.AcquireLock(cfg, func(string) { waited = true })
+	if err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	if waited {
+		t.Error("AcquireLock() waited for a free lock")
+	}
+
+	// Operations given the lock run under it
+	plan, err := sokru.NewPlan(cfg, sokru.RunOptions{Lock: lock})
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	if _, err := sokru.Apply(cfg, plan, sokru.RunOptions{Lock: lock}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// Others wait until it is released
+	done := make(chan error)
+	go func() {
+		_, err := sokru.Install(cfg, sokru.RunOptions{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Install() = %v while the lock was held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lock.Release()
+	if err := <-done; err != nil {
+		t.Errorf("Install() error = %v", err)
+	}
+}
+
+func TestInstallEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		symlinks string
+		want     []sokru.EventType
+		wantErr  bool
+	}{
+		{
+			name:     "installs",
+			symlinks: apiSymlinks,
+			want:     []sokru.EventType{sokru.EventLoaded, sokru.EventChange, sokru.EventChange},
+		},
+		{
+			name:     "rolls back a failure",
+			symlinks: "- common:\n    ~/.bashrc: bashrc\n    ~/missing/.vimrc: vimrc\n",
+			want:     []sokru.EventType{sokru.EventLoaded, sokru.EventChange, sokru.EventError, sokru.EventRollback, sokru.EventRolledBack},
+			wantErr:  true,
+		},
+		{
+			name:     "fails validation",
+			symlinks: "- common:\n    ~/.bashrc: missing\n",
+			want:     []sokru.EventType{sokru.EventError},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := setupDotfiles(t, tt.symlinks, apiSources)
+
+			var events []sokru.Event
+			observer := sokru.ObserverFunc(func(event sokru.Event) {
+				events = append(events, event)
+			})
+
+			_, err := sokru.Install(cfg, sokru.RunOptions{Observer: observer})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Install() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []sokru.EventType
+			for _, event := range events {
+				got = append(got, event.Type)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+			if i := indexOf(got, sokru.EventError); tt.wantErr && (i < 0 || events[i].Err != err) {
+				t.Errorf("the error event should carry the error of Install()")
+			}
+		})
+	}
+}
+
+// indexOf returns the index of the first eventType in events
+func indexOf(events []sokru.EventType, eventType sokru.EventType) int {
+	for i, event := range events {
+		if event == eventType {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestJSONObserver(t *testing.T) {
+	cfg, _ := setupDotfiles(t, apiSymlinks, apiSources)
+	cfg.DryRun = true
+
+	var out bytes.Buffer
+	if _, err := sokru.Install(cfg, sokru.RunOptions{Observer: sokru.NewJSONObserver(&out)}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), out.String())
+	}
+
+	var event struct {
+		Type   string `json:"type"`
+		DryRun bool   `json:"dry_run"`
+		Change struct {
+			Kind   string `json:"kind"`
+			Target string `json:"target"`
+		} `json:"change"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("line %q is not JSON: %v", lines[1], err)
+	}
+	if event.Type != "change" || !event.DryRun || event.Change.Kind != "created" || event.Change.Target == "" {
+		t.Errorf("event = %+v, want a dry run creation", event)
+	}
+}
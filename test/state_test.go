@@ -0,0 +1,96 @@
+// Package test
+// Description: Unit tests for the state of materialized targets
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/state"
+)
+
+func TestStateLoadMissing(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-state-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	st, err := state.Load(filepath.Join(tempDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(st.Files) != 0 {
+		t.Errorf("Expected empty state, got %d entries", len(st.Files))
+	}
+}
+
+func TestStateSaveAndLoad(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-state-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	statePath := filepath.Join(tempDir, "nested", "state.json")
+
+	st, err := state.Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	st.Set("/home/me/.config/app.conf", state.FileState{Source: "/srv/app.conf", Mode: "copy", Hash: "abc"})
+	st.Set("/home/me/.config/old.conf", state.FileState{Source: "/srv/old.conf", Mode: "copy", Hash: "def"})
+	st.Delete("/home/me/.config/old.conf")
+
+	if err := st.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := state.Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	fs, ok := loaded.Get("/home/me/.config/app.conf")
+	if !ok {
+		t.Fatal("Expected entry to be saved")
+	}
+	if fs.Source != "/srv/app.conf" || fs.Mode != "copy" || fs.Hash != "abc" {
+		t.Errorf("Unexpected entry: %+v", fs)
+	}
+	if fs.UpdatedAt.IsZero() {
+		t.Error("Expected UpdatedAt to be set")
+	}
+	if _, ok := loaded.Get("/home/me/.config/old.conf"); ok {
+		t.Error("Deleted entry should not be saved")
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-state-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "file")
+	content := []byte("hello\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hash, err := state.HashFile(path)
+	if err != nil {
+		t.Fatalf("HashFile() error = %v", err)
+	}
+	if hash != state.HashBytes(content) {
+		t.Errorf("HashFile() = %s, want %s", hash, state.HashBytes(content))
+	}
+
+	if _, err := state.HashFile(filepath.Join(tempDir, "missing")); err == nil {
+		t.Error("HashFile() expected error for missing file")
+	}
+}
@@ -5,22 +5,25 @@
 package test
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
-	"github.com/alexlm78/sokru/cmd"
+	"github.com/alexlm78/sokru/internal/platform"
+	"github.com/alexlm78/sokru/pkg/sokru"
 )
 
 func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 	tests := []struct {
 		name     string
-		config   cmd.SymlinkConfig
+		config   sokru.SymlinkConfig
 		os       string
 		expected map[string]string
 	}{
 		{
 			name: "Legacy format - link field only",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Link: map[string]string{
 					"~/.bashrc": "~/.dotfiles/bash/bashrc",
 					"~/.vimrc":  "~/.dotfiles/vim/vimrc",
@@ -34,7 +37,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Common only",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Common: map[string]string{
 					"~/.gitconfig": "~/.dotfiles/git/gitconfig",
 					"~/.vimrc":     "~/.dotfiles/vim/vimrc",
@@ -48,7 +51,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Linux specific only",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Linux: map[string]string{
 					"~/.config/i3/config": "~/.dotfiles/i3/config",
 				},
@@ -60,7 +63,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Darwin specific only",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Darwin: map[string]string{
 					"~/Library/Preferences/app.plist": "~/.dotfiles/macos/app.plist",
 				},
@@ -72,7 +75,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Windows specific only",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Windows: map[string]string{
 					"~/AppData/config.ini": "~/.dotfiles/windows/config.ini",
 				},
@@ -84,7 +87,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Common + Linux specific (Linux OS)",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Common: map[string]string{
 					"~/.gitconfig": "~/.dotfiles/git/gitconfig",
 					"~/.vimrc":     "~/.dotfiles/vim/vimrc",
@@ -102,7 +105,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Common + Darwin specific (Darwin OS)",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Common: map[string]string{
 					"~/.gitconfig": "~/.dotfiles/git/gitconfig",
 				},
@@ -118,7 +121,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "OS-specific override common",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Common: map[string]string{
 					"~/.bashrc": "~/.dotfiles/bash/bashrc.common",
 				},
@@ -133,7 +136,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Link field overrides OS-specific",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Link: map[string]string{
 					"~/.bashrc": "~/.dotfiles/bash/bashrc.link",
 				},
@@ -151,7 +154,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Wrong OS - should return empty",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Linux: map[string]string{
 					"~/.config/i3/config": "~/.dotfiles/i3/config",
 				},
@@ -161,7 +164,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "OS filter - matching OS",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				OS: "linux",
 				Link: map[string]string{
 					"~/.config/i3/config": "~/.dotfiles/i3/config",
@@ -174,7 +177,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "OS filter - non-matching OS",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				OS: "linux",
 				Link: map[string]string{
 					"~/.config/i3/config": "~/.dotfiles/i3/config",
@@ -185,7 +188,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name: "Complex: Common + OS-specific with different files",
-			config: cmd.SymlinkConfig{
+			config: sokru.SymlinkConfig{
 				Common: map[string]string{
 					"~/file1": "~/dotfiles/common/file1",
 					"~/file2": "~/dotfiles/common/file2",
@@ -204,7 +207,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 		},
 		{
 			name:     "Empty config",
-			config:   cmd.SymlinkConfig{},
+			config:   sokru.SymlinkConfig{},
 			os:       "linux",
 			expected: map[string]string{},
 		},
@@ -222,7 +225,7 @@ func TestSymlinkConfig_GetLinksForOS(t *testing.T) {
 }
 
 func TestSymlinkConfig_GetLinksForOS_AllOperatingSystems(t *testing.T) {
-	config := cmd.SymlinkConfig{
+	config := sokru.SymlinkConfig{
 		Common: map[string]string{
 			"~/.gitconfig": "~/.dotfiles/git/gitconfig",
 		},
@@ -273,3 +276,492 @@ func TestSymlinkConfig_GetLinksForOS_AllOperatingSystems(t *testing.T) {
 		t.Error("Windows: windows-specific link not found")
 	}
 }
+
+// linkSources maps each resolved link's target to its source
+func linkSources(links map[string]sokru.Link) map[string]string {
+	result := make(map[string]string, len(links))
+	for target, link := range links {
+		result[target] = link.Source
+	}
+	return result
+}
+
+func TestSymlinkConfig_ResolveLinks_Glob(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-glob-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binDir := filepath.Join(tempDir, "bin")
+	// Directories matching a glob are skipped
+	if err := os.MkdirAll(filepath.Join(binDir, "tool-dir"), 0755); err != nil {
+		t.Fatalf("Failed to create bin dir: %v", err)
+	}
+	for _, name := range []string{"tool-a", "tool-b", "README.md"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	targetDir := filepath.Join(tempDir, "target")
+
+	tests := []struct {
+		name     string
+		config   sokru.SymlinkConfig
+		os       string
+		expected map[string]string
+	}{
+		{
+			name: "Glob in map form",
+			config: sokru.SymlinkConfig{
+				Common: map[string]string{
+					targetDir: filepath.Join(binDir, "tool-*"),
+				},
+			},
+			os: "linux",
+			expected: map[string]string{
+				filepath.Join(targetDir, "tool-a"): filepath.Join(binDir, "tool-a"),
+				filepath.Join(targetDir, "tool-b"): filepath.Join(binDir, "tool-b"),
+			},
+		},
+		{
+			name: "Structured glob with exclude",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{
+					{
+						Target:  targetDir,
+						Source:  filepath.Join(binDir, "*"),
+						Exclude: []string{"*.md"},
+					},
+				},
+			},
+			os: "linux",
+			expected: map[string]string{
+				filepath.Join(targetDir, "tool-a"): filepath.Join(binDir, "tool-a"),
+				filepath.Join(targetDir, "tool-b"): filepath.Join(binDir, "tool-b"),
+			},
+		},
+		{
+			name: "Structured link without glob",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{
+					{
+						Target: filepath.Join(targetDir, "readme"),
+						Source: filepath.Join(binDir, "README.md"),
+					},
+				},
+			},
+			os: "linux",
+			expected: map[string]string{
+				filepath.Join(targetDir, "readme"): filepath.Join(binDir, "README.md"),
+			},
+		},
+		{
+			name: "Structured links respect OS filter",
+			config: sokru.SymlinkConfig{
+				OS: "darwin",
+				Links: []sokru.LinkSpec{
+					{Target: targetDir, Source: filepath.Join(binDir, "*")},
+				},
+			},
+			os:       "linux",
+			expected: map[string]string{},
+		},
+		{
+			name: "Glob without matches",
+			config: sokru.SymlinkConfig{
+				Common: map[string]string{
+					targetDir: filepath.Join(binDir, "missing-*"),
+				},
+			},
+			os:       "linux",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := tt.config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: tt.os}})
+			if err != nil {
+				t.Fatalf("ResolveLinks() error = %v", err)
+			}
+
+			result := linkSources(links)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ResolveLinks() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config sokru.SymlinkConfig
+	}{
+		{
+			name: "Missing source",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{{Target: "/tmp/target"}},
+			},
+		},
+		{
+			name: "Unknown kind",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{{Target: "/tmp/target", Source: "/tmp/source", Kind: "bogus"}},
+			},
+		},
+		{
+			name: "Unknown mode",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{{Target: "/tmp/target", Source: "/tmp/source", Mode: "bogus"}},
+			},
+		},
+		{
+			name: "Invalid glob pattern",
+			config: sokru.SymlinkConfig{
+				Common: map[string]string{"/tmp/target": "/tmp/[abc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}}); err == nil {
+				t.Error("ResolveLinks() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_RelativeSources(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("Failed to get home directory: %v", err)
+	}
+
+	config := sokru.SymlinkConfig{
+		Common: map[string]string{
+			"~/.vimrc":     "vim/vimrc",
+			"~/.gitconfig": "~/other/gitconfig",
+			"~/.zshrc":     "/abs/zshrc",
+		},
+	}
+
+	links, err := config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}, BaseDir: "/srv/dotfiles"})
+	if err != nil {
+		t.Fatalf("ResolveLinks() error = %v", err)
+	}
+	result := linkSources(links)
+
+	expected := map[string]string{
+		filepath.Join(homeDir, ".vimrc"):     "/srv/dotfiles/vim/vimrc",
+		filepath.Join(homeDir, ".gitconfig"): filepath.Join(homeDir, "other/gitconfig"),
+		filepath.Join(homeDir, ".zshrc"):     "/abs/zshrc",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ResolveLinks() = %v, want %v", result, expected)
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_Template(t *testing.T) {
+	config := sokru.SymlinkConfig{
+		Links: []sokru.LinkSpec{
+			{
+				Target: "/home/me/.gitconfig",
+				Source: "git/gitconfig.tmpl",
+				Kind:   sokru.KindTemplate,
+				Vars:   map[string]string{"email": "me@example.com"},
+			},
+		},
+	}
+
+	links, err := config.ResolveLinks(sokru.ResolveOptions{
+		Platform:  platform.Info{OS: "linux"},
+		BaseDir:   "/srv/dotfiles",
+		RenderDir: "/state/rendered",
+	})
+	if err != nil {
+		t.Fatalf("ResolveLinks() error = %v", err)
+	}
+
+	link, ok := links["/home/me/.gitconfig"]
+	if !ok {
+		t.Fatal("Template link not resolved")
+	}
+	if link.Kind != sokru.KindTemplate {
+		t.Errorf("Kind = %q, want %q", link.Kind, sokru.KindTemplate)
+	}
+	if link.Template != "/srv/dotfiles/git/gitconfig.tmpl" {
+		t.Errorf("Template = %q", link.Template)
+	}
+	if filepath.Dir(link.Source) != "/state/rendered" {
+		t.Errorf("Source should be inside the render directory, got %q", link.Source)
+	}
+	if link.Vars["email"] != "me@example.com" {
+		t.Errorf("Vars not carried over: %v", link.Vars)
+	}
+
+	// Without a render directory templates cannot be resolved
+	if _, err := config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}}); err == nil {
+		t.Error("ResolveLinks() expected error without render directory")
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_Mode(t *testing.T) {
+	config := sokru.SymlinkConfig{
+		Common: map[string]string{"/home/me/.vimrc": "/srv/dotfiles/vimrc"},
+		Links: []sokru.LinkSpec{
+			{Target: "/home/me/.config/app.conf", Source: "/srv/dotfiles/app.conf", Mode: sokru.ModeCopy},
+			{Target: "/home/me/.config/other.conf", Source: "/srv/dotfiles/other.conf", Mode: sokru.ModeHardlink},
+		},
+	}
+
+	links, err := config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}})
+	if err != nil {
+		t.Fatalf("ResolveLinks() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"/home/me/.vimrc":             sokru.ModeSymlink,
+		"/home/me/.config/app.conf":   sokru.ModeCopy,
+		"/home/me/.config/other.conf": sokru.ModeHardlink,
+	}
+
+	for target, mode := range expected {
+		if links[target].Mode != mode {
+			t.Errorf("Mode of %s = %q, want %q", target, links[target].Mode, mode)
+		}
+	}
+}
+
+func TestSymlinkConfig_GetLinks_Hosts(t *testing.T) {
+	config := sokru.SymlinkConfig{
+		Common: map[string]string{
+			"~/.bashrc":    "/common/bashrc",
+			"~/.gitconfig": "/common/gitconfig",
+		},
+		Linux: map[string]string{
+			"~/.gitconfig": "/linux/gitconfig",
+			"~/.tmux.conf": "/linux/tmux.conf",
+		},
+		Hosts: map[string]map[string]string{
+			"build-*": {
+				"~/.tmux.conf": "/build/tmux.conf",
+				"~/.ccache":    "/build/ccache",
+			},
+			"build-gpu-*": {
+				"~/.ccache": "/build-gpu/ccache",
+			},
+			"build-gpu-01": {
+				"~/.bashrc": "/build-gpu-01/bashrc",
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		platform platform.Info
+		expected map[string]string
+	}{
+		{
+			name:     "No matching host",
+			platform: platform.Info{OS: "linux", Hostname: "laptop"},
+			expected: map[string]string{
+				"~/.bashrc":    "/common/bashrc",
+				"~/.gitconfig": "/linux/gitconfig",
+				"~/.tmux.conf": "/linux/tmux.conf",
+			},
+		},
+		{
+			name:     "Host glob overrides OS",
+			platform: platform.Info{OS: "linux", Hostname: "build-07"},
+			expected: map[string]string{
+				"~/.bashrc":    "/common/bashrc",
+				"~/.gitconfig": "/linux/gitconfig",
+				"~/.tmux.conf": "/build/tmux.conf",
+				"~/.ccache":    "/build/ccache",
+			},
+		},
+		{
+			name:     "More specific glob wins",
+			platform: platform.Info{OS: "linux", Hostname: "build-gpu-02"},
+			expected: map[string]string{
+				"~/.bashrc":    "/common/bashrc",
+				"~/.gitconfig": "/linux/gitconfig",
+				"~/.tmux.conf": "/build/tmux.conf",
+				"~/.ccache":    "/build-gpu/ccache",
+			},
+		},
+		{
+			name:     "Exact host has highest priority",
+			platform: platform.Info{OS: "linux", Hostname: "build-gpu-01.example.com"},
+			expected: map[string]string{
+				"~/.bashrc":    "/build-gpu-01/bashrc",
+				"~/.gitconfig": "/linux/gitconfig",
+				"~/.tmux.conf": "/build/tmux.conf",
+				"~/.ccache":    "/build-gpu/ccache",
+			},
+		},
+		{
+			name:     "Hosts apply on any OS",
+			platform: platform.Info{OS: "darwin", Hostname: "BUILD-03"},
+			expected: map[string]string{
+				"~/.bashrc":    "/common/bashrc",
+				"~/.gitconfig": "/common/gitconfig",
+				"~/.tmux.conf": "/build/tmux.conf",
+				"~/.ccache":    "/build/ccache",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := config.GetLinks(tt.platform)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("GetLinks() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_InvalidHostPattern(t *testing.T) {
+	config := sokru.SymlinkConfig{
+		Hosts: map[string]map[string]string{
+			"build-[": {"/tmp/target": "/tmp/source"},
+		},
+	}
+
+	if _, err := config.ResolveLinks(sokru.ResolveOptions{Platform: platform.Info{OS: "linux"}}); err == nil {
+		t.Error("ResolveLinks() expected error for invalid host pattern")
+	}
+}
+
+func TestSymlinkConfig_Matches(t *testing.T) {
+	intelMac := platform.Info{OS: "darwin", Arch: "amd64"}
+	armMac := platform.Info{OS: "darwin", Arch: "arm64"}
+	ubuntu := platform.Info{OS: "linux", Arch: "amd64", Distro: "ubuntu", DistroLike: []string{"debian"}, Desktops: []string{"ubuntu", "gnome"}}
+	arch := platform.Info{OS: "linux", Arch: "amd64", Distro: "arch", Desktops: []string{"kde"}}
+	wsl := platform.Info{OS: "linux", Arch: "amd64", Distro: "ubuntu", WSL: true}
+
+	yes, no := true, false
+
+	tests := []struct {
+		name     string
+		config   sokru.SymlinkConfig
+		platform platform.Info
+		expected bool
+	}{
+		{"No selectors", sokru.SymlinkConfig{}, armMac, true},
+		{"Arch matches", sokru.SymlinkConfig{OS: "darwin", Arch: "arm64"}, armMac, true},
+		{"Arch differs", sokru.SymlinkConfig{OS: "darwin", Arch: "arm64"}, intelMac, false},
+		{"Arch alias", sokru.SymlinkConfig{Arch: "x86_64"}, intelMac, true},
+		{"Distro matches", sokru.SymlinkConfig{Distro: "arch"}, arch, true},
+		{"Distro differs", sokru.SymlinkConfig{Distro: "arch"}, ubuntu, false},
+		{"Distro family", sokru.SymlinkConfig{Distro: "debian"}, ubuntu, true},
+		{"WSL required", sokru.SymlinkConfig{WSL: &yes}, wsl, true},
+		{"WSL required on native Linux", sokru.SymlinkConfig{WSL: &yes}, ubuntu, false},
+		{"WSL excluded", sokru.SymlinkConfig{WSL: &no}, wsl, false},
+		{"Desktop matches", sokru.SymlinkConfig{Desktop: "GNOME"}, ubuntu, true},
+		{"Desktop differs", sokru.SymlinkConfig{Desktop: "gnome"}, arch, false},
+		{"All selectors must match", sokru.SymlinkConfig{OS: "linux", Distro: "ubuntu", Desktop: "kde"}, ubuntu, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.config.Matches(tt.platform); result != tt.expected {
+				t.Errorf("Matches() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	// Entries that don't match contribute no links
+	config := sokru.SymlinkConfig{Arch: "arm64", Common: map[string]string{"~/.zshrc": "/zshrc"}}
+	if links := config.GetLinks(intelMac); len(links) != 0 {
+		t.Errorf("GetLinks() = %v, want no links", links)
+	}
+}
+
+func TestSymlinkConfig_ResolveLinks_When(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sokru-when-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.Mkdir(filepath.Join(tempDir, "cargo"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	t.Setenv("SOKRU_TEST_WORK", "1")
+
+	opts := sokru.ResolveOptions{
+		Platform: platform.Info{OS: "linux", Arch: "arm64", Desktops: []string{"ubuntu", "GNOME"}},
+		BaseDir:  tempDir,
+	}
+
+	tests := []struct {
+		name     string
+		config   sokru.SymlinkConfig
+		expected []string
+	}{
+		{
+			name: "Entry condition true",
+			config: sokru.SymlinkConfig{
+				When:   `os == "linux" && env.SOKRU_TEST_WORK == "1" && exists("cargo")`,
+				Common: map[string]string{"/home/me/.work": "/work"},
+			},
+			expected: []string{"/home/me/.work"},
+		},
+		{
+			name: "Entry condition false",
+			config: sokru.SymlinkConfig{
+				When:   `arch == "amd64"`,
+				Common: map[string]string{"/home/me/.work": "/work"},
+				Links:  []sokru.LinkSpec{{Target: "/home/me/.other", Source: "/other"}},
+			},
+			expected: nil,
+		},
+		{
+			name: "Link condition",
+			config: sokru.SymlinkConfig{
+				Links: []sokru.LinkSpec{
+					{Target: "/home/me/.cargo/config.toml", Source: "/cargo.toml", When: `exists("cargo")`},
+					{Target: "/home/me/.rustup/settings.toml", Source: "/rustup.toml", When: `exists("rustup")`},
+				},
+			},
+			expected: []string{"/home/me/.cargo/config.toml"},
+		},
+		{
+			name: "Desktop matches any current desktop",
+			config: sokru.SymlinkConfig{
+				When:   `desktop == "gnome" && desktop != "KDE"`,
+				Common: map[string]string{"/home/me/.gnome": "/gnome"},
+			},
+			expected: []string{"/home/me/.gnome"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := tt.config.ResolveLinks(opts)
+			if err != nil {
+				t.Fatalf("ResolveLinks() error = %v", err)
+			}
+
+			var targets []string
+			for target := range links {
+				targets = append(targets, target)
+			}
+			if !reflect.DeepEqual(targets, tt.expected) {
+				t.Errorf("ResolveLinks() targets = %v, want %v", targets, tt.expected)
+			}
+		})
+	}
+
+	invalid := sokru.SymlinkConfig{When: `os ==`, Common: map[string]string{"/a": "/b"}}
+	if _, err := invalid.ResolveLinks(opts); err == nil {
+		t.Error("ResolveLinks() expected error for invalid expression")
+	}
+}
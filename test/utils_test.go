@@ -47,7 +47,7 @@ func TestExpandPath(t *testing.T) {
 		{
 			name:     "Just tilde",
 			input:    "~",
-			expected: "~",
+			expected: homeDir,
 		},
 		{
 			name:     "Tilde not at start",
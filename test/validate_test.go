@@ -0,0 +1,207 @@
+// Package test
+// Description: Unit tests for symlinks file validation
+// (c) 2024 Alejandro Lopez Monzon <alejandro@kreaker.dev>
+
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexlm78/sokru/internal/platform"
+	"github.com/alexlm78/sokru/pkg/sokru"
+)
+
+// validate writes files into a temporary dotfiles directory and validates
+// its symlinks.yaml for linux. The directory is available to the files as
+// $SOKRU_TEST_DOTFILES.
+func validate(t *testing.T, files map[string]string) (string, []sokru.Issue) {
+	t.Helper()
+
+	dotfilesDir := t.TempDir()
+	writeFiles(t, dotfilesDir, files)
+	t.Setenv("SOKRU_TEST_DOTFILES", dotfilesDir)
+
+	issues, err := sokru.ValidateSymlinks(filepath.Join(dotfilesDir, "symlinks.yaml"), sokru.ValidateOptions{
+		Resolve: sokru.ResolveOptions{
+			Platform: platform.Info{OS: "linux"},
+			BaseDir:  dotfilesDir,
+		},
+		DotfilesDir: dotfilesDir,
+	})
+	if err != nil {
+		t.Fatalf("ValidateSymlinks() error = %v", err)
+	}
+	return dotfilesDir, issues
+}
+
+func TestValidateSymlinks_Valid(t *testing.T) {
+	_, issues := validate(t, map[string]string{
+		"symlinks.yaml": `- common:
+    /tmp/sokru-validate/.bashrc: bashrc
+  linux:
+    /tmp/sokru-validate/.bashrc: bashrc-linux
+- links:
+    - target: /tmp/sokru-validate/.vimrc
+      source: vimrc
+`,
+		"bashrc":       "",
+		"bashrc-linux": "",
+		"vimrc":        "",
+	})
+
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestValidateSymlinks_Issues(t *testing.T) {
+	dotfilesDir, issues := validate(t, map[string]string{
+		"symlinks.yaml": `- linx:
+    /tmp/sokru-validate/.bashrc: bashrc
+- common:
+    /tmp/sokru-validate/.vimrc: vimrc
+- common:
+    /tmp/sokru-validate/.vimrc: vimrc
+- darwin:
+    /tmp/sokru-validate/.zshrc: zshrc
+- links:
+    - target: /tmp/sokru-validate/.gitconfig
+      source: gitconfig
+      mdoe: copy
+    - target: /tmp/sokru-validate/.gitconfig
+      source: gitconfig
+- include: more.yaml
+`,
+		"more.yaml": `- common:
+    /tmp/sokru-validate/a: /tmp/sokru-validate/b
+    /tmp/sokru-validate/b: /tmp/sokru-validate/a
+- common:
+    ${SOKRU_TEST_DOTFILES}/inside: vimrc
+- name: first
+  after: second
+- name: second
+  after: first
+`,
+		"vimrc":     "",
+		"gitconfig": "",
+	})
+	symlinkFile := filepath.Join(dotfilesDir, "symlinks.yaml")
+	moreFile := filepath.Join(dotfilesDir, "more.yaml")
+
+	expected := []struct {
+		file     string
+		line     int
+		warning  bool
+		contains string
+	}{
+		{symlinkFile, 1, false, `unknown key "linx", did you mean "link" or "linux"?`},
+		{symlinkFile, 6, false, "duplicate target /tmp/sokru-validate/.vimrc, also defined at " + symlinkFile + ":4"},
+		{symlinkFile, 8, true, "source " + filepath.Join(dotfilesDir, "zshrc") + " does not exist (on darwin)"},
+		{symlinkFile, 12, false, `unknown key "mdoe", did you mean "mode"?`},
+		{symlinkFile, 13, false, "duplicate target /tmp/sokru-validate/.gitconfig, also defined at line 10"},
+		{moreFile, 2, false, "link cycle: /tmp/sokru-validate/a -> /tmp/sokru-validate/b -> /tmp/sokru-validate/a"},
+		{moreFile, 5, false, "is inside the dotfiles directory"},
+		{moreFile, 6, false, "dependency cycle between entries: first -> second -> first"},
+	}
+
+	for _, want := range expected {
+		found := false
+		for _, issue := range issues {
+			if issue.File == want.file && issue.Line == want.line && issue.Warning == want.warning && strings.Contains(issue.Message, want.contains) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Missing issue %s:%d (warning %v): %s\nGot:\n%s", want.file, want.line, want.warning, want.contains, formatIssues(issues))
+		}
+	}
+}
+
+func TestValidateSymlinks_SectionPositions(t *testing.T) {
+	// A target set by several sections is reported at the section in use for
+	// each OS
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	symlinkFile := "test-symlinks-multi-os.yml"
+	issues, err := sokru.ValidateSymlinks(symlinkFile, sokru.ValidateOptions{
+		Resolve: sokru.ResolveOptions{
+			Platform: platform.Info{OS: "linux"},
+			BaseDir:  filepath.Join(home, "dotfiles"),
+		},
+		DotfilesDir: filepath.Join(home, "dotfiles"),
+	})
+	if err != nil {
+		t.Fatalf("ValidateSymlinks() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		line    int
+		warning bool
+	}{
+		{name: "common on windows", source: "common/mixed", line: 18, warning: true},
+		{name: "darwin", source: "darwin/mixed-override", line: 20, warning: true},
+		{name: "linux", source: "linux/mixed-override", line: 22, warning: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := filepath.Join(home, "dotfiles", tt.source)
+			for _, issue := range issues {
+				if strings.Contains(issue.Message, source+" ") {
+					if issue.Line != tt.line || issue.Column != 5 || issue.Warning != tt.warning {
+						t.Errorf("issue of %s = %s, want line %d column 5 (warning %v)", tt.source, issue, tt.line, tt.warning)
+					}
+					return
+				}
+			}
+			t.Errorf("Missing issue for %s\nGot:\n%s", source, formatIssues(issues))
+		})
+	}
+}
+
+func TestValidateSymlinks_MissingSourceIsError(t *testing.T) {
+	_, issues := validate(t, map[string]string{
+		"symlinks.yaml": "- linux:\n    /tmp/sokru-validate/.bashrc: bashrc\n",
+	})
+
+	if len(issues) != 1 || issues[0].Warning || issues[0].Line != 2 {
+		t.Errorf("Expected one error on line 2, got:\n%s", formatIssues(issues))
+	}
+}
+
+func TestValidateSymlinks_Profiles(t *testing.T) {
+	// Entries of profiles that are never active together may share targets
+	_, issues := validate(t, map[string]string{
+		"symlinks.yaml": `- profiles:
+    work: {}
+    personal: {}
+- profile: work
+  common:
+    /tmp/sokru-validate/.ssh/config: ssh-work
+- profile: personal
+  common:
+    /tmp/sokru-validate/.ssh/config: ssh-personal
+`,
+		"ssh-work":     "",
+		"ssh-personal": "",
+	})
+
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got:\n%s", formatIssues(issues))
+	}
+}
+
+func formatIssues(issues []sokru.Issue) string {
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, fmt.Sprint(issue))
+	}
+	return strings.Join(lines, "\n")
+}